@@ -3,7 +3,6 @@ package commands
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"github.com/apflieger/tie/core"
 	"github.com/apflieger/tie/model"
 	"gopkg.in/libgit2/git2go.v25"
@@ -13,7 +12,34 @@ import (
 	"strings"
 )
 
-func CommitCommand(repo *git.Repository, commitMessage string, tipName string, context model.Context) error {
+func CommitCommand(repo *git.Repository, commitMessage string, tipName string, all, patch bool, context model.Context) error {
+	// Staging everything would leave no hunk to choose
+	if all && patch {
+		return errors.New("--all can't be used with --patch.")
+	}
+
+	if all {
+		if err := core.StageTracked(repo); err != nil {
+			return err
+		}
+	}
+
+	if patch {
+		staged, err := selectHunks(repo, context)
+		if err != nil {
+			return err
+		}
+		if !staged {
+			return errors.New("No changes selected.")
+		}
+	}
+
+	if !context.NoVerify {
+		if err := core.RunHook(repo, core.HookPreCommit, ""); err != nil {
+			return err
+		}
+	}
+
 	head, headCommit, tree := core.PrepareCommit(repo)
 
 	if tipName == model.OptionMissing {
@@ -36,13 +62,14 @@ func CommitCommand(repo *git.Repository, commitMessage string, tipName string, c
 		}
 
 		// Create and select a new tip
-		tip, _ := repo.References.Create(core.RefsTips+tipName, head.Target(), false, "tie commit -t")
-		repo.References.Create(core.RefsTails+tipName, head.Target(), false, "tie commit -t")
-		config, _ := repo.Config()
-		config.SetString(fmt.Sprintf("tip.%v.base", tipName), head.Name())
-		head, _ = repo.References.CreateSymbolic("HEAD", tip.Name(), true, "tie commit -t")
+		err := core.CreateTip(repo, tipName, head.Target(), head.Name(), "tie commit -t")
+		if err != nil {
+			return err
+		}
+		head, _ = repo.Head()
 	}
 
+	var messageErr error
 	if commitMessage == "" {
 		linesRegexp := regexp.MustCompile(`(.*)`)
 		lines := linesRegexp.FindAllString(headCommit.Message(), -1)
@@ -50,17 +77,55 @@ func CommitCommand(repo *git.Repository, commitMessage string, tipName string, c
 		for _, line := range lines {
 			presetCommitMessage.WriteString("#" + line + "\n")
 		}
-		commitEditMsgFile := filepath.Join(repo.Path(), "COMMIT_EDITMSG")
-		ioutil.WriteFile(commitEditMsgFile, presetCommitMessage.Bytes(), 0644)
+		commitMessage, messageErr = editCommitMessage(repo, presetCommitMessage.String(), true, context)
+	} else {
+		commitMessage, messageErr = editCommitMessage(repo, commitMessage, false, context, "message")
+	}
 
-		config, _ := repo.Config()
-		commitMessage, _ = context.OpenEditor(config, commitEditMsgFile)
+	if messageErr != nil {
+		return messageErr
 	}
 
 	signature, _ := repo.DefaultSignature()
-	repo.CreateCommit(head.Name(), signature, signature, core.FormatCommitMessage(commitMessage), tree, headCommit)
+	_, err := core.CreateCommit(repo, core.CommitSigner(repo), head.Name(), signature, signature, core.FormatCommitMessage(commitMessage), tree, headCommit)
+	if err != nil {
+		return err
+	}
 
-	core.PushTip(repo, tipName, context)
+	// The commit is kept when the pre-push hook fails, only the push is aborted
+	if err := core.PushTip(repo, tipName, context); core.IsHookError(err) {
+		return err
+	}
 
 	return nil
 }
+
+// Writes the message in COMMIT_EDITMSG and lets the prepare-commit-msg hook, then the editor
+// if asked, change it. The commit-msg hook can still reject or modify the final message.
+func editCommitMessage(repo *git.Repository, message string, edit bool, context model.Context, source ...string) (string, error) {
+	commitEditMsgFile := filepath.Join(repo.Path(), "COMMIT_EDITMSG")
+	ioutil.WriteFile(commitEditMsgFile, []byte(message), 0644)
+
+	err := core.RunHook(repo, core.HookPrepareCommitMsg, "", append([]string{commitEditMsgFile}, source...)...)
+	if err != nil {
+		return "", err
+	}
+
+	if edit {
+		config, _ := repo.Config()
+		edited, err := context.OpenEditor(config, commitEditMsgFile)
+		if err != nil {
+			return "", err
+		}
+		ioutil.WriteFile(commitEditMsgFile, []byte(edited), 0644)
+	}
+
+	if !context.NoVerify {
+		if err := core.RunHook(repo, core.HookCommitMsg, "", commitEditMsgFile); err != nil {
+			return "", err
+		}
+	}
+
+	content, err := ioutil.ReadFile(commitEditMsgFile)
+	return string(content), err
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/libgit2/git2go.v25"
 	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -21,7 +23,7 @@ func TestCommitCommand(t *testing.T) {
 		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
 
 		// tie commit -m "fix typo"
-		err := CommitCommand(repo, "fix typo", model.OptionMissing, context.Context)
+		err := CommitCommand(repo, "fix typo", model.OptionMissing, false, false, context.Context)
 		assert.Nil(t, err)
 
 		// We expect the target of head to be one commit ahead, status clear and HEAD still on the tip
@@ -67,7 +69,7 @@ func TestCommitCommand(t *testing.T) {
 			presetCommitMessage = string(bytes)
 			return "Commit message from mocked editor", nil
 		}
-		CommitCommand(repo, "", model.OptionMissing, context.Context)
+		CommitCommand(repo, "", model.OptionMissing, false, false, context.Context)
 
 		// The commit message should have been preset with the previous one, commented
 		assert.Equal(t, "#A commit message.\n#With a second line.\n", presetCommitMessage)
@@ -79,7 +81,7 @@ func TestCommitCommand(t *testing.T) {
 	})
 
 	test.RunOnRepo(t, "NotOnTipError", func(t *testing.T, context test.TestContext, repo *git.Repository) {
-		err := CommitCommand(repo, "Commit on master", model.OptionMissing, context.Context)
+		err := CommitCommand(repo, "Commit on master", model.OptionMissing, false, false, context.Context)
 
 		if assert.NotNil(t, err) {
 			assert.Equal(t, "HEAD is not on a tip. Run 'commit -t' to create a tip on the fly.", err.Error())
@@ -87,12 +89,12 @@ func TestCommitCommand(t *testing.T) {
 	})
 
 	test.RunOnRepo(t, "OnTheFlyTipEmptyNameError", func(t *testing.T, context test.TestContext, repo *git.Repository) {
-		err := CommitCommand(repo, "Commit on master", "", context.Context)
+		err := CommitCommand(repo, "Commit on master", "", false, false, context.Context)
 		if assert.NotNil(t, err) {
 			assert.Equal(t, "Name of the tip can't be empty.", err.Error())
 		}
 
-		err = CommitCommand(repo, "Commit on master", " ", context.Context)
+		err = CommitCommand(repo, "Commit on master", " ", false, false, context.Context)
 		if assert.NotNil(t, err) {
 			assert.Equal(t, "Name of the tip can't be empty.", err.Error())
 		}
@@ -103,7 +105,7 @@ func TestCommitCommand(t *testing.T) {
 		test.WriteFile(repo, true, "foo", "bar")
 
 		// tie commit -t new_tip -m "Added foo"
-		err := CommitCommand(repo, "Added foo", "new_tip", context.Context)
+		err := CommitCommand(repo, "Added foo", "new_tip", false, false, context.Context)
 		assert.Nil(t, err)
 
 		// HEAD should be on new_tip
@@ -129,11 +131,153 @@ func TestCommitCommand(t *testing.T) {
 		test.WriteFile(repo, true, "foo", "bar")
 
 		// tie commit -t new_tip -m "Added foo"
-		err := CommitCommand(repo, "Added foo", model.OptionWithoutValue, context.Context)
+		err := CommitCommand(repo, "Added foo", model.OptionWithoutValue, false, false, context.Context)
 		assert.Nil(t, err)
 
 		// HEAD should be on master-tip
 		head, _ := repo.Head()
 		assert.Equal(t, core.RefsTips+"master-tip", head.Name())
 	})
+	test.RunOnRepo(t, "All", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteFile(repo, true, "foo", "bar")
+		test.Commit(repo, nil)
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+
+		// Modify foo without adding it
+		test.WriteFile(repo, false, "foo", "baz")
+
+		// tie commit -a -m "Changed foo"
+		err := CommitCommand(repo, "Changed foo", model.OptionMissing, true, false, context.Context)
+		assert.Nil(t, err)
+
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "Patch", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		lines := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+		test.WriteFile(repo, true, "foo", lines...)
+		test.Commit(repo, nil)
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+
+		changed := append([]string{}, lines...)
+		changed[0] = "one"
+		changed[9] = "ten"
+		test.WriteFile(repo, false, "foo", changed...)
+
+		// Stage the first hunk only
+		answers := []string{"y", "n"}
+		context.Prompt = func(question string) (string, error) {
+			answer := answers[0]
+			answers = answers[1:]
+			return answer, nil
+		}
+
+		// tie commit -p -m "Changed the first line"
+		err := CommitCommand(repo, "Changed the first line", model.OptionMissing, false, true, context.Context)
+		assert.Nil(t, err)
+		assert.Empty(t, answers)
+
+		head, _ := repo.Head()
+		commit, _ := repo.LookupCommit(head.Target())
+		tree, _ := commit.Tree()
+		entry := tree.EntryByName("foo")
+		blob, _ := repo.LookupBlob(entry.Id)
+		committed := append([]string{"one"}, lines[1:]...)
+		assert.Equal(t, strings.Join(committed, "\n"), string(blob.Contents()))
+
+		// The second hunk is left in the workdir
+		content, _ := ioutil.ReadFile(filepath.Join(repo.Workdir(), "foo"))
+		assert.Equal(t, strings.Join(changed, "\n"), string(content))
+	})
+
+	test.RunOnRepo(t, "PatchNothingSelected", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteFile(repo, true, "foo", "bar")
+		test.Commit(repo, nil)
+		test.WriteFile(repo, false, "foo", "baz")
+
+		context.Prompt = func(question string) (string, error) {
+			return "q", nil
+		}
+
+		err := CommitCommand(repo, "Nothing", model.OptionMissing, false, true, context.Context)
+		assert.Equal(t, "No changes selected.", err.Error())
+	})
+
+	test.RunOnRepo(t, "AllAndPatch", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteFile(repo, true, "foo", "bar")
+		test.Commit(repo, nil)
+		test.WriteFile(repo, false, "foo", "baz")
+
+		err := CommitCommand(repo, "Both", model.OptionMissing, true, true, context.Context)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "--all can't be used with --patch.", err.Error())
+		}
+
+		// Nothing has been staged
+		statusList, _ := repo.StatusList(&git.StatusOptions{Show: git.StatusShowIndexAndWorkdir})
+		entry, _ := statusList.ByIndex(0)
+		assert.Equal(t, git.StatusWtModified, entry.Status)
+	})
+
+	test.RunOnRepo(t, "PreCommitHook", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		head, _ := repo.Head()
+		test.WriteFile(repo, true, "foo", "bar")
+		test.WriteHook(repo, core.HookPreCommit, "exit 1")
+
+		err := CommitCommand(repo, "Added foo", model.OptionMissing, false, false, context.Context)
+		assert.Equal(t, "The pre-commit hook failed (exit status 1).", err.Error())
+		head2, _ := repo.Head()
+		assert.True(t, head2.Target().Equal(head.Target()))
+
+		// tie commit --no-verify
+		context.NoVerify = true
+		err = CommitCommand(repo, "Added foo", model.OptionMissing, false, false, context.Context)
+		assert.Nil(t, err)
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "CommitMsgHooks", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, true, "foo", "bar")
+		test.WriteHook(repo, core.HookPrepareCommitMsg, `echo "[$2] $(cat $1)" > $1`)
+		test.WriteHook(repo, core.HookCommitMsg, `echo "Ticket: T-1" >> $1`)
+
+		err := CommitCommand(repo, "Added foo", model.OptionMissing, false, false, context.Context)
+		assert.Nil(t, err)
+
+		head, _ := repo.Head()
+		commit, _ := repo.LookupCommit(head.Target())
+		assert.Equal(t, "[message] Added foo\nTicket: T-1\n", commit.Message())
+	})
+
+	test.RunOnRepo(t, "CommitMsgHookRejects", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		head, _ := repo.Head()
+		test.WriteFile(repo, true, "foo", "bar")
+		test.WriteHook(repo, core.HookCommitMsg, `grep -q "T-[0-9]" $1`)
+
+		err := CommitCommand(repo, "Added foo", model.OptionMissing, false, false, context.Context)
+		assert.NotNil(t, err)
+		head2, _ := repo.Head()
+		assert.True(t, head2.Target().Equal(head.Target()))
+	})
+	test.RunOnRepo(t, "Signed", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, true, "foo", "bar")
+
+		program := filepath.Join(repo.Path(), "gpg")
+		ioutil.WriteFile(program, []byte("#!/bin/sh\ncat > /dev/null\necho '[GNUPG:] SIG_CREATED ' >&2\necho signature\n"), 0755)
+		config, _ := repo.Config()
+		config.SetBool("commit.gpgsign", true)
+		config.SetString("gpg.program", program)
+
+		err := CommitCommand(repo, "Added foo", model.OptionMissing, false, false, context.Context)
+		assert.Nil(t, err)
+
+		head, _ := repo.Head()
+		commit, _ := repo.LookupCommit(head.Target())
+		assert.Equal(t, "Added foo\n", commit.Message())
+		assert.True(t, core.IsSigned(repo, commit))
+	})
 }
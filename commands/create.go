@@ -28,19 +28,5 @@ func CreateCommand(repo *git.Repository, name, base string) error {
 		}
 	}
 
-	tipRef, err := repo.References.Create(core.RefsTips+name, baseRef.Target(), false, "tie tip create")
-
-	if err != nil {
-		return err
-	}
-
-	repo.References.Create(core.RefsTails+name, baseRef.Target(), false, "tie tip create")
-
-	repo.References.CreateSymbolic("HEAD", tipRef.Name(), true, "tie tip create")
-
-	config, _ := repo.Config()
-
-	config.SetString(fmt.Sprintf("tip.%v.base", name), baseRef.Name())
-
-	return nil
+	return core.CreateTip(repo, name, baseRef.Target(), baseRef.Name(), "tie tip create")
 }
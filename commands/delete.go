@@ -7,8 +7,8 @@ import (
 	"gopkg.in/libgit2/git2go.v25"
 )
 
-func DeleteCommand(repo *git.Repository, stacked bool, refs []string, context model.Context) error {
-	if stacked {
+func DeleteCommand(repo *git.Repository, stacked, merged bool, refs []string, context model.Context) error {
+	if stacked || merged {
 		it, _ := repo.NewReferenceIteratorGlob(core.RefsTips + "*")
 		refs = []string{}
 		for tip, end := it.Next(); end == nil; tip, end = it.Next() {
@@ -19,6 +19,11 @@ func DeleteCommand(repo *git.Repository, stacked bool, refs []string, context mo
 			isDescendant, _ := repo.DescendantOf(baseRef.Target(), tip.Target())
 			if isDescendant || baseRef.Target().Equal(tip.Target()) {
 				refs = append(refs, tip.Name())
+			} else if merged {
+				// The commits may have been cherry-picked, squashed or rebased on the base
+				if _, landed, _ := core.LandedCommits(repo, tipName); landed {
+					refs = append(refs, tip.Name())
+				}
 			}
 		}
 	} else if len(refs) == 0 {
@@ -37,24 +42,41 @@ func DeleteCommand(repo *git.Repository, stacked bool, refs []string, context mo
 		// select the base before deletion.
 		head, _ := repo.Head()
 		if head.Name() == ref {
-			tipName, _ := core.TipName(ref)
-			config, _ := repo.Config()
-			base, _ := config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
-			baseRef, _ := repo.References.Lookup(base)
-
-			// checkout the index and the working tree
-			commit, _ := repo.LookupCommit(baseRef.Target())
-
-			tree, _ := commit.Tree()
+			err = deleteSelectedTip(repo, tipName, context)
+		} else {
+			err = core.DeleteTip(repo, tipName, context)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			repo.CheckoutTree(tree, &git.CheckoutOpts{Strategy: git.CheckoutSafe})
+// Checks the base of the selected tip out, then deletes the tip and moves HEAD to the base
+// in a single transaction. The working tree goes back to the tip if the transaction fails.
+func deleteSelectedTip(repo *git.Repository, tipName string, context model.Context) error {
+	config, _ := repo.Config()
+	base, _ := config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
+	baseRef, err := repo.References.Lookup(base)
+	if err != nil {
+		return fmt.Errorf("Base '%v' of tip '%v' doesn't exist.", base, tipName)
+	}
 
-			// set HEAD
-			_, err = repo.References.CreateSymbolic("HEAD", base, true,
-				fmt.Sprintf("Select %v caused by deleting currently selected %v", base, ref))
-		}
+	// checkout the index and the working tree
+	commit, _ := repo.LookupCommit(baseRef.Target())
+	tree, _ := commit.Tree()
+	if err := repo.CheckoutTree(tree, &git.CheckoutOpts{Strategy: git.CheckoutSafe}); err != nil {
+		return err
+	}
 
-		core.DeleteTip(repo, tipName, context)
+	if err := core.DeleteSelectedTip(repo, tipName, base, context); err != nil {
+		head, _ := repo.Head()
+		headCommit, _ := repo.LookupCommit(head.Target())
+		headTree, _ := headCommit.Tree()
+		repo.CheckoutTree(headTree, &git.CheckoutOpts{Strategy: git.CheckoutSafe})
+		return err
 	}
+
 	return nil
 }
@@ -13,7 +13,7 @@ func TestDeleteCommand(t *testing.T) {
 		// create a tip with his tail and base
 		test.CreateTip(repo, "test", "refs/heads/master", false)
 
-		err := DeleteCommand(repo, false, []string{core.RefsTips + "test"}, context.Context)
+		err := DeleteCommand(repo, false, false, []string{core.RefsTips + "test"}, context.Context)
 
 		assert.Nil(t, err)
 
@@ -25,12 +25,26 @@ func TestDeleteCommand(t *testing.T) {
 		assert.Equal(t, "Deleted tip 'test'\n", context.OutputBuffer.String())
 	})
 
+	test.RunOnRepo(t, "WorkInProgress", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		head, _ := repo.Head()
+		repo.References.Create(core.RefsWip+"test", head.Target(), false, "")
+
+		err := DeleteCommand(repo, false, false, []string{core.RefsTips + "test"}, context.Context)
+
+		assert.Nil(t, err)
+
+		// the saved work in progress goes away with the tip
+		_, err = repo.References.Lookup(core.RefsWip + "test")
+		assert.NotNil(t, err)
+	})
+
 	test.RunOnRepo(t, "Branch", func(t *testing.T, context test.TestContext, repo *git.Repository) {
 		// create a local branch
 		head, _ := repo.Head()
 		repo.References.Create("refs/heads/test", head.Target(), false, "")
 
-		err := DeleteCommand(repo, false, []string{"refs/heads/test"}, context.Context)
+		err := DeleteCommand(repo, false, false, []string{"refs/heads/test"}, context.Context)
 
 		// tie delete doesn't allow to delete branches
 		assert.NotNil(t, err)
@@ -56,7 +70,7 @@ func TestDeleteCommand(t *testing.T) {
 		repo.References.Create("refs/remotes/origin/tips/test", head.Target(), false, "")
 		origin.Push([]string{tipRefName + ":refs/heads/tips/test"}, nil)
 
-		err := DeleteCommand(repo, false, []string{tipRefName}, context.Context)
+		err := DeleteCommand(repo, false, false, []string{tipRefName}, context.Context)
 
 		assert.Nil(t, err)
 
@@ -73,7 +87,7 @@ func TestDeleteCommand(t *testing.T) {
 		// create an unreachable origin remote
 		repo.Remotes.Create("origin", "/dev/null")
 
-		err := DeleteCommand(repo, false, []string{core.RefsTips + "test"}, context.Context)
+		err := DeleteCommand(repo, false, false, []string{core.RefsTips + "test"}, context.Context)
 
 		assert.Nil(t, err)
 
@@ -90,7 +104,7 @@ func TestDeleteCommand(t *testing.T) {
 		test.Commit(repo, nil)
 
 		// Delete the tip
-		err := DeleteCommand(repo, false, nil, context.Context)
+		err := DeleteCommand(repo, false, false, nil, context.Context)
 		assert.Nil(t, err)
 
 		// output should be...
@@ -126,7 +140,7 @@ func TestDeleteCommand(t *testing.T) {
 
 			// At this point, master is one commit ahead of the tip
 
-			err := DeleteCommand(repo, true, nil, context.Context)
+			err := DeleteCommand(repo, true, false, nil, context.Context)
 			assert.Nil(t, err)
 
 			// output should be...
@@ -146,7 +160,7 @@ func TestDeleteCommand(t *testing.T) {
 			// Select master
 			repo.SetHead("refs/heads/master")
 
-			err := DeleteCommand(repo, true, nil, context.Context)
+			err := DeleteCommand(repo, true, false, nil, context.Context)
 			assert.Nil(t, err)
 
 			// output should be...
@@ -159,4 +173,35 @@ func TestDeleteCommand(t *testing.T) {
 			assert.NotNil(t, err)
 		})
 	})
+
+	t.Run("MergedOption", func(t *testing.T) {
+
+		test.RunOnRepo(t, "CherryPicked", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+			test.CreateTip(repo, "test", "refs/heads/master", false)
+			test.CreateTip(repo, "pending", "refs/heads/master", false)
+			test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"foo": "foo"})
+			test.CommitFiles(repo, core.RefsTips+"pending", map[string]string{"bar": "bar"})
+
+			// The commit of test lands on master with another parent
+			test.CommitFiles(repo, "refs/heads/master", map[string]string{"other": "other"})
+			test.CommitFiles(repo, "refs/heads/master", map[string]string{"foo": "foo"})
+
+			// --stacked doesn't see it
+			err := DeleteCommand(repo, true, false, nil, context.Context)
+			assert.Nil(t, err)
+			_, err = repo.References.Lookup(core.RefsTips + "test")
+			assert.Nil(t, err)
+
+			err = DeleteCommand(repo, false, true, nil, context.Context)
+			assert.Nil(t, err)
+
+			assert.Contains(t, context.OutputBuffer.String(), "Deleted tip 'test'")
+			_, err = repo.References.Lookup(core.RefsTips + "test")
+			assert.NotNil(t, err)
+
+			// The tip that didn't land is kept
+			_, err = repo.References.Lookup(core.RefsTips + "pending")
+			assert.Nil(t, err)
+		})
+	})
 }
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Sets the description of the tip, or opens the editor on it when message is missing
+func DescribeCommand(repo *git.Repository, tip, message string, context model.Context) error {
+	tipName, err := resolveTip(repo, tip, "Not on a tip. Specify the tip to describe.")
+	if err != nil {
+		return err
+	}
+
+	previous := core.TipDescription(repo, tipName)
+
+	if message == model.OptionMissing {
+		descriptionFile := filepath.Join(repo.Path(), "TIP_EDITMSG")
+		template := fmt.Sprintf("%v\n# Describe the tip '%v'. The first line is the title.\n"+
+			"# Lines starting with '#' are ignored. An empty description removes it.\n", previous, tipName)
+		ioutil.WriteFile(descriptionFile, []byte(template), 0644)
+
+		config, _ := repo.Config()
+		message, err = context.OpenEditor(config, descriptionFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	description := core.FormatCommitMessage(message)
+	if description == previous {
+		return nil
+	}
+
+	if err := core.SetTipDescription(repo, tipName, description); err != nil {
+		return err
+	}
+
+	if len(description) == 0 {
+		context.Logger.Printf("Removed the description of tip '%v'\n", tipName)
+		core.DeleteRemoteDescription(repo, tipName, context)
+	} else {
+		context.Logger.Printf("Described tip '%v': %v\n", tipName, core.TipTitle(repo, tipName))
+		if err := core.PushTip(repo, tipName, context); core.IsHookError(err) {
+			return err
+		}
+	}
+
+	return nil
+}
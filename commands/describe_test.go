@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDescribeCommand(t *testing.T) {
+	test.RunOnRepo(t, "Editor", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		core.SetTipDescription(repo, "test", "Old title\n")
+
+		context.OpenEditor = func(config *git.Config, file string) (string, error) {
+			content, _ := ioutil.ReadFile(file)
+			// The current description is proposed
+			assert.Contains(t, string(content), "Old title\n")
+			return "New title\n\nDetails\n# ignored\n", nil
+		}
+
+		err := DescribeCommand(repo, "", model.OptionMissing, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t, "New title\n\nDetails\n", core.TipDescription(repo, "test"))
+		assert.Equal(t, "Described tip 'test': New title\n", context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "Remove", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		core.SetTipDescription(repo, "test", "Title\n")
+
+		err := DescribeCommand(repo, "test", "", context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t, "", core.TipDescription(repo, "test"))
+		assert.Equal(t, "Removed the description of tip 'test'\n", context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "ShownInListAndStatus", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		DescribeCommand(repo, "", "Title\n\nDetails", context.Context)
+		context.OutputBuffer.Reset()
+
+		ListCommand(repo, context.Context, true, false, false, false)
+		assert.Equal(t, "* refs/tips/test  Title\n", context.OutputBuffer.String())
+		context.OutputBuffer.Reset()
+
+		StatusCommand(repo, context.Context)
+		assert.Equal(t,
+			"On tip 'test' based on 'refs/heads/master'\n"+
+				"Title: Title\n"+
+				"0 commits ahead, 0 behind 'refs/heads/master'\n",
+			context.OutputBuffer.String())
+		context.OutputBuffer.Reset()
+
+		// The title is only part of the json output, the porcelain fields are stable
+		context.Format = model.FormatJson
+		ListCommand(repo, context.Context, true, false, false, false)
+		assert.Contains(t, context.OutputBuffer.String(), `"selected":true,"title":"Title"}`)
+		context.OutputBuffer.Reset()
+
+		context.Format = model.FormatPorcelain
+		ListCommand(repo, context.Context, true, false, false, false)
+		assert.NotContains(t, context.OutputBuffer.String(), "Title")
+	})
+
+	test.RunOnRemote(t, "PrePushHookFailure", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
+		test.WriteHook(repo, core.HookPrePush, "exit 1")
+
+		err := DescribeCommand(repo, "", "Title", context.Context)
+		assert.True(t, core.IsHookError(err))
+
+		// The description is kept, only the push is aborted
+		assert.Equal(t, "Title\n", core.TipDescription(repo, "test"))
+		_, err = remote.References.Lookup(core.RefsTips + "test")
+		assert.NotNil(t, err)
+	})
+}
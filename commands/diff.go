@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+)
+
+// Prints the changes introduced by the tip since its tail. With remote, compares
+// the commits of the tip with the ones last pushed on its remote tip instead.
+func DiffCommand(repo *git.Repository, tip string, remote, nameStatus bool, context model.Context) error {
+	tipName, err := resolveTip(repo, tip, "Not on a tip. Specify the tip to diff.")
+	if err != nil {
+		return err
+	}
+
+	if remote {
+		return rangeDiff(repo, tipName, nameStatus, context)
+	}
+
+	diff, err := core.TipDiff(repo, tipName)
+	if err != nil {
+		return err
+	}
+	defer diff.Free()
+
+	var text string
+	if nameStatus {
+		text, err = core.DiffNameStatus(diff)
+	} else {
+		text, err = core.DiffPatch(diff)
+	}
+	if err != nil {
+		return err
+	}
+
+	context.Logger.Print(text)
+
+	return nil
+}
+
+func rangeDiff(repo *git.Repository, tipName string, summaryOnly bool, context model.Context) error {
+	old, remoteTipName, err := core.RemoteTipCommits(repo, tipName)
+	if err != nil {
+		return err
+	}
+
+	updated, err := core.TipCommits(repo, tipName)
+	if err != nil {
+		return err
+	}
+
+	pairs, err := core.RangeDiff(repo, old, updated)
+	if err != nil {
+		return err
+	}
+
+	context.Logger.Printf("Comparing '%v' with '%v'\n", core.RefsTips+tipName, remoteTipName)
+
+	for _, pair := range pairs {
+		summary := ""
+		if pair.New != nil {
+			summary = pair.New.Summary()
+		} else {
+			summary = pair.Old.Summary()
+		}
+
+		context.Logger.Printf("%v %v %v %v\n", rangeDiffSide(pair.OldIndex, pair.Old), pair.Status(), rangeDiffSide(pair.NewIndex, pair.New), summary)
+
+		if summaryOnly || len(pair.Interdiff) == 0 {
+			continue
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(pair.Interdiff, "\n"), "\n") {
+			context.Logger.Println("    " + line)
+		}
+	}
+
+	return nil
+}
+
+func rangeDiffSide(index int, commit *git.Commit) string {
+	if commit == nil {
+		return "-:  -------"
+	}
+	return fmt.Sprintf("%v:  %v", index, commit.Id().String()[:7])
+}
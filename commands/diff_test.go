@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestDiffCommand(t *testing.T) {
+	test.RunOnRepo(t, "Patch", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, true, "foo", "foo")
+		test.Commit(repo, nil)
+		test.WriteFile(repo, true, "foo", "bar")
+		test.Commit(repo, nil)
+
+		err := DiffCommand(repo, "", false, false, context.Context)
+		assert.Nil(t, err)
+		assert.Contains(t, context.OutputBuffer.String(), "diff --git a/foo b/foo\nnew file mode 100644\n")
+		assert.Contains(t, context.OutputBuffer.String(), "+bar\n")
+	})
+
+	test.RunOnRepo(t, "NameStatus", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"foo": "foo", "bar": "bar"})
+
+		err := DiffCommand(repo, "test", false, true, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t, "A\tbar\nA\tfoo\n", context.OutputBuffer.String())
+	})
+
+	test.RunOnRemote(t, "RangeDiff", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
+		a := test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"foo": "foo"})
+		core.PushTip(repo, "test", context.Context)
+		b := test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"bar": "bar"})
+		context.OutputBuffer.Reset()
+
+		err := DiffCommand(repo, "", true, false, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t,
+			"Comparing 'refs/tips/test' with 'refs/rtips/origin/test'\n"+
+				"1:  "+a.String()[:7]+" = 1:  "+a.String()[:7]+" default message\n"+
+				"-:  ------- > 2:  "+b.String()[:7]+" default message\n",
+			context.OutputBuffer.String())
+	})
+
+	test.RunOnRemote(t, "NotPushed", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
+
+		err := DiffCommand(repo, "", true, false, context.Context)
+		assert.Equal(t, "Tip 'test' hasn't been pushed to origin.", err.Error())
+	})
+}
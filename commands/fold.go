@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+)
+
+// Replays the commits of the tip src on top of the tip dst, then deletes src.
+// dst defaults to the base of src when it is a tip.
+func FoldCommand(repo *git.Repository, src, dst string, context model.Context) error {
+	if core.RewriteInProgress(repo) {
+		return errors.New("A rewrite is in progress. Run 'tie rewrite continue' or 'tie rewrite abort' before folding.")
+	}
+
+	srcName, err := resolveTip(repo, src, "Not on a tip. Specify the tip to fold.")
+	if err != nil {
+		return err
+	}
+
+	config, _ := repo.Config()
+	srcBase, _ := config.LookupString(fmt.Sprintf("tip.%v.base", srcName))
+
+	dstName := strings.TrimPrefix(dst, core.RefsTips)
+	if len(dstName) == 0 {
+		var notTip error
+		dstName, notTip = core.TipName(srcBase)
+		if notTip != nil {
+			return fmt.Errorf("Tip '%v' isn't stacked on a tip. Specify the tip to fold it into with --into.", srcName)
+		}
+	}
+
+	dstTip, err := repo.References.Lookup(core.RefsTips + dstName)
+	if err != nil {
+		return fmt.Errorf("Tip '%v' doesn't exist.", dstName)
+	}
+
+	if dstName == srcName {
+		return fmt.Errorf("Tip '%v' can't be folded into itself.", srcName)
+	}
+
+	for _, descendant := range core.TipDescendants(repo, srcName) {
+		if descendant == dstName {
+			return fmt.Errorf("Tip '%v' is stacked on '%v'. Fold '%v' into '%v' instead.", dstName, srcName, dstName, srcName)
+		}
+	}
+
+	// All the tips moved by the fold must be free
+	for _, tipName := range append([]string{srcName, dstName}, core.TipDescendants(repo, srcName)...) {
+		if err := core.CheckTipNotInWorktree(repo, tipName); err != nil {
+			return err
+		}
+	}
+
+	srcCommits, err := core.TipCommits(repo, srcName)
+	if err != nil {
+		return err
+	}
+
+	onto, _ := repo.LookupCommit(dstTip.Target())
+	top, err := core.ReplayCommits(repo, srcCommits, onto)
+	if err != nil {
+		return err
+	}
+
+	// The tips stacked on src are replayed on the folded tip, and so on for theirs
+	moved := map[string]*git.Commit{srcName: top}
+	descendants := []string{}
+	bases := core.TipBases(repo)
+	for _, descendant := range core.TipDescendants(repo, srcName) {
+		baseTip, _ := core.TipName(bases[descendant])
+		newBase, found := moved[baseTip]
+		if !found {
+			continue
+		}
+		commits, err := core.TipCommits(repo, descendant)
+		if err != nil {
+			continue
+		}
+		replayed, err := core.ReplayCommits(repo, commits, newBase)
+		if err != nil {
+			return fmt.Errorf("Cannot replay tip '%v' on the folded tip (%v).", descendant, err)
+		}
+		moved[descendant] = replayed
+		descendants = append(descendants, descendant)
+	}
+
+	// Check out the folded tip when one of the two tips is selected, or the
+	// replayed tip stacked on src
+	head, _ := repo.Head()
+	selected := head != nil && (head.Name() == core.RefsTips+srcName || head.Name() == dstTip.Name())
+	checkout := top
+	if head != nil {
+		if tipName, err := core.TipName(head.Name()); err == nil && tipName != srcName && moved[tipName] != nil {
+			checkout = moved[tipName]
+		}
+	}
+	if selected || checkout != top {
+		headCommit, _ := repo.LookupCommit(head.Target())
+		baseline, _ := headCommit.Tree()
+		tree, _ := checkout.Tree()
+		err := repo.CheckoutTree(tree, &git.CheckoutOpts{Strategy: git.CheckoutSafe, Baseline: baseline})
+		if err != nil {
+			return err
+		}
+	}
+
+	tx := core.NewTransaction(repo, "tie fold")
+	tx.CreateRef(dstTip.Name(), top.Id(), true)
+	if selected {
+		tx.CreateSymbolicRef("HEAD", dstTip.Name())
+	}
+	for child, base := range bases {
+		if base == core.RefsTips+srcName {
+			tx.SetConfig(fmt.Sprintf("tip.%v.base", child), dstTip.Name())
+		}
+	}
+	for _, descendant := range descendants {
+		baseTip, _ := core.TipName(bases[descendant])
+		tx.CreateRef(core.RefsTips+descendant, moved[descendant].Id(), true)
+		tx.CreateRef(core.RefsTails+descendant, moved[baseTip].Id(), true)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	context.Logger.Printf("Folded tip '%v' into '%v' (%v)\n", srcName, dstName, commits(len(srcCommits)))
+
+	var hookErr error
+	for _, tipName := range append([]string{dstName}, descendants...) {
+		pushErr := core.PushTip(repo, tipName, context)
+		if core.IsHookError(pushErr) {
+			if hookErr == nil {
+				hookErr = pushErr
+			}
+			continue
+		}
+
+		// Local tips are not pushed, so only a failed push of a remote tip is reported
+		base, _ := config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
+		if remoteName, notRemote := core.RemoteOf(base, config); notRemote == nil && pushErr != nil {
+			context.Logger.Println(pushErr.Error())
+			context.Logger.Printf("Tip '%v' has been folded locally but not on %v.\n", tipName, remoteName)
+		}
+	}
+
+	if err := core.DeleteTip(repo, srcName, context); err != nil {
+		return err
+	}
+
+	// The tips are folded when the pre-push hook fails, only the push is aborted
+	return hookErr
+}
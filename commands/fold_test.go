@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestFoldCommand(t *testing.T) {
+	test.RunOnRemote(t, "IntoBase", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "a", "refs/remotes/origin/master", true)
+		test.WriteFile(repo, true, "a", "a")
+		test.Commit(repo, nil)
+		test.CreateTip(repo, "b", core.RefsTips+"a", true)
+		test.WriteFile(repo, true, "b", "b")
+		b, _ := test.Commit(repo, nil)
+		core.PushTip(repo, "a", context.Context)
+		core.PushTip(repo, "b", context.Context)
+		config, _ := repo.Config()
+		config.SetString("tip.c.base", core.RefsTips+"b")
+
+		err := FoldCommand(repo, "", "", context.Context)
+		assert.Nil(t, err)
+
+		// b is stacked on a, its commits are kept as they are
+		tip, _ := repo.References.Lookup(core.RefsTips + "a")
+		assert.True(t, tip.Target().Equal(b))
+		remoteTip, _ := remote.References.Lookup(core.RefsTips + "a")
+		assert.True(t, remoteTip.Target().Equal(b))
+
+		_, err = repo.References.Lookup(core.RefsTips + "b")
+		assert.NotNil(t, err)
+		_, err = remote.References.Lookup(core.RefsTips + "b")
+		assert.NotNil(t, err)
+
+		head, _ := repo.Head()
+		assert.Equal(t, core.RefsTips+"a", head.Name())
+		cBase, _ := config.LookupString("tip.c.base")
+		assert.Equal(t, core.RefsTips+"a", cBase)
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "IntoAnotherTip", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "a", "refs/heads/master", false)
+		test.CreateTip(repo, "d", "refs/heads/master", false)
+		a := test.CommitFiles(repo, core.RefsTips+"a", map[string]string{"a": "a"})
+		test.CommitFiles(repo, core.RefsTips+"d", map[string]string{"d": "d"})
+
+		err := FoldCommand(repo, "d", "a", context.Context)
+		assert.Nil(t, err)
+
+		tip, _ := repo.References.Lookup(core.RefsTips + "a")
+		commit, _ := repo.LookupCommit(tip.Target())
+		assert.True(t, commit.ParentId(0).Equal(a))
+		tree, _ := commit.Tree()
+		assert.NotNil(t, tree.EntryByName("a"))
+		assert.NotNil(t, tree.EntryByName("d"))
+
+		_, err = repo.References.Lookup(core.RefsTips + "d")
+		assert.NotNil(t, err)
+		assert.Contains(t, context.OutputBuffer.String(), "Folded tip 'd' into 'a' (1 commit)\n")
+	})
+
+	test.RunOnRepo(t, "Conflict", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "a", "refs/heads/master", false)
+		test.CreateTip(repo, "d", "refs/heads/master", false)
+		a := test.CommitFiles(repo, core.RefsTips+"a", map[string]string{"foo": "a"})
+		test.CommitFiles(repo, core.RefsTips+"d", map[string]string{"foo": "d"})
+
+		err := FoldCommand(repo, "d", "a", context.Context)
+		assert.NotNil(t, err)
+
+		tip, _ := repo.References.Lookup(core.RefsTips + "a")
+		assert.True(t, tip.Target().Equal(a))
+		_, err = repo.References.Lookup(core.RefsTips + "d")
+		assert.Nil(t, err)
+	})
+
+	test.RunOnRepo(t, "StackedTipReplayed", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "a", "refs/heads/master", false)
+		test.CommitFiles(repo, core.RefsTips+"a", map[string]string{"a": "a"})
+		test.CreateTip(repo, "d", "refs/heads/master", true)
+		test.WriteFile(repo, true, "d", "d")
+		test.Commit(repo, nil)
+		test.CreateTip(repo, "e", core.RefsTips+"d", true)
+		test.WriteFile(repo, true, "e", "e")
+		test.Commit(repo, nil)
+
+		err := FoldCommand(repo, "d", "a", context.Context)
+		assert.Nil(t, err)
+
+		// e now stands on the folded tip, its tail too
+		a, _ := repo.References.Lookup(core.RefsTips + "a")
+		e, _ := repo.References.Lookup(core.RefsTips + "e")
+		tail, _ := repo.References.Lookup(core.RefsTails + "e")
+		commit, _ := repo.LookupCommit(e.Target())
+		assert.True(t, commit.ParentId(0).Equal(a.Target()))
+		assert.True(t, tail.Target().Equal(a.Target()))
+		commits, _ := core.TipCommits(repo, "e")
+		assert.Equal(t, 1, len(commits))
+
+		// e is still selected, with the files of the three tips
+		head, _ := repo.Head()
+		assert.Equal(t, core.RefsTips+"e", head.Name())
+		tree, _ := commit.Tree()
+		for _, file := range []string{"a", "d", "e"} {
+			assert.NotNil(t, tree.EntryByName(file), file)
+		}
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "NoDestination", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "a", "refs/heads/master", true)
+
+		err := FoldCommand(repo, "", "", context.Context)
+		assert.Equal(t, "Tip 'a' isn't stacked on a tip. Specify the tip to fold it into with --into.", err.Error())
+	})
+}
@@ -0,0 +1,22 @@
+package commands
+
+import (
+	"encoding/json"
+	"github.com/apflieger/tie/model"
+)
+
+// Prints v as json, on a single line
+func printJson(context model.Context, v interface{}) error {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	context.Logger.Println(string(bytes))
+
+	return nil
+}
+
+func machineReadable(context model.Context) bool {
+	return context.Format == model.FormatJson || context.Format == model.FormatPorcelain
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const hunkHelp = `y - stage this hunk
+n - do not stage this hunk
+q - quit; do not stage this hunk or any of the remaining ones
+a - stage this hunk and all later hunks in the file
+d - do not stage this hunk or any of the later hunks in the file
+s - split the current hunk into smaller hunks
+e - manually edit the current hunk
+? - print help`
+
+const editHunkHelp = `# Manual hunk edit mode. Lines starting with '#' are ignored.
+# To remove '-' lines, make them ' ' lines (context).
+# To remove '+' lines, delete them.
+# Removing all the lines aborts the edition.
+`
+
+// Asks which hunks of the working tree should be staged, like git add -p.
+// Returns whether something has been staged.
+func selectHunks(repo *git.Repository, context model.Context) (bool, error) {
+	if context.Prompt == nil {
+		return false, errors.New("Cannot choose the hunks: no terminal available.")
+	}
+
+	files, err := core.WorkdirHunks(repo)
+	if err != nil {
+		return false, err
+	}
+
+	staged := false
+
+	for _, hunks := range files {
+		path := hunks[0].Path
+		chosen := []core.Hunk{}
+		quit := false
+
+		context.Logger.Printf("diff --git a/%v b/%v\n", path, path)
+
+	hunkLoop:
+		for i := 0; i < len(hunks); i++ {
+			hunk := hunks[i]
+			context.Logger.Print(hunk.String())
+
+			options := "y,n,q,a,d,e,?"
+			if len(hunk.Split()) > 1 {
+				options = "y,n,q,a,d,s,e,?"
+			}
+
+			answer, err := context.Prompt(fmt.Sprintf("Stage this hunk (%v/%v) [%v]? ", i+1, len(hunks), options))
+			if err != nil {
+				return staged, err
+			}
+
+			switch strings.TrimSpace(answer) {
+			case "y":
+				chosen = append(chosen, hunk)
+			case "n":
+			case "q":
+				quit = true
+				break hunkLoop
+			case "a":
+				chosen = append(chosen, hunks[i:]...)
+				break hunkLoop
+			case "d":
+				break hunkLoop
+			case "s":
+				split := hunk.Split()
+				if len(split) > 1 {
+					context.Logger.Printf("Split into %v hunks.\n", len(split))
+					hunks = append(hunks[:i], append(split, hunks[i+1:]...)...)
+				} else {
+					context.Logger.Println("This hunk can't be split.")
+				}
+				i--
+			case "e":
+				edited, err := editHunk(repo, hunk, context)
+				if err != nil {
+					context.Logger.Println(err.Error())
+					i--
+				} else {
+					chosen = append(chosen, edited)
+				}
+			default:
+				context.Logger.Println(hunkHelp)
+				i--
+			}
+		}
+
+		if len(chosen) > 0 {
+			if err := core.StageHunks(repo, path, chosen); err != nil {
+				return staged, err
+			}
+			staged = true
+		}
+
+		if quit {
+			break
+		}
+	}
+
+	return staged, nil
+}
+
+func editHunk(repo *git.Repository, hunk core.Hunk, context model.Context) (core.Hunk, error) {
+	editFile := filepath.Join(repo.Path(), "ADD_EDIT.patch")
+	ioutil.WriteFile(editFile, []byte(editHunkHelp+hunk.String()), 0644)
+
+	config, _ := repo.Config()
+	text, err := context.OpenEditor(config, editFile)
+	if err != nil {
+		return hunk, err
+	}
+
+	return core.ParseEditedHunk(hunk, text)
+}
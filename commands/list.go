@@ -83,12 +83,43 @@ func ListCommand(repo *git.Repository, context model.Context, tips, branches, re
 	head, _ := repo.Head()
 	directRef, _ := head.Resolve()
 
+	if machineReadable(context) {
+		return printRefRecords(repo, list, directRef.Name(), context)
+	}
+
 	for _, ref := range list {
 		prefix := "  "
 		if ref == directRef.Name() {
 			prefix = "* "
 		}
-		context.Logger.Println(prefix + ref)
+		line := prefix + ref
+		if tipName, err := core.TipName(ref); err == nil {
+			if title := core.TipTitle(repo, tipName); len(title) > 0 {
+				line += "  " + title
+			}
+		}
+		context.Logger.Println(line)
+	}
+
+	return nil
+}
+
+func printRefRecords(repo *git.Repository, refs []string, selected string, context model.Context) error {
+	records := []core.RefRecord{}
+	for _, ref := range refs {
+		record, err := core.NewRefRecord(repo, ref, selected)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+
+	if context.Format == model.FormatJson {
+		return printJson(context, records)
+	}
+
+	for _, record := range records {
+		context.Logger.Println(record.Porcelain())
 	}
 
 	return nil
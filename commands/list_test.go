@@ -3,6 +3,7 @@ package commands
 import (
 	"bytes"
 	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
 	"github.com/apflieger/tie/test"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/libgit2/git2go.v25"
@@ -146,4 +147,30 @@ func TestListCommand(t *testing.T) {
 		assertRefsList(t, repo, context,
 			core.RefsTips+"test")
 	})
+
+	test.RunOnRepo(t, "JsonFormat", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		setupRefs(repo)
+		head, _ := repo.Head()
+		oid := head.Target().String()
+		context.Format = model.FormatJson
+
+		ListCommand(repo, context.Context, false, true, false, false)
+		assert.Equal(t,
+			`[{"ref":"refs/heads/branch1","kind":"branch","base":"","tail":"","tip":"`+oid+`","ahead":0,"behind":0,"pushed":false,"selected":false},`+
+				`{"ref":"refs/heads/master","kind":"branch","base":"","tail":"","tip":"`+oid+`","ahead":0,"behind":0,"pushed":false,"selected":true}]`+"\n",
+			context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "PorcelainFormat", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		setupRefs(repo)
+		head, _ := repo.Head()
+		oid := head.Target().String()
+		context.Format = model.FormatPorcelain
+
+		ListCommand(repo, context.Context, true, false, false, false)
+		assert.Equal(t,
+			"ref\trefs/tips/tip1\ttip\trefs/remotes/origin/branch2\t-\t"+oid+"\t0\t0\tfalse\tfalse\n"+
+				"ref\trefs/tips/tip2\ttip\trefs/remotes/origin/branch2\t-\t"+oid+"\t0\t0\tfalse\tfalse\n",
+			context.OutputBuffer.String())
+	})
 }
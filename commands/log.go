@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+)
+
+type logOptions struct {
+	// Print the whole commit instead of a single line
+	full  bool
+	stat  bool
+	patch bool
+}
+
+// Commits are separated by a blank line when they take more than one line
+func (options logOptions) multiline() bool {
+	return options.full || options.stat || options.patch
+}
+
+// Prints the commits owned by the tip, from the most recent to the oldest
+func LogCommand(repo *git.Repository, tip string, allTips, full, stat, patch bool, context model.Context) error {
+	options := logOptions{full: full, stat: stat, patch: patch}
+
+	tipNames := core.TipsInOrder(repo)
+	if !allTips {
+		tipName, err := resolveTip(repo, tip, "Not on a tip. Specify the tip to log or use --all-tips.")
+		if err != nil {
+			return err
+		}
+		tipNames = []string{tipName}
+	}
+
+	if machineReadable(context) {
+		return printCommitRecords(repo, tipNames, context)
+	}
+
+	if allTips {
+		return logAllTips(repo, tipNames, options, context)
+	}
+
+	return logTip(repo, tipNames[0], "", options, context)
+}
+
+func printCommitRecords(repo *git.Repository, tipNames []string, context model.Context) error {
+	records := []core.CommitRecord{}
+	for _, tipName := range tipNames {
+		commits, err := core.TipCommits(repo, tipName)
+		if err != nil {
+			return err
+		}
+		for i := len(commits) - 1; i >= 0; i-- {
+			records = append(records, core.NewCommitRecord(core.RefsTips+tipName, commits[i]))
+		}
+	}
+
+	if context.Format == model.FormatJson {
+		return printJson(context, records)
+	}
+
+	for _, record := range records {
+		context.Logger.Println(record.Porcelain())
+	}
+
+	return nil
+}
+
+// Returns the name of the given tip, or of the selected one when tip is empty
+func resolveTip(repo *git.Repository, tip, notOnTip string) (string, error) {
+	tipName := strings.TrimPrefix(tip, core.RefsTips)
+	if len(tipName) == 0 {
+		head, err := repo.Head()
+		if err != nil {
+			return "", errors.New(notOnTip)
+		}
+		var notTip error
+		tipName, notTip = core.TipName(head.Name())
+		if notTip != nil {
+			return "", errors.New(notOnTip)
+		}
+	}
+
+	if _, err := repo.References.Lookup(core.RefsTips + tipName); err != nil {
+		return "", fmt.Errorf("Tip '%v' doesn't exist.", tipName)
+	}
+
+	return tipName, nil
+}
+
+// Prints the commits of every tip, grouped by tip. Stacked tips come after their base.
+func logAllTips(repo *git.Repository, tipNames []string, options logOptions, context model.Context) error {
+	bases := core.TipBases(repo)
+	head, _ := repo.Head()
+
+	for i, tipName := range tipNames {
+		if i > 0 && options.multiline() {
+			context.Logger.Println()
+		}
+
+		prefix := "  "
+		if head != nil && head.Name() == core.RefsTips+tipName {
+			prefix = "* "
+		}
+
+		base := bases[tipName]
+		if baseTip, err := core.TipName(base); err == nil {
+			context.Logger.Printf("%vTip '%v' stacked on tip '%v'\n", prefix, tipName, baseTip)
+		} else {
+			context.Logger.Printf("%vTip '%v' based on '%v'\n", prefix, tipName, base)
+		}
+
+		if err := logTip(repo, tipName, "    ", options, context); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func logTip(repo *git.Repository, tipName, indent string, options logOptions, context model.Context) error {
+	commits, err := core.TipCommits(repo, tipName)
+	if err != nil {
+		return err
+	}
+
+	for i := len(commits) - 1; i >= 0; i-- {
+		text, err := formatCommit(repo, commits[i], options)
+		if err != nil {
+			return err
+		}
+
+		if options.multiline() && i < len(commits)-1 {
+			context.Logger.Println()
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+			if len(line) == 0 {
+				context.Logger.Println()
+			} else {
+				context.Logger.Println(indent + line)
+			}
+		}
+	}
+
+	return nil
+}
+
+func formatCommit(repo *git.Repository, commit *git.Commit, options logOptions) (string, error) {
+	var text string
+
+	if options.full {
+		author := commit.Author()
+		text = fmt.Sprintf("commit %v\nAuthor: %v <%v>\nDate:   %v\n\n",
+			commit.Id(), author.Name, author.Email, author.When.Format("Mon Jan 2 15:04:05 2006 -0700"))
+		for _, line := range strings.Split(strings.TrimRight(commit.Message(), "\n"), "\n") {
+			text += "    " + line + "\n"
+		}
+	} else {
+		text = fmt.Sprintf("%v %v\n", commit.Id().String()[:7], commit.Summary())
+	}
+
+	if !options.stat && !options.patch {
+		return text, nil
+	}
+
+	diff, err := core.CommitDiff(repo, commit)
+	if err != nil {
+		return "", err
+	}
+	defer diff.Free()
+
+	if options.stat {
+		stat, err := core.DiffStat(diff)
+		if err != nil {
+			return "", err
+		}
+		text += "\n" + stat
+	}
+
+	if options.patch {
+		patch, err := core.DiffPatch(diff)
+		if err != nil {
+			return "", err
+		}
+		text += "\n" + patch
+	}
+
+	return text, nil
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"encoding/json"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+	"testing"
+)
+
+func TestLogCommand(t *testing.T) {
+	test.RunOnRepo(t, "OneLine", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, true, "a", "a")
+		a, _ := test.Commit(repo, &test.CommitParams{Message: "a"})
+		test.WriteFile(repo, true, "b", "b")
+		b, _ := test.Commit(repo, &test.CommitParams{Message: "b\n\nbody"})
+
+		err := LogCommand(repo, "", false, false, false, false, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t, b.String()[:7]+" b\n"+a.String()[:7]+" a\n", context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "Full", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		test.WriteFile(repo, true, "a", "a")
+		a, _ := test.Commit(repo, &test.CommitParams{Refname: "refs/tips/test", Message: "a\n\nbody"})
+
+		err := LogCommand(repo, "test", false, true, false, false, context.Context)
+		assert.Nil(t, err)
+		lines := strings.Split(context.OutputBuffer.String(), "\n")
+		assert.Equal(t, "commit "+a.String(), lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "Author: "))
+		assert.True(t, strings.HasPrefix(lines[2], "Date:   "))
+		assert.Equal(t, []string{"", "    a", "    ", "    body", ""}, lines[3:])
+	})
+
+	test.RunOnRepo(t, "Patch", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, true, "a", "a")
+		test.Commit(repo, &test.CommitParams{Message: "a"})
+
+		err := LogCommand(repo, "refs/tips/test", false, false, true, true, context.Context)
+		assert.Nil(t, err)
+		assert.Contains(t, context.OutputBuffer.String(), " a | 1 +\n")
+		assert.Contains(t, context.OutputBuffer.String(), "diff --git a/a b/a\n")
+	})
+
+	test.RunOnRepo(t, "AllTips", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "a", "refs/heads/master", true)
+		test.WriteFile(repo, true, "a", "a")
+		a, _ := test.Commit(repo, &test.CommitParams{Message: "a"})
+		test.CreateTip(repo, "b", "refs/tips/a", true)
+		test.WriteFile(repo, true, "b", "b")
+		b, _ := test.Commit(repo, &test.CommitParams{Message: "b"})
+
+		err := LogCommand(repo, "", true, false, false, false, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t,
+			"  Tip 'a' based on 'refs/heads/master'\n"+
+				"    "+a.String()[:7]+" a\n"+
+				"* Tip 'b' stacked on tip 'a'\n"+
+				"    "+b.String()[:7]+" b\n",
+			context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "NotOnTip", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		err := LogCommand(repo, "", false, false, false, false, context.Context)
+		assert.Equal(t, "Not on a tip. Specify the tip to log or use --all-tips.", err.Error())
+
+		err = LogCommand(repo, "missing", false, false, false, false, context.Context)
+		assert.Equal(t, "Tip 'missing' doesn't exist.", err.Error())
+	})
+
+	test.RunOnRepo(t, "UnbornHead", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		repo.References.CreateSymbolic("HEAD", "refs/heads/unborn", true, "")
+
+		err := LogCommand(repo, "", false, false, false, false, context.Context)
+		assert.Equal(t, "Not on a tip. Specify the tip to log or use --all-tips.", err.Error())
+	})
+
+	test.RunOnRepo(t, "JsonFormat", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, true, "a", "a")
+		a, _ := test.Commit(repo, &test.CommitParams{Message: "a\n\nbody"})
+		context.Format = model.FormatJson
+
+		err := LogCommand(repo, "", false, false, false, false, context.Context)
+		assert.Nil(t, err)
+
+		records := []core.CommitRecord{}
+		json.Unmarshal(context.OutputBuffer.Bytes(), &records)
+		assert.Equal(t, 1, len(records))
+		assert.Equal(t, "refs/tips/test", records[0].Ref)
+		assert.Equal(t, a.String(), records[0].Oid)
+		assert.Equal(t, "a", records[0].Summary)
+		assert.Equal(t, "a\n\nbody", records[0].Message)
+	})
+}
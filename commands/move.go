@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+)
+
+// Cherry-picks the commit on top of the tip to, then removes it from the tip that owns it
+func MoveCommand(repo *git.Repository, commit, to string, context model.Context) error {
+	if len(to) == 0 {
+		return errors.New("The destination tip is missing. Use --to.")
+	}
+
+	dstName := strings.TrimPrefix(to, core.RefsTips)
+	dst, err := repo.References.Lookup(core.RefsTips + dstName)
+	if err != nil {
+		return fmt.Errorf("Tip '%v' doesn't exist.", dstName)
+	}
+
+	object, err := repo.RevparseSingle(commit)
+	if err != nil {
+		return err
+	}
+	oid := object.Id()
+
+	srcName, srcCommits, err := owningTip(repo, oid)
+	if err != nil {
+		return err
+	}
+
+	if srcName == dstName {
+		return fmt.Errorf("Commit '%v' is already part of tip '%v'.", commit, dstName)
+	}
+
+	// A tip stacked on the source already has the commit in its history
+	if inHistory, _ := repo.DescendantOf(dst.Target(), oid); inHistory || dst.Target().Equal(oid) {
+		return fmt.Errorf("Commit '%v' is already in the history of tip '%v'.", commit, dstName)
+	}
+
+	removal := core.PickSteps(srcCommits)
+	for i := range removal {
+		if removal[i].Commit == oid.String() {
+			removal[i].Action = core.RewriteDrop
+		}
+	}
+
+	tail, err := repo.References.Lookup(core.RefsTails + srcName)
+	if err != nil {
+		return err
+	}
+
+	rewrite := &core.Rewrite{
+		Tip:  dstName,
+		Head: dst.Target().String(),
+		Todo: []core.RewriteStep{{Action: core.RewritePick, Commit: oid.String()}},
+		Next: &core.Rewrite{
+			Tip:  srcName,
+			Head: tail.Target().String(),
+			Todo: removal,
+		},
+		Command: "move",
+	}
+
+	// The tips stacked on both tips are replayed on their updated base
+	last := rewrite.Next
+	for _, tipName := range append(core.TipDescendants(repo, srcName), core.TipDescendants(repo, dstName)...) {
+		commits, err := core.TipCommits(repo, tipName)
+		if err != nil {
+			return err
+		}
+		last.Next = &core.Rewrite{
+			Tip:  tipName,
+			Todo: core.PickSteps(commits),
+		}
+		last = last.Next
+	}
+
+	return core.StartRewrite(repo, rewrite, context)
+}
+
+// Returns the tip that owns the commit, the selected one first, and its commits
+func owningTip(repo *git.Repository, oid *git.Oid) (string, []*git.Commit, error) {
+	tipNames := core.TipsInOrder(repo)
+
+	if head, err := repo.Head(); err == nil {
+		if selected, err := core.TipName(head.Name()); err == nil {
+			tipNames = append([]string{selected}, tipNames...)
+		}
+	}
+
+	for _, tipName := range tipNames {
+		commits, err := core.TipCommits(repo, tipName)
+		if err != nil {
+			continue
+		}
+		for _, commit := range commits {
+			if commit.Id().Equal(oid) {
+				return tipName, commits, nil
+			}
+		}
+	}
+
+	return "", nil, fmt.Errorf("Commit '%v' is not part of any tip.", oid.String()[:7])
+}
+
+func MoveContinueCommand(repo *git.Repository, context model.Context) error {
+	return core.ContinueRewrite(repo, context)
+}
+
+func MoveAbortCommand(repo *git.Repository) error {
+	return core.AbortRewrite(repo)
+}
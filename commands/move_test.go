@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestMoveCommand(t *testing.T) {
+	// Creates the tip b with the commit z, and the tip a, selected, with the commits x and y
+	setup := func(repo *git.Repository, xFile, zFile string) (x, z *git.Oid) {
+		test.CreateTip(repo, "b", "refs/remotes/origin/master", false)
+		z = test.CommitFiles(repo, core.RefsTips+"b", map[string]string{zFile: "z"})
+
+		test.CreateTip(repo, "a", "refs/remotes/origin/master", true)
+		test.WriteFile(repo, true, xFile, "x")
+		x, _ = test.Commit(repo, &test.CommitParams{Message: "x"})
+		test.WriteFile(repo, true, "y", "y")
+		test.Commit(repo, &test.CommitParams{Message: "y"})
+		return x, z
+	}
+
+	assertMoved := func(t *testing.T, repo, remote *git.Repository, z *git.Oid) {
+		b, _ := repo.References.Lookup(core.RefsTips + "b")
+		top, _ := repo.LookupCommit(b.Target())
+		assert.Equal(t, "x", top.Summary())
+		assert.True(t, top.ParentId(0).Equal(z))
+
+		commits, _ := core.TipCommits(repo, "a")
+		assert.Equal(t, 1, len(commits))
+		assert.Equal(t, "y", commits[0].Summary())
+
+		for _, tipName := range []string{"a", "b"} {
+			tip, _ := repo.References.Lookup(core.RefsTips + tipName)
+			remoteTip, err := remote.References.Lookup(core.RefsTips + tipName)
+			assert.Nil(t, err, tipName)
+			assert.True(t, remoteTip.Target().Equal(tip.Target()), tipName)
+		}
+	}
+
+	test.RunOnRemote(t, "Move", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		x, z := setup(repo, "x", "z")
+
+		err := MoveCommand(repo, x.String(), "b", context.Context)
+		assert.Nil(t, err)
+
+		assertMoved(t, repo, remote, z)
+		// a is still selected and doesn't have x anymore
+		head, _ := repo.Head()
+		assert.Equal(t, core.RefsTips+"a", head.Name())
+		_, err = ioutil.ReadFile(filepath.Join(repo.Workdir(), "x"))
+		assert.NotNil(t, err)
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRemote(t, "ConflictContinue", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		x, z := setup(repo, "foo", "foo")
+
+		err := MoveCommand(repo, x.String(), core.RefsTips+"b", context.Context)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "Resolve it and run 'tie move continue'.")
+		}
+		assert.True(t, core.RewriteInProgress(repo))
+
+		test.WriteFile(repo, true, "foo", "resolved")
+		err = MoveContinueCommand(repo, context.Context)
+		assert.Nil(t, err)
+
+		assert.False(t, core.RewriteInProgress(repo))
+		assertMoved(t, repo, remote, z)
+	})
+
+	test.RunOnRemote(t, "ConflictAbort", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		x, z := setup(repo, "foo", "foo")
+		a, _ := repo.References.Lookup(core.RefsTips + "a")
+
+		MoveCommand(repo, x.String(), "b", context.Context)
+		err := MoveAbortCommand(repo)
+		assert.Nil(t, err)
+
+		assert.False(t, core.RewriteInProgress(repo))
+		b, _ := repo.References.Lookup(core.RefsTips + "b")
+		assert.True(t, b.Target().Equal(z))
+		a2, _ := repo.References.Lookup(core.RefsTips + "a")
+		assert.True(t, a2.Target().Equal(a.Target()))
+	})
+
+	test.RunOnRemote(t, "SourceConflictAbort", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "b", "refs/remotes/origin/master", false)
+		z := test.CommitFiles(repo, core.RefsTips+"b", map[string]string{"z": "z"})
+
+		// y changes the file added by x, so a conflicts without x
+		test.CreateTip(repo, "a", "refs/remotes/origin/master", true)
+		test.WriteFile(repo, true, "bar", "x")
+		x, _ := test.Commit(repo, &test.CommitParams{Message: "x"})
+		test.WriteFile(repo, true, "bar", "y")
+		y, _ := test.Commit(repo, &test.CommitParams{Message: "y"})
+
+		err := MoveCommand(repo, x.String(), "b", context.Context)
+		assert.NotNil(t, err)
+		assert.True(t, core.RewriteInProgress(repo))
+
+		err = MoveAbortCommand(repo)
+		assert.Nil(t, err)
+
+		// x is back in a only
+		assert.False(t, core.RewriteInProgress(repo))
+		b, _ := repo.References.Lookup(core.RefsTips + "b")
+		assert.True(t, b.Target().Equal(z))
+		a, _ := repo.References.Lookup(core.RefsTips + "a")
+		assert.True(t, a.Target().Equal(y))
+	})
+
+	test.RunOnRemote(t, "StackedOnSource", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		x, z := setup(repo, "x", "z")
+		test.CreateTip(repo, "c", core.RefsTips+"a", true)
+		test.WriteFile(repo, true, "c", "c")
+		test.Commit(repo, &test.CommitParams{Message: "c"})
+
+		err := MoveCommand(repo, x.String(), "b", context.Context)
+		assert.Nil(t, err)
+		assertMoved(t, repo, remote, z)
+
+		// c follows a
+		a, _ := repo.References.Lookup(core.RefsTips + "a")
+		c, _ := repo.References.Lookup(core.RefsTips + "c")
+		cCommit, _ := repo.LookupCommit(c.Target())
+		assert.True(t, cCommit.ParentId(0).Equal(a.Target()))
+		cTail, _ := repo.References.Lookup(core.RefsTails + "c")
+		assert.True(t, cTail.Target().Equal(a.Target()))
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "NotInATip", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		test.CreateTip(repo, "b", "refs/heads/master", false)
+
+		err := MoveCommand(repo, head.Target().String(), "b", context.Context)
+		assert.Equal(t, "Commit '"+head.Target().String()[:7]+"' is not part of any tip.", err.Error())
+	})
+	test.RunOnRepo(t, "StackedDestination", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "a", "refs/heads/master", true)
+		test.WriteFile(repo, true, "x", "x")
+		x, _ := test.Commit(repo, &test.CommitParams{Message: "x"})
+		test.CreateTip(repo, "c", core.RefsTips+"a", false)
+
+		err := MoveCommand(repo, x.String(), "c", context.Context)
+		assert.Equal(t, "Commit '"+x.String()+"' is already in the history of tip 'c'.", err.Error())
+		assert.False(t, core.RewriteInProgress(repo))
+	})
+}
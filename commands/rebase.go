@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+)
+
+// Replays the commits of the tip on newBase, which becomes the base of the tip
+func RebaseCommand(repo *git.Repository, newBase, tip string, context model.Context) error {
+	if len(newBase) == 0 {
+		return errors.New("The new base is missing. Run 'tie rebase --onto <newbase>'.")
+	}
+
+	tipName, err := resolveTip(repo, tip, "Not on a tip. Specify the tip to rebase.")
+	if err != nil {
+		return err
+	}
+
+	base, err := core.Dwim(repo, newBase)
+	if err != nil {
+		// Branches given by their short name
+		var notBranch error
+		base, notBranch = repo.References.Dwim(newBase)
+		if notBranch != nil {
+			return err
+		}
+	}
+
+	// The base can't depend on the tip
+	if baseTip, err := core.TipName(base.Name()); err == nil {
+		if baseTip == tipName {
+			return fmt.Errorf("Tip '%v' can't be based on itself.", tipName)
+		}
+		for _, descendant := range core.TipDescendants(repo, tipName) {
+			if descendant == baseTip {
+				return fmt.Errorf("Tip '%v' can't be based on '%v', which is stacked on it.", tipName, baseTip)
+			}
+		}
+	}
+
+	commits, err := core.TipCommits(repo, tipName)
+	if err != nil {
+		return err
+	}
+
+	onto, err := base.Resolve()
+	if err != nil {
+		return err
+	}
+
+	return core.StartRewrite(repo, &core.Rewrite{
+		Tip:     tipName,
+		Todo:    core.PickSteps(commits),
+		Head:    onto.Target().String(),
+		Tail:    onto.Target().String(),
+		Base:    base.Name(),
+		Command: "rebase",
+	}, context)
+}
+
+func RebaseContinueCommand(repo *git.Repository, context model.Context) error {
+	return core.ContinueRewrite(repo, context)
+}
+
+func RebaseAbortCommand(repo *git.Repository) error {
+	return core.AbortRewrite(repo)
+}
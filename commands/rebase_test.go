@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRebaseCommand(t *testing.T) {
+	// Creates the branch other and a tip on master, changing foo on both sides
+	setup := func(repo *git.Repository, otherFoo string) (other, tip *git.Oid) {
+		head, _ := repo.Head()
+		repo.References.Create("refs/heads/other", head.Target(), false, "")
+		other = test.CommitFiles(repo, "refs/heads/other", map[string]string{"foo": otherFoo})
+
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, true, "foo", "tip")
+		tip, _ = test.Commit(repo, nil)
+		return other, tip
+	}
+
+	assertBase := func(t *testing.T, repo *git.Repository, base string, tail *git.Oid) {
+		config, _ := repo.Config()
+		baseRefName, _ := config.LookupString("tip.test.base")
+		assert.Equal(t, base, baseRefName)
+		tailRef, _ := repo.References.Lookup(core.RefsTails + "test")
+		assert.True(t, tailRef.Target().Equal(tail))
+	}
+
+	test.RunOnRepo(t, "Onto", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		repo.References.Create("refs/heads/other", head.Target(), false, "")
+		other := test.CommitFiles(repo, "refs/heads/other", map[string]string{"bar": "bar"})
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, true, "foo", "foo")
+		test.Commit(repo, nil)
+
+		err := RebaseCommand(repo, "other", "", context.Context)
+		assert.Nil(t, err)
+
+		tip, _ := repo.References.Lookup(core.RefsTips + "test")
+		commit, _ := repo.LookupCommit(tip.Target())
+		assert.True(t, commit.ParentId(0).Equal(other))
+		assertBase(t, repo, "refs/heads/other", other)
+
+		bar, _ := ioutil.ReadFile(filepath.Join(repo.Workdir(), "bar"))
+		assert.Equal(t, "bar", string(bar))
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "ConflictContinue", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		other, _ := setup(repo, "other")
+
+		err := RebaseCommand(repo, "refs/heads/other", "test", context.Context)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "Resolve it and run 'tie rebase continue'.")
+		}
+		assert.True(t, core.RewriteInProgress(repo))
+
+		context.OutputBuffer.Reset()
+		StatusCommand(repo, context.Context)
+		assert.Contains(t, context.OutputBuffer.String(), "Rebase in progress. Run 'tie rebase continue' or 'tie rebase abort'.\n")
+
+		test.WriteFile(repo, true, "foo", "resolved")
+		err = RebaseContinueCommand(repo, context.Context)
+		assert.Nil(t, err)
+
+		assert.False(t, core.RewriteInProgress(repo))
+		assertBase(t, repo, "refs/heads/other", other)
+		foo, _ := ioutil.ReadFile(filepath.Join(repo.Workdir(), "foo"))
+		assert.Equal(t, "resolved", string(foo))
+	})
+
+	test.RunOnRepo(t, "ConflictAbort", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		_, orig := setup(repo, "other")
+		head, _ := repo.References.Lookup("refs/heads/master")
+
+		RebaseCommand(repo, "refs/heads/other", "", context.Context)
+		err := RebaseAbortCommand(repo)
+		assert.Nil(t, err)
+
+		assert.False(t, core.RewriteInProgress(repo))
+		tip, _ := repo.References.Lookup(core.RefsTips + "test")
+		assert.True(t, tip.Target().Equal(orig))
+		assertBase(t, repo, "refs/heads/master", head.Target())
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "OntoStackedTip", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "a", "refs/heads/master", true)
+		test.CreateTip(repo, "b", core.RefsTips+"a", true)
+
+		err := RebaseCommand(repo, "b", "a", context.Context)
+		assert.Equal(t, "Tip 'a' can't be based on 'b', which is stacked on it.", err.Error())
+
+		err = RebaseCommand(repo, "a", "a", context.Context)
+		assert.Equal(t, "Tip 'a' can't be based on itself.", err.Error())
+	})
+}
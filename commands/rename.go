@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"errors"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+)
+
+func RenameCommand(repo *git.Repository, oldName, newName string, context model.Context) error {
+	if core.RewriteInProgress(repo) {
+		return errors.New("A rewrite is in progress. Run 'tie rewrite continue' or 'tie rewrite abort' before renaming.")
+	}
+
+	oldName = strings.TrimPrefix(oldName, core.RefsTips)
+	newName = strings.Trim(strings.TrimPrefix(newName, core.RefsTips), " ")
+
+	if len(newName) == 0 {
+		return errors.New("Name of the tip can't be empty.")
+	}
+
+	return core.RenameTip(repo, oldName, newName, context)
+}
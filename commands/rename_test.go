@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestRenameCommand(t *testing.T) {
+	test.RunOnRepo(t, "RefNames", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "old", "refs/heads/master", true)
+
+		err := RenameCommand(repo, core.RefsTips+"old", core.RefsTips+"new", context.Context)
+		assert.Nil(t, err)
+		head, _ := repo.Head()
+		assert.Equal(t, core.RefsTips+"new", head.Name())
+	})
+
+	test.RunOnRepo(t, "EmptyName", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "old", "refs/heads/master", true)
+
+		err := RenameCommand(repo, "old", " ", context.Context)
+		assert.Equal(t, "Name of the tip can't be empty.", err.Error())
+	})
+
+	test.RunOnRepo(t, "UnknownTip", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		err := RenameCommand(repo, "missing", "new", context.Context)
+		assert.Equal(t, "Tip 'missing' doesn't exist.", err.Error())
+	})
+}
@@ -1,34 +1,347 @@
 package commands
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"github.com/apflieger/tie/core"
 	"github.com/apflieger/tie/model"
 	"gopkg.in/libgit2/git2go.v25"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
 )
 
+const rewriteTodoFile = "TIE_REWRITE_TODO"
+
+const rewriteTodoHelp = `
+# Rewrite %v onto %v
+#
+# Commands:
+# p, pick = use commit
+# r, reword = use commit, but edit the commit message
+# e, edit = use commit, but stop for amending
+# s, squash = use commit, but meld into previous commit
+# f, fixup = like "squash", but discard this commit's log message
+# d, drop = remove commit
+#
+# These lines can be re-ordered; they are executed from top to bottom.
+# If you remove a line here THAT COMMIT WILL BE LOST.
+# However, if you remove everything, the rewrite will be aborted.
+`
+
+var rewriteActions = map[string]string{
+	"p":                core.RewritePick,
+	core.RewritePick:   core.RewritePick,
+	"r":                core.RewriteReword,
+	core.RewriteReword: core.RewriteReword,
+	"e":                core.RewriteEdit,
+	core.RewriteEdit:   core.RewriteEdit,
+	"s":                core.RewriteSquash,
+	core.RewriteSquash: core.RewriteSquash,
+	"f":                core.RewriteFixup,
+	core.RewriteFixup:  core.RewriteFixup,
+	"d":                core.RewriteDrop,
+	core.RewriteDrop:   core.RewriteDrop,
+}
+
+func RewriteStartCommand(repo *git.Repository, context model.Context) error {
+	tipName, err := resolveTip(repo, "", "Not on a tip. Only tips can be rewritten.")
+	if err != nil {
+		return err
+	}
+
+	if core.RewriteInProgress(repo) {
+		return errors.New("A rewrite is already in progress. Run 'tie rewrite continue' or 'tie rewrite abort'.")
+	}
+
+	commits, err := core.TipCommits(repo, tipName)
+	if err != nil {
+		return err
+	}
+
+	if len(commits) == 0 {
+		return fmt.Errorf("Tip '%v' has no commit to rewrite.", tipName)
+	}
+
+	tail, _ := repo.References.Lookup(core.RefsTails + tipName)
+
+	todo := new(bytes.Buffer)
+	for _, commit := range commits {
+		todo.WriteString(fmt.Sprintf("%v %v %v\n", core.RewritePick, commit.Id().String()[:7], commit.Summary()))
+	}
+	todo.WriteString(fmt.Sprintf(rewriteTodoHelp, tipName, tail.Target().String()[:7]))
+
+	todoFile := filepath.Join(repo.Path(), rewriteTodoFile)
+	ioutil.WriteFile(todoFile, todo.Bytes(), 0644)
+
+	config, _ := repo.Config()
+	editedTodo, err := context.OpenEditor(config, todoFile)
+	if err != nil {
+		return err
+	}
+
+	steps, err := parseRewriteTodo(repo, editedTodo)
+	if err != nil {
+		return err
+	}
+
+	if len(steps) == 0 {
+		context.Logger.Println("Nothing to do. Rewrite aborted.")
+		return nil
+	}
+
+	rewrite := &core.Rewrite{
+		Tip:  tipName,
+		Head: tail.Target().String(),
+		Todo: steps,
+	}
+
+	return core.StartRewrite(repo, rewrite, context)
+}
+
+func parseRewriteTodo(repo *git.Repository, todo string) ([]core.RewriteStep, error) {
+	steps := []core.RewriteStep{}
+
+	for _, line := range strings.Split(todo, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Invalid rewrite line '%v'.", line)
+		}
+
+		action, known := rewriteActions[fields[0]]
+		if !known {
+			return nil, fmt.Errorf("Unknown rewrite action '%v'.", fields[0])
+		}
+
+		object, err := repo.RevparseSingle(fields[1])
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, core.RewriteStep{
+			Action: action,
+			Commit: object.Id().String(),
+			Edit:   action == core.RewriteReword || action == core.RewriteSquash,
+		})
+	}
+
+	return steps, nil
+}
+
+func RewriteContinueCommand(repo *git.Repository, context model.Context) error {
+	return core.ContinueRewrite(repo, context)
+}
+
+func RewriteAbortCommand(repo *git.Repository) error {
+	return core.AbortRewrite(repo)
+}
+
 func AmendCommand(repo *git.Repository, commitMessage string, context model.Context) error {
+	if !context.NoVerify {
+		if err := core.RunHook(repo, core.HookPreCommit, ""); err != nil {
+			return err
+		}
+	}
+
 	head, headCommit, tree := core.PrepareCommit(repo)
 
 	committer, _ := repo.DefaultSignature()
 
-	if commitMessage == model.OptionMissing {
-		commitMessage = headCommit.Message()
+	var err error
+	switch commitMessage {
+	case model.OptionMissing:
+		commitMessage, err = editCommitMessage(repo, headCommit.Message(), false, context, "commit", headCommit.Id().String())
+	case model.OptionWithoutValue:
+		commitMessage, err = editCommitMessage(repo, headCommit.Message(), true, context, "commit", headCommit.Id().String())
+	default:
+		commitMessage, err = editCommitMessage(repo, commitMessage, false, context, "message")
 	}
 
-	if commitMessage == model.OptionWithoutValue {
-		config, _ := repo.Config()
-		commitEditMsgFile := filepath.Join(repo.Path(), "COMMIT_EDITMSG")
-		ioutil.WriteFile(commitEditMsgFile, []byte(headCommit.Message()), 0644)
-
-		commitMessage, _ = context.OpenEditor(config, commitEditMsgFile)
+	if err != nil {
+		return err
 	}
 
-	_, err := headCommit.Amend(head.Name(), headCommit.Author(), committer, core.FormatCommitMessage(commitMessage), tree)
+	_, err = core.AmendCommit(repo, core.CommitSigner(repo), head.Name(), headCommit, headCommit.Author(), committer, core.FormatCommitMessage(commitMessage), tree)
+	if err != nil {
+		return err
+	}
 
 	tipName, _ := core.TipName(head.Name())
-	core.PushTip(repo, tipName, context)
+	if err := core.PushTip(repo, tipName, context); core.IsHookError(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Returns the name of the selected tip and the steps that replay it as is
+func tipSteps(repo *git.Repository) (string, []core.RewriteStep, error) {
+	tipName, err := resolveTip(repo, "", "Not on a tip. Only tips can be rewritten.")
+	if err != nil {
+		return "", nil, err
+	}
+
+	commits, err := core.TipCommits(repo, tipName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tipName, core.PickSteps(commits), nil
+}
+
+// Returns the position of the commit designated by spec in steps
+func findStep(repo *git.Repository, tipName string, steps []core.RewriteStep, spec string) (int, error) {
+	object, err := repo.RevparseSingle(spec)
+	if err != nil {
+		return -1, err
+	}
+
+	for i, step := range steps {
+		if step.Commit == object.Id().String() {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("Commit '%v' is not part of tip '%v'.", spec, tipName)
+}
+
+func rewriteTip(repo *git.Repository, tipName string, steps []core.RewriteStep, context model.Context) error {
+	tail, _ := repo.References.Lookup(core.RefsTails + tipName)
+
+	rewrite := &core.Rewrite{
+		Tip:  tipName,
+		Head: tail.Target().String(),
+		Todo: steps,
+	}
+
+	return core.StartRewrite(repo, rewrite, context)
+}
+
+func RewriteDropCommand(repo *git.Repository, commit string, context model.Context) error {
+	tipName, steps, err := tipSteps(repo)
+	if err != nil {
+		return err
+	}
+
+	i, err := findStep(repo, tipName, steps, commit)
+	if err != nil {
+		return err
+	}
+
+	steps[i].Action = core.RewriteDrop
+
+	return rewriteTip(repo, tipName, steps, context)
+}
+
+func RewriteFixupCommand(repo *git.Repository, commit string, context model.Context) error {
+	tipName, steps, err := tipSteps(repo)
+	if err != nil {
+		return err
+	}
+
+	i, err := findStep(repo, tipName, steps, commit)
+	if err != nil {
+		return err
+	}
+
+	steps[i].Action = core.RewriteFixup
+
+	return rewriteTip(repo, tipName, steps, context)
+}
+
+// Squashes the commits of revRange. A single commit is squashed into the previous one.
+// The messages are concatenated unless a message is given.
+func RewriteSquashCommand(repo *git.Repository, revRange, message string, context model.Context) error {
+	tipName, steps, err := tipSteps(repo)
+	if err != nil {
+		return err
+	}
+
+	from, to := -1, -1
+
+	bounds := strings.SplitN(revRange, "..", 2)
+	if len(bounds) == 2 {
+		// a..b excludes a, which receives the squashed commits
+		if from, err = findStep(repo, tipName, steps, bounds[0]); err != nil {
+			return err
+		}
+		if to, err = findStep(repo, tipName, steps, bounds[1]); err != nil {
+			return err
+		}
+		from++
+	} else {
+		if from, err = findStep(repo, tipName, steps, revRange); err != nil {
+			return err
+		}
+		to = from
+	}
+
+	if from > to {
+		return fmt.Errorf("Invalid range '%v'.", revRange)
+	}
+
+	for i := from; i <= to; i++ {
+		steps[i].Action = core.RewriteSquash
+	}
+
+	if message != model.OptionMissing {
+		steps[to].Message = message
+	}
+
+	return rewriteTip(repo, tipName, steps, context)
+}
+
+func RewriteRewordCommand(repo *git.Repository, commit, message string, context model.Context) error {
+	tipName, steps, err := tipSteps(repo)
+	if err != nil {
+		return err
+	}
+
+	i, err := findStep(repo, tipName, steps, commit)
+	if err != nil {
+		return err
+	}
+
+	steps[i].Action = core.RewriteReword
+
+	if message == model.OptionMissing || message == model.OptionWithoutValue {
+		steps[i].Edit = true
+	} else {
+		steps[i].Message = message
+	}
+
+	return rewriteTip(repo, tipName, steps, context)
+}
+
+// Moves commit right before the commit designated by before
+func RewriteMoveCommand(repo *git.Repository, commit, before string, context model.Context) error {
+	tipName, steps, err := tipSteps(repo)
+	if err != nil {
+		return err
+	}
+
+	from, err := findStep(repo, tipName, steps, commit)
+	if err != nil {
+		return err
+	}
+
+	to, err := findStep(repo, tipName, steps, before)
+	if err != nil {
+		return err
+	}
+
+	step := steps[from]
+	steps = append(steps[:from], steps[from+1:]...)
+	if from < to {
+		to--
+	}
+	steps = append(steps[:to], append([]core.RewriteStep{step}, steps[to:]...)...)
 
-	return err
+	return rewriteTip(repo, tipName, steps, context)
 }
@@ -8,6 +8,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/libgit2/git2go.v25"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -96,4 +98,324 @@ func TestRewriteCommand(t *testing.T) {
 		// The commit message should be the same but formatted
 		assert.Equal(t, "Commit message from mocked editor\n", headCommit.Message())
 	})
+
+	test.RunOnRemote(t, "ReorderAndDrop", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
+
+		test.WriteFile(repo, true, "a", "a")
+		a, _ := test.Commit(repo, &test.CommitParams{Message: "a"})
+		test.WriteFile(repo, true, "b", "b")
+		test.Commit(repo, &test.CommitParams{Message: "b"})
+		test.WriteFile(repo, true, "c", "c")
+		c, _ := test.Commit(repo, &test.CommitParams{Message: "c"})
+
+		// Put c before a and remove b
+		context.OpenEditor = func(config *git.Config, file string) (string, error) {
+			return fmt.Sprintf("pick %v\npick %v\n", c.String(), a.String()), nil
+		}
+
+		err := RewriteStartCommand(repo, context.Context)
+		assert.Nil(t, err)
+
+		head, _ := repo.Head()
+		assert.Equal(t, core.RefsTips+"test", head.Name())
+		headCommit, _ := repo.LookupCommit(head.Target())
+		assert.Equal(t, "a", headCommit.Message())
+		assert.Equal(t, "c", headCommit.Parent(0).Message())
+
+		// b should have been removed from the working tree
+		_, err = os.Stat(filepath.Join(repo.Workdir(), "b"))
+		assert.True(t, os.IsNotExist(err))
+		test.StatusClean(t, repo)
+
+		// The rewrite should be over
+		assert.False(t, core.RewriteInProgress(repo))
+
+		// We expect the tip to be pushed on origin
+		remoteTip, err := remote.References.Lookup(core.RefsTips + "test")
+		if assert.Nil(t, err) {
+			assert.True(t, remoteTip.Target().Equal(head.Target()))
+		}
+	})
+
+	test.RunOnRepo(t, "SquashAndReword", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		master, _ := repo.References.Lookup("refs/heads/master")
+
+		test.WriteFile(repo, true, "a", "a")
+		a, _ := test.Commit(repo, &test.CommitParams{Message: "a"})
+		test.WriteFile(repo, true, "b", "b")
+		b, _ := test.Commit(repo, &test.CommitParams{Message: "b"})
+		test.WriteFile(repo, true, "c", "c")
+		c, _ := test.Commit(repo, &test.CommitParams{Message: "c"})
+
+		var squashMessage string
+		context.OpenEditor = func(config *git.Config, file string) (string, error) {
+			if filepath.Base(file) == "TIE_REWRITE_TODO" {
+				return fmt.Sprintf("pick %v\nsquash %v\nreword %v\n", a, b, c), nil
+			}
+			bytes, _ := ioutil.ReadFile(file)
+			if len(squashMessage) == 0 {
+				squashMessage = string(bytes)
+				return "a and b", nil
+			}
+			return "c reworded", nil
+		}
+
+		err := RewriteStartCommand(repo, context.Context)
+		assert.Nil(t, err)
+
+		assert.Equal(t, "a\nb", squashMessage)
+
+		head, _ := repo.Head()
+		headCommit, _ := repo.LookupCommit(head.Target())
+		assert.Equal(t, "c reworded\n", headCommit.Message())
+		assert.Equal(t, "a and b\n", headCommit.Parent(0).Message())
+		assert.True(t, headCommit.Parent(0).Parent(0).Id().Equal(master.Target()))
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "ConflictContinue", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		master, _ := repo.References.Lookup("refs/heads/master")
+
+		test.WriteFile(repo, true, "foo", "line1")
+		test.Commit(repo, &test.CommitParams{Message: "a"})
+		test.WriteFile(repo, true, "foo", "line2")
+		b, _ := test.Commit(repo, &test.CommitParams{Message: "b"})
+
+		// Dropping a makes b conflict
+		context.OpenEditor = func(config *git.Config, file string) (string, error) {
+			return "pick " + b.String(), nil
+		}
+
+		err := RewriteStartCommand(repo, context.Context)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Conflict while rewriting "+b.String()[:7]+". Resolve it and run 'tie rewrite continue'.", err.Error())
+		}
+		assert.True(t, core.RewriteInProgress(repo))
+
+		index, _ := repo.Index()
+		_, err = index.GetConflict("foo")
+		assert.Nil(t, err, "File foo should be in conflict")
+
+		// Continuing without resolving the conflict fails
+		err = RewriteContinueCommand(repo, context.Context)
+		assert.NotNil(t, err)
+
+		// Resolve the conflict
+		test.WriteFile(repo, true, "foo", "line2")
+
+		err = RewriteContinueCommand(repo, context.Context)
+		assert.Nil(t, err)
+		assert.False(t, core.RewriteInProgress(repo))
+
+		// The tip should have one commit on top of master
+		head, _ := repo.Head()
+		assert.Equal(t, core.RefsTips+"test", head.Name())
+		headCommit, _ := repo.LookupCommit(head.Target())
+		assert.Equal(t, "b", headCommit.Message())
+		assert.True(t, headCommit.Parent(0).Id().Equal(master.Target()))
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "ConflictAbort", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+
+		test.WriteFile(repo, true, "foo", "line1")
+		test.Commit(repo, nil)
+		test.WriteFile(repo, true, "foo", "line2")
+		b, _ := test.Commit(repo, nil)
+
+		context.OpenEditor = func(config *git.Config, file string) (string, error) {
+			return "pick " + b.String(), nil
+		}
+
+		err := RewriteStartCommand(repo, context.Context)
+		assert.NotNil(t, err)
+
+		err = RewriteAbortCommand(repo)
+		assert.Nil(t, err)
+		assert.False(t, core.RewriteInProgress(repo))
+
+		// The tip should be back where it was
+		head, _ := repo.Head()
+		assert.Equal(t, core.RefsTips+"test", head.Name())
+		assert.True(t, head.Target().Equal(b))
+		test.StatusClean(t, repo)
+
+		// Nothing to abort anymore
+		err = RewriteAbortCommand(repo)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Not in a rewrite sequence.", err.Error())
+		}
+	})
+
+	test.RunOnRepo(t, "Edit", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+
+		test.WriteFile(repo, true, "a", "a")
+		a, _ := test.Commit(repo, &test.CommitParams{Message: "a"})
+		test.WriteFile(repo, true, "b", "b")
+		b, _ := test.Commit(repo, &test.CommitParams{Message: "b"})
+
+		context.OpenEditor = func(config *git.Config, file string) (string, error) {
+			return fmt.Sprintf("edit %v\npick %v\n", a, b), nil
+		}
+
+		err := RewriteStartCommand(repo, context.Context)
+		assert.Nil(t, err)
+
+		// The rewrite stopped on a
+		assert.True(t, core.RewriteInProgress(repo))
+		head, _ := repo.Head()
+		assert.True(t, head.Target().Equal(a))
+		assert.Equal(t, "Stopped at "+a.String()[:7]+" a\nChange the files and run 'tie rewrite continue' once done.\n", context.OutputBuffer.String())
+
+		// Change a
+		test.WriteFile(repo, true, "a", "a edited")
+
+		err = RewriteContinueCommand(repo, context.Context)
+		assert.Nil(t, err)
+
+		head, _ = repo.Head()
+		headCommit, _ := repo.LookupCommit(head.Target())
+		assert.Equal(t, "b", headCommit.Message())
+		amended := headCommit.Parent(0)
+		assert.Equal(t, "a", amended.Message())
+		amendedTree, _ := amended.Tree()
+		entry := amendedTree.EntryByName("a")
+		blob, _ := repo.LookupBlob(entry.Id)
+		assert.Equal(t, "a edited", string(blob.Contents()))
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "NotOnTip", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		err := RewriteStartCommand(repo, context.Context)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Not on a tip. Only tips can be rewritten.", err.Error())
+		}
+	})
+
+	test.RunOnRepo(t, "UnbornHead", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		repo.References.CreateSymbolic("HEAD", "refs/heads/unborn", true, "")
+
+		err := RewriteStartCommand(repo, context.Context)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Not on a tip. Only tips can be rewritten.", err.Error())
+		}
+	})
+}
+
+func TestScriptedRewrite(t *testing.T) {
+	// Creates a tip with commits a, b and c, each adding the file of the same name
+	setup := func(repo *git.Repository) (a, b, c *git.Oid) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, true, "a", "a")
+		a, _ = test.Commit(repo, &test.CommitParams{Message: "a"})
+		test.WriteFile(repo, true, "b", "b")
+		b, _ = test.Commit(repo, &test.CommitParams{Message: "b"})
+		test.WriteFile(repo, true, "c", "c")
+		c, _ = test.Commit(repo, &test.CommitParams{Message: "c"})
+		return
+	}
+
+	messages := func(repo *git.Repository) []string {
+		commits, _ := core.TipCommits(repo, "test")
+		result := []string{}
+		for _, commit := range commits {
+			result = append(result, commit.Message())
+		}
+		return result
+	}
+
+	test.RunOnRepo(t, "Drop", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		_, b, _ := setup(repo)
+
+		err := RewriteDropCommand(repo, b.String(), context.Context)
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"a", "c"}, messages(repo))
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "Fixup", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		_, b, _ := setup(repo)
+
+		err := RewriteFixupCommand(repo, b.String(), context.Context)
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"a", "c"}, messages(repo))
+
+		// a now contains b
+		commits, _ := core.TipCommits(repo, "test")
+		tree, _ := commits[0].Tree()
+		assert.NotNil(t, tree.EntryByName("b"))
+	})
+
+	test.RunOnRepo(t, "SquashRange", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		a, _, c := setup(repo)
+
+		err := RewriteSquashCommand(repo, a.String()+".."+c.String(), model.OptionMissing, context.Context)
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"a\nb\nc"}, messages(repo))
+	})
+
+	test.RunOnRepo(t, "SquashWithMessage", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		_, _, c := setup(repo)
+
+		err := RewriteSquashCommand(repo, c.String(), "b and c", context.Context)
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"a", "b and c\n"}, messages(repo))
+	})
+
+	test.RunOnRepo(t, "Reword", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		a, _, _ := setup(repo)
+
+		context.OpenEditor = func(config *git.Config, file string) (string, error) {
+			t.Error("The editor shouldn't be opened")
+			return "", nil
+		}
+
+		err := RewriteRewordCommand(repo, a.String(), "a reworded", context.Context)
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"a reworded\n", "b", "c"}, messages(repo))
+	})
+
+	test.RunOnRepo(t, "Move", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		a, _, c := setup(repo)
+
+		err := RewriteMoveCommand(repo, c.String(), a.String(), context.Context)
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"c", "a", "b"}, messages(repo))
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "NotInTip", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		master, _ := repo.References.Lookup("refs/heads/master")
+		setup(repo)
+
+		err := RewriteDropCommand(repo, master.Target().String(), context.Context)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Commit '"+master.Target().String()+"' is not part of tip 'test'.", err.Error())
+		}
+	})
+
+	test.RunOnRepo(t, "UnreachableRemote", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		_, b, _ := setup(repo)
+		config, _ := repo.Config()
+		config.SetString("tip.test.base", "refs/remotes/origin/master")
+		repo.Remotes.Create("origin", "/dev/null")
+
+		err := RewriteDropCommand(repo, b.String(), context.Context)
+		assert.Nil(t, err)
+
+		// The tip is rewritten even though it can't be pushed
+		assert.Equal(t, []string{"a", "c"}, messages(repo))
+		assert.Contains(t, context.OutputBuffer.String(), "Tip 'test' has been rewritten locally but not on origin.\n")
+	})
 }
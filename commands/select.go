@@ -2,10 +2,11 @@ package commands
 
 import (
 	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
 	"gopkg.in/libgit2/git2go.v25"
 )
 
-func SelectCommand(repo *git.Repository, shorthand string) error {
+func SelectCommand(repo *git.Repository, shorthand string, stash bool, context model.Context) error {
 	// lookup the reference
 	rev, err := core.Dwim(repo, shorthand)
 
@@ -13,6 +14,32 @@ func SelectCommand(repo *git.Repository, shorthand string) error {
 		return err
 	}
 
+	// Like a branch, a tip can only be checked out in one worktree
+	if tipName, notTip := core.TipName(rev.Name()); notTip == nil {
+		if err := core.CheckTipNotInWorktree(repo, tipName); err != nil {
+			return err
+		}
+	}
+
+	previous := new(git.Oid).String()
+	head, headErr := repo.Head()
+	if headErr == nil {
+		previous = head.Target().String()
+	}
+
+	// Leave the uncommitted changes on the tip they were made on
+	var currentTip string
+	saved := false
+	if stash && headErr == nil && head.Name() != rev.Name() {
+		if tipName, notTip := core.TipName(head.Name()); notTip == nil {
+			currentTip = tipName
+			saved, err = core.SaveWip(repo, currentTip)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// checkout the index and the working tree
 	commit, _ := repo.LookupCommit(rev.Target())
 
@@ -20,11 +47,32 @@ func SelectCommand(repo *git.Repository, shorthand string) error {
 
 	err = repo.CheckoutTree(tree, &git.CheckoutOpts{Strategy: git.CheckoutSafe})
 	if err != nil {
+		if saved {
+			core.RestoreWip(repo, currentTip)
+		}
 		return err
 	}
 
 	// set HEAD
 	_, err = repo.References.CreateSymbolic("HEAD", rev.Name(), true, "Selected "+rev.Name())
+	if err != nil {
+		return err
+	}
+
+	if saved {
+		context.Logger.Printf("Saved the work in progress of tip '%v'\n", currentTip)
+	}
+
+	if tipName, notTip := core.TipName(rev.Name()); stash && notTip == nil {
+		restored, err := core.RestoreWip(repo, tipName)
+		if err != nil {
+			return err
+		}
+		if restored {
+			context.Logger.Printf("Restored the work in progress of tip '%v'\n", tipName)
+		}
+	}
 
-	return err
+	// Like git, the exit status of post-checkout becomes the one of the command
+	return core.RunHook(repo, core.HookPostCheckout, "", previous, commit.Id().String(), "1")
 }
@@ -5,6 +5,9 @@ import (
 	"github.com/apflieger/tie/test"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -19,7 +22,7 @@ func TestSelectCommand(t *testing.T) {
 		assert.Equal(t, "refs/heads/master", head.Name())
 
 		// Select the test tip
-		SelectCommand(repo, "test")
+		SelectCommand(repo, "test", true, context.Context)
 
 		// We expect HEAD to be attached on the tip
 		head, _ = repo.Head()
@@ -38,7 +41,7 @@ func TestSelectCommand(t *testing.T) {
 		repo.References.Create("refs/remotes/origin/master", head.Target(), false, "")
 
 		// Select origin/master
-		SelectCommand(repo, "refs/remotes/origin/master")
+		SelectCommand(repo, "refs/remotes/origin/master", true, context.Context)
 
 		// Commit a change on origin/master (this simulates a fetch)
 		test.WriteFile(repo, true, "foo", "b")
@@ -55,7 +58,7 @@ func TestSelectCommand(t *testing.T) {
 		assert.Equal(t, 1, statusCount)
 
 		// re-select origin/master should clean the status
-		err := SelectCommand(repo, "refs/remotes/origin/master")
+		err := SelectCommand(repo, "refs/remotes/origin/master", true, context.Context)
 
 		assert.Nil(t, err)
 
@@ -67,7 +70,7 @@ func TestSelectCommand(t *testing.T) {
 	})*/
 
 	test.RunOnRepo(t, "DwimFailed", func(t *testing.T, context test.TestContext, repo *git.Repository) {
-		err := SelectCommand(repo, "test")
+		err := SelectCommand(repo, "test", true, context.Context)
 
 		if assert.NotNil(t, err) {
 			assert.Equal(t, "No ref found for shorthand 'test'", err.Error())
@@ -83,11 +86,79 @@ func TestSelectCommand(t *testing.T) {
 		test.WriteFile(repo, false, "foo", "b")
 
 		// select the tip
-		err := SelectCommand(repo, "test")
+		err := SelectCommand(repo, "test", true, context.Context)
 
 		// We expect the select to fail because the checkout has a conflict
 		if assert.NotNil(t, err) {
 			assert.Equal(t, "1 conflict prevents checkout", err.Error())
 		}
 	})
+	test.RunOnRepo(t, "Autostash", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteFile(repo, true, "foo", "a")
+		test.Commit(repo, nil)
+		test.CreateTip(repo, "other", "refs/heads/master", false)
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+
+		// Uncommitted change on test
+		test.WriteFile(repo, false, "foo", "b")
+
+		err := SelectCommand(repo, "other", true, context.Context)
+		assert.Nil(t, err)
+		test.StatusClean(t, repo)
+		assert.Equal(t, "Saved the work in progress of tip 'test'\n", context.OutputBuffer.String())
+
+		// Back on test, the change is restored
+		err = SelectCommand(repo, "test", true, context.Context)
+		assert.Nil(t, err)
+		content, _ := ioutil.ReadFile(filepath.Join(repo.Workdir(), "foo"))
+		assert.Equal(t, "b", string(content))
+		_, err = repo.References.Lookup(core.RefsWip + "test")
+		assert.NotNil(t, err)
+	})
+
+	test.RunOnRepo(t, "NoStash", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteFile(repo, true, "foo", "a")
+		test.Commit(repo, nil)
+		test.CreateTip(repo, "other", "refs/heads/master", false)
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, false, "foo", "b")
+
+		// tie select --no-stash other
+		err := SelectCommand(repo, "other", false, context.Context)
+		assert.Nil(t, err)
+
+		// The change follows
+		content, _ := ioutil.ReadFile(filepath.Join(repo.Workdir(), "foo"))
+		assert.Equal(t, "b", string(content))
+		_, err = repo.References.Lookup(core.RefsWip + "test")
+		assert.NotNil(t, err)
+	})
+	test.RunOnRepo(t, "CheckedOutInWorktree", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		dir, _ := ioutil.TempDir("", "tie-worktrees-")
+		defer os.RemoveAll(dir)
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		path := filepath.Join(dir, "test")
+
+		// tie select --worktree test
+		err := WorktreeCommand(repo, "test", path, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t, "Tip 'test' is checked out in "+path+"\n", context.OutputBuffer.String())
+
+		err = SelectCommand(repo, "test", true, context.Context)
+		assert.Equal(t, "Tip 'test' is checked out in the worktree "+path+".", err.Error())
+
+		// Moving the tip would leave the worktree behind
+		err = RebaseCommand(repo, "refs/heads/master", "test", context.Context)
+		assert.Equal(t, "Tip 'test' is checked out in the worktree "+path+".", err.Error())
+		assert.False(t, core.RewriteInProgress(repo))
+		err = SplitCommand(repo, "test", "HEAD", "new", context.Context)
+		assert.Equal(t, "Tip 'test' is checked out in the worktree "+path+".", err.Error())
+
+		// Deleting the tip prunes its worktree
+		err = DeleteCommand(repo, false, false, []string{core.RefsTips + "test"}, context.Context)
+		assert.Nil(t, err)
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+		assert.Nil(t, core.TipWorktree(repo, "test"))
+	})
 }
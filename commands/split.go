@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+)
+
+// Moves the commits of the tip that come after the commit at into a new tip,
+// stacked on the tip. The new tip gets selected.
+func SplitCommand(repo *git.Repository, tip, at, newName string, context model.Context) error {
+	if core.RewriteInProgress(repo) {
+		return errors.New("A rewrite is in progress. Run 'tie rewrite continue' or 'tie rewrite abort' before splitting.")
+	}
+
+	tipName, err := resolveTip(repo, tip, "Not on a tip. Specify the tip to split.")
+	if err != nil {
+		return err
+	}
+
+	if err := core.CheckTipNotInWorktree(repo, tipName); err != nil {
+		return err
+	}
+
+	newName = strings.Trim(strings.TrimPrefix(newName, core.RefsTips), " ")
+	if len(newName) == 0 {
+		return errors.New("Name of the new tip can't be empty. Use --name.")
+	}
+
+	if _, err := repo.References.Lookup(core.RefsTips + newName); err == nil {
+		return fmt.Errorf("Tip '%v' already exists.", newName)
+	}
+
+	if len(at) == 0 {
+		return errors.New("The split point is missing. Use --at.")
+	}
+
+	object, err := repo.RevparseSingle(at)
+	if err != nil {
+		return err
+	}
+	splitPoint := object.Id()
+
+	tipCommits, err := core.TipCommits(repo, tipName)
+	if err != nil {
+		return err
+	}
+
+	position := -1
+	for i, commit := range tipCommits {
+		if commit.Id().Equal(splitPoint) {
+			position = i
+		}
+	}
+
+	if position < 0 {
+		return fmt.Errorf("Commit '%v' is not part of tip '%v'.", at, tipName)
+	}
+
+	if position == len(tipCommits)-1 {
+		return fmt.Errorf("Commit '%v' is the last one of tip '%v'. There is nothing to split.", at, tipName)
+	}
+
+	tipRef, _ := repo.References.Lookup(core.RefsTips + tipName)
+	top := tipRef.Target()
+
+	// Switch to the content of the new tip first, in case it fails
+	var topTree *git.Tree
+	head, _ := repo.Head()
+	if head == nil || head.Name() != tipRef.Name() {
+		commit, _ := repo.LookupCommit(top)
+		topTree, _ = commit.Tree()
+		if err := repo.CheckoutTree(topTree, &git.CheckoutOpts{Strategy: git.CheckoutSafe}); err != nil {
+			return err
+		}
+	}
+
+	tx := core.NewTransaction(repo, "tie split")
+	tx.CreateRef(core.RefsTips+newName, top, false)
+	tx.CreateRef(core.RefsTails+newName, splitPoint, true)
+	tx.SetConfig(fmt.Sprintf("tip.%v.base", newName), core.RefsTips+tipName)
+
+	// The tips stacked on the top of the tip are now stacked on the new tip
+	for child, base := range core.TipBases(repo) {
+		if base == core.RefsTips+tipName {
+			tx.SetConfig(fmt.Sprintf("tip.%v.base", child), core.RefsTips+newName)
+		}
+	}
+
+	tx.CreateSymbolicRef("HEAD", core.RefsTips+newName)
+	tx.CreateRef(core.RefsTips+tipName, splitPoint, true)
+
+	if err := tx.Commit(); err != nil {
+		// HEAD is back where it was, and so must be the working tree
+		if topTree != nil && head != nil {
+			headCommit, _ := repo.LookupCommit(head.Target())
+			headTree, _ := headCommit.Tree()
+			repo.CheckoutTree(headTree, &git.CheckoutOpts{Strategy: git.CheckoutSafe, Baseline: topTree})
+		}
+		return err
+	}
+
+	context.Logger.Printf("Split tip '%v': %v kept, %v moved to '%v'\n",
+		tipName, commits(position+1), commits(len(tipCommits)-position-1), newName)
+
+	// The tip is split when the pre-push hook fails, only the push is aborted
+	if err := core.PushTip(repo, tipName, context); core.IsHookError(err) {
+		return err
+	}
+	if err := core.PushTip(repo, newName, context); core.IsHookError(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	test.RunOnRemote(t, "Split", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
+		a := test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"a": "a"})
+		test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"b": "b"})
+		c := test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"c": "c"})
+		config, _ := repo.Config()
+		config.SetString("tip.child.base", core.RefsTips+"test")
+
+		err := SplitCommand(repo, "", a.String(), "upper", context.Context)
+		assert.Nil(t, err)
+
+		tip, _ := repo.References.Lookup(core.RefsTips + "test")
+		assert.True(t, tip.Target().Equal(a))
+
+		upper, _ := repo.References.Lookup(core.RefsTips + "upper")
+		assert.True(t, upper.Target().Equal(c))
+		tail, _ := repo.References.Lookup(core.RefsTails + "upper")
+		assert.True(t, tail.Target().Equal(a))
+		base, _ := config.LookupString("tip.upper.base")
+		assert.Equal(t, core.RefsTips+"test", base)
+
+		// The new tip is selected and the tips stacked on top follow it
+		head, _ := repo.Head()
+		assert.Equal(t, core.RefsTips+"upper", head.Name())
+		childBase, _ := config.LookupString("tip.child.base")
+		assert.Equal(t, core.RefsTips+"upper", childBase)
+
+		// Both tips are pushed
+		remoteTip, _ := remote.References.Lookup(core.RefsTips + "test")
+		assert.True(t, remoteTip.Target().Equal(a))
+		remoteUpper, _ := remote.References.Lookup(core.RefsTips + "upper")
+		assert.True(t, remoteUpper.Target().Equal(c))
+
+		assert.Contains(t, context.OutputBuffer.String(), "Split tip 'test': 1 commit kept, 2 commits moved to 'upper'\n")
+	})
+
+	test.RunOnRepo(t, "TransactionFailure", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		a := test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"a": "a"})
+		test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"b": "b"})
+
+		// refs/tails/upper can't be created next to refs/tails/upper/blocking
+		repo.References.Create(core.RefsTails+"upper/blocking", a, false, "")
+
+		err := SplitCommand(repo, "test", a.String(), "upper", context.Context)
+		assert.NotNil(t, err)
+
+		// The working tree is back on master with the refs
+		_, err = repo.References.Lookup(core.RefsTips + "upper")
+		assert.NotNil(t, err)
+		current, _ := repo.Head()
+		assert.Equal(t, head.Name(), current.Name())
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "InvalidSplitPoint", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		outside := head.Target()
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		last := test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"a": "a"})
+
+		err := SplitCommand(repo, "test", outside.String(), "upper", context.Context)
+		assert.Equal(t, "Commit '"+outside.String()+"' is not part of tip 'test'.", err.Error())
+
+		err = SplitCommand(repo, "test", last.String(), "upper", context.Context)
+		assert.Equal(t, "Commit '"+last.String()+"' is the last one of tip 'test'. There is nothing to split.", err.Error())
+
+		err = SplitCommand(repo, "test", last.String(), "", context.Context)
+		assert.Equal(t, "Name of the new tip can't be empty. Use --name.", err.Error())
+	})
+}
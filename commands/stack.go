@@ -40,10 +40,20 @@ func StackCommand(repo *git.Repository, context model.Context) error {
 		base.SetTarget(head.Target(), "stack tip "+tipName) // base is not mutated, .Target will still return the previous one
 		printStackInfo(repo, context.Logger, baseRefName, head.Name(), base.Target(), head.Target())
 		if notOnLocalTip == nil {
-			core.PushTip(repo, baseTipName, context)
+			if err := core.PushTip(repo, baseTipName, context); core.IsHookError(err) {
+				return err
+			}
 		}
 	} else {
 		remote, _ := repo.Remotes.Lookup(remoteName)
+		refspecs := []string{head.Name() + ":" + pushRef}
+
+		if !context.NoVerify {
+			if err := core.RunPrePushHook(repo, remote, refspecs); err != nil {
+				return err
+			}
+		}
+
 		pushOptions := &git.PushOptions{
 			RemoteCallbacks: context.RemoteCallbacks,
 		}
@@ -55,7 +65,8 @@ func StackCommand(repo *git.Repository, context model.Context) error {
 
 		// There's a vulnerability in case of a reverse fast forward reset on the remote.
 		// In which case push will succeed, putting commits that have been removed back to the base.
-		pushErr := remote.Push([]string{head.Name() + ":" + pushRef}, pushOptions)
+		pushErr := remote.Push(refspecs, pushOptions)
+		core.ApproveCredentials(context, pushErr)
 		gitErr, isGitErr := pushErr.(*git.GitError)
 		if isGitErr && gitErr.Code == git.ErrNonFastForward {
 			return fmt.Errorf("Current tip '%v' is out of date with its base '%v'. Please update\n", tipName, baseRefName)
@@ -63,11 +74,14 @@ func StackCommand(repo *git.Repository, context model.Context) error {
 	}
 
 	repo.References.CreateSymbolic("HEAD", baseRefName, true, "stack tip "+tipName)
+	hookErr := core.RunHook(repo, core.HookPostCheckout, "", head.Target().String(), head.Target().String(), "1")
 
 	// The tip has been successfully stacked. Now we can delete it.
-	core.DeleteTip(repo, tipName, context)
+	if err := core.DeleteTip(repo, tipName, context); err != nil {
+		return err
+	}
 
-	return nil
+	return hookErr
 }
 
 func printStackInfo(repo *git.Repository, logger *log.Logger, baseRefName, tipRefName string, baseOid, tipOid *git.Oid) {
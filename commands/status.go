@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+)
+
+var operationMessages = map[string]string{
+	core.OperationUpdate:     "Update in progress. Run 'tie update continue' or 'tie update abort'.",
+	core.OperationRebase:     "Rebase in progress.",
+	core.OperationMerge:      "Merge in progress.",
+	core.OperationCherryPick: "Cherry-pick in progress.",
+	core.OperationRevert:     "Revert in progress.",
+	core.OperationUnknown:    "A git operation is in progress.",
+}
+
+func StatusCommand(repo *git.Repository, context model.Context) error {
+	if machineReadable(context) {
+		return printStatusRecord(repo, context)
+	}
+
+	logger := context.Logger
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+
+	tipName, notTip := core.TipName(head.Name())
+
+	if notTip != nil {
+		logger.Printf("On '%v', not on a tip\n", head.Name())
+	} else {
+		status, err := core.GetTipStatus(repo, tipName)
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("On tip '%v' based on '%v'\n", tipName, status.Base)
+
+		if title := core.TipTitle(repo, tipName); len(title) > 0 {
+			logger.Printf("Title: %v\n", title)
+		}
+
+		if len(status.BaseOid) == 0 {
+			logger.Printf("Base '%v' doesn't exist\n", status.Base)
+		} else {
+			logger.Printf("%v ahead, %v behind '%v'\n", commits(status.Ahead), status.Behind, status.Base)
+			if !status.UpToDate {
+				logger.Println("The base has moved since the last update. Run 'tie update'.")
+			}
+		}
+
+		if len(status.RemoteTip) > 0 {
+			if status.Pushed {
+				logger.Printf("%v ahead, %v behind '%v'\n", commits(status.RemoteAhead), status.RemoteBehind, status.RemoteTip)
+			} else {
+				logger.Println("Not pushed yet")
+			}
+		}
+	}
+
+	changes, err := core.WorkdirChanges(repo)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) > 0 {
+		logger.Println("Changes:")
+		for _, change := range changes {
+			staged := " "
+			if change.Staged {
+				staged = "+"
+			}
+			logger.Printf("  %v %-11v %v\n", staged, change.Change+":", change.Path)
+		}
+	}
+
+	for _, operation := range core.OperationsInProgress(repo) {
+		logger.Println(operationMessage(repo, operation))
+	}
+
+	return nil
+}
+
+// The rewrite is continued or aborted by the command that started it: rewrite, update, rebase or move
+func operationMessage(repo *git.Repository, operation string) string {
+	if operation != core.OperationRewrite {
+		return operationMessages[operation]
+	}
+
+	command := "rewrite"
+	if rewrite, err := core.LoadRewrite(repo); err == nil {
+		command = rewrite.CommandName()
+	}
+
+	return fmt.Sprintf("%v in progress. Run 'tie %v continue' or 'tie %v abort'.", strings.Title(command), command, command)
+}
+
+func printStatusRecord(repo *git.Repository, context model.Context) error {
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+
+	record := core.StatusRecord{
+		Operations: core.OperationsInProgress(repo),
+	}
+
+	record.Head, err = core.NewRefRecord(repo, head.Name(), head.Name())
+	if err != nil {
+		return err
+	}
+
+	if tipName, notTip := core.TipName(head.Name()); notTip == nil {
+		status, err := core.GetTipStatus(repo, tipName)
+		if err != nil {
+			return err
+		}
+		if status.Pushed {
+			record.RemoteTip = status.RemoteTip
+			record.RemoteAhead = status.RemoteAhead
+			record.RemoteBehind = status.RemoteBehind
+		}
+	}
+
+	record.Changes, err = core.WorkdirChanges(repo)
+	if err != nil {
+		return err
+	}
+
+	if context.Format == model.FormatJson {
+		return printJson(context, record)
+	}
+
+	context.Logger.Println(record.Head.Porcelain())
+	if len(record.RemoteTip) > 0 {
+		context.Logger.Printf("remote\t%v\t%v\t%v\n", record.RemoteTip, record.RemoteAhead, record.RemoteBehind)
+	}
+	for _, change := range record.Changes {
+		context.Logger.Println(change.Porcelain())
+	}
+	for _, operation := range record.Operations {
+		context.Logger.Println("operation\t" + operation)
+	}
+
+	return nil
+}
+
+func commits(count int) string {
+	if count == 1 {
+		return "1 commit"
+	}
+	return fmt.Sprintf("%v commits", count)
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestStatusCommand(t *testing.T) {
+	test.RunOnRepo(t, "NotOnTip", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		err := StatusCommand(repo, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t, "On 'refs/heads/master', not on a tip\n", context.OutputBuffer.String())
+	})
+
+	test.RunOnRemote(t, "OnTip", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
+		test.WriteFile(repo, true, "foo", "foo")
+		test.Commit(repo, nil)
+		test.CommitFiles(repo, "refs/remotes/origin/master", map[string]string{"bar": "bar"})
+		test.WriteFile(repo, false, "foo", "bar")
+
+		err := StatusCommand(repo, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t,
+			"On tip 'test' based on 'refs/remotes/origin/master'\n"+
+				"1 commit ahead, 1 behind 'refs/remotes/origin/master'\n"+
+				"The base has moved since the last update. Run 'tie update'.\n"+
+				"Not pushed yet\n"+
+				"Changes:\n"+
+				"    modified:   foo\n",
+			context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "RewriteInProgress", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, true, "a", "a")
+		a, _ := test.Commit(repo, &test.CommitParams{Message: "a"})
+
+		context.OpenEditor = func(config *git.Config, file string) (string, error) {
+			return fmt.Sprintf("edit %v\n", a), nil
+		}
+		RewriteStartCommand(repo, context.Context)
+		context.OutputBuffer.Reset()
+
+		err := StatusCommand(repo, context.Context)
+		assert.Nil(t, err)
+		assert.True(t, core.RewriteInProgress(repo))
+		assert.Equal(t,
+			"On tip 'test' based on 'refs/heads/master'\n"+
+				"1 commit ahead, 0 behind 'refs/heads/master'\n"+
+				"Rewrite in progress. Run 'tie rewrite continue' or 'tie rewrite abort'.\n",
+			context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "JsonFormat", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		head, _ := repo.Head()
+		oid := head.Target().String()
+		test.WriteFile(repo, true, "foo", "foo")
+		context.Format = model.FormatJson
+
+		err := StatusCommand(repo, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t,
+			`{"head":{"ref":"refs/tips/test","kind":"tip","base":"refs/heads/master","tail":"`+oid+`","tip":"`+oid+`","ahead":0,"behind":0,"pushed":false,"selected":true},`+
+				`"remote_tip":"","remote_ahead":0,"remote_behind":0,`+
+				`"changes":[{"path":"foo","change":"new file","staged":true}],"operations":[]}`+"\n",
+			context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "PorcelainFormat", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		oid := head.Target().String()
+		test.WriteFile(repo, false, "foo", "foo")
+		context.Format = model.FormatPorcelain
+
+		err := StatusCommand(repo, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t,
+			"ref\trefs/heads/master\tbranch\t-\t-\t"+oid+"\t0\t0\tfalse\ttrue\n"+
+				"change\tfoo\tuntracked\tfalse\n",
+			context.OutputBuffer.String())
+	})
+}
@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+)
+
+func UndoCommand(repo *git.Repository, push bool, context model.Context) error {
+	if !core.WorkdirClean(repo) {
+		return errors.New("Status should be clean before undoing.")
+	}
+
+	operation, err := core.UndoOperation(repo)
+	if err != nil {
+		return err
+	}
+
+	context.Logger.Printf("Undid 'tie %v'\n", operation.Command)
+
+	if push {
+		pushChangedTips(repo, operation, context)
+	}
+
+	return nil
+}
+
+func RedoCommand(repo *git.Repository, push bool, context model.Context) error {
+	if !core.WorkdirClean(repo) {
+		return errors.New("Status should be clean before redoing.")
+	}
+
+	operation, err := core.RedoOperation(repo)
+	if err != nil {
+		return err
+	}
+
+	context.Logger.Printf("Redid 'tie %v'\n", operation.Command)
+
+	if push {
+		pushChangedTips(repo, operation, context)
+	}
+
+	return nil
+}
+
+// Lists the recorded operations, the most recent first
+func OplogCommand(repo *git.Repository, context model.Context) error {
+	operations, err := core.LoadJournal(repo)
+	if err != nil {
+		return err
+	}
+
+	for i := len(operations) - 1; i >= 0; i-- {
+		operation := operations[i]
+		undone := ""
+		if operation.Undone {
+			undone = " (undone)"
+		}
+		context.Logger.Printf("%v %v tie %v%v\n", operation.Id, operation.Time.Format("2006-01-02 15:04:05"), operation.Command, undone)
+
+		for _, change := range operation.Changes {
+			context.Logger.Printf("    %v: %v -> %v\n", change.Name, journalValue(change.Before), journalValue(change.After))
+		}
+	}
+
+	return nil
+}
+
+func journalValue(value string) string {
+	if len(value) == 0 {
+		return "(none)"
+	}
+	if _, err := git.NewOid(value); err == nil {
+		return value[:7]
+	}
+	return value
+}
+
+// Brings the remote in line with the tips changed by the operation
+func pushChangedTips(repo *git.Repository, operation *core.Operation, context model.Context) {
+	bases := map[string]string{}
+	described := map[string]bool{}
+	for _, change := range operation.Changes {
+		if strings.HasPrefix(change.Name, core.RefsTipMeta) {
+			described[strings.TrimPrefix(change.Name, core.RefsTipMeta)] = true
+		}
+		// The base is needed to find the remote of deleted tips
+		if strings.HasPrefix(change.Name, core.ConfigEntryPrefix+"tip.") {
+			tipName := strings.TrimSuffix(strings.TrimPrefix(change.Name, core.ConfigEntryPrefix+"tip."), ".base")
+			bases[tipName] = change.After
+			if len(change.After) == 0 {
+				bases[tipName] = change.Before
+			}
+		}
+	}
+
+	config, _ := repo.Config()
+
+	tipNames := []string{}
+	for _, change := range operation.Changes {
+		if tipName, err := core.TipName(change.Name); err == nil {
+			tipNames = append(tipNames, tipName)
+		}
+	}
+
+	// A tip whose description alone changed is pushed again
+	for _, change := range operation.Changes {
+		tipName := strings.TrimPrefix(change.Name, core.RefsTipMeta)
+		if tipName == change.Name {
+			continue
+		}
+		found := false
+		for _, name := range tipNames {
+			found = found || name == tipName
+		}
+		if !found {
+			tipNames = append(tipNames, tipName)
+		}
+	}
+
+	for _, tipName := range tipNames {
+		if _, err := repo.References.Lookup(core.RefsTips + tipName); err == nil {
+			err = core.PushTip(repo, tipName, context)
+			if err == nil {
+				context.Logger.Printf("Pushed tip '%v'\n", tipName)
+			} else {
+				context.Logger.Println(err.Error())
+			}
+			continue
+		}
+
+		base, found := bases[tipName]
+		if !found {
+			base, _ = config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
+		}
+
+		remoteName, err := core.DeleteRemoteTip(repo, tipName, base, described[tipName], context)
+		if err == nil && len(remoteName) > 0 {
+			context.Logger.Printf("Deleted tip '%v' on %v\n", tipName, remoteName)
+		}
+	}
+}
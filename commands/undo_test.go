@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+	"testing"
+)
+
+func TestUndoCommand(t *testing.T) {
+	test.RunOnRemote(t, "UndoCreateAndPush", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		before := core.TakeSnapshot(repo)
+		CreateCommand(repo, "test", "refs/remotes/origin/master")
+		core.PushTip(repo, "test", context.Context)
+		core.RecordOperation(repo, "create test refs/remotes/origin/master", before)
+
+		err := UndoCommand(repo, true, context.Context)
+		assert.Nil(t, err)
+
+		_, err = repo.References.Lookup(core.RefsTips + "test")
+		assert.NotNil(t, err)
+
+		// The tip has been deleted on the remote too
+		_, err = remote.References.Lookup(core.RefsTips + "test")
+		assert.NotNil(t, err)
+
+		assert.Equal(t,
+			"Undid 'tie create test refs/remotes/origin/master'\n"+
+				"Deleted tip 'test' on origin\n",
+			context.OutputBuffer.String())
+
+		// Redo without pushing
+		context.OutputBuffer.Reset()
+		err = RedoCommand(repo, false, context.Context)
+		assert.Nil(t, err)
+
+		head, _ := repo.Head()
+		assert.Equal(t, core.RefsTips+"test", head.Name())
+		_, err = remote.References.Lookup(core.RefsTips + "test")
+		assert.NotNil(t, err)
+		assert.Equal(t, "Redid 'tie create test refs/remotes/origin/master'\n", context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "DirtyStateError", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteFile(repo, true, "foo", "bar")
+
+		err := UndoCommand(repo, false, context.Context)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Status should be clean before undoing.", err.Error())
+		}
+	})
+}
+
+func TestOplogCommand(t *testing.T) {
+	test.RunOnRepo(t, "MostRecentFirst", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		before := core.TakeSnapshot(repo)
+		CreateCommand(repo, "a", "")
+		core.RecordOperation(repo, "create a", before)
+
+		before = core.TakeSnapshot(repo)
+		CreateCommand(repo, "b", "")
+		core.RecordOperation(repo, "create b", before)
+		core.UndoOperation(repo)
+
+		err := OplogCommand(repo, context.Context)
+		assert.Nil(t, err)
+
+		output := context.OutputBuffer.String()
+		assert.Contains(t, output, "tie create b (undone)\n    HEAD: ref: refs/tips/a -> ref: refs/tips/b\n")
+		assert.Contains(t, output, "tie create a\n    HEAD: ref: refs/heads/master -> ref: refs/tips/a\n")
+		assert.True(t, strings.Index(output, "create b") < strings.Index(output, "create a"))
+	})
+}
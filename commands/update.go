@@ -1,14 +1,17 @@
 package commands
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/apflieger/tie/core"
 	"github.com/apflieger/tie/model"
 	"gopkg.in/libgit2/git2go.v25"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
 )
 
 // Identical to https://github.com/libgit2/libgit2/blob/master/src/rebase.c#L28
@@ -18,6 +21,9 @@ const (
 	headNameFile   = "head-name"
 )
 
+// Name of the tip whose descendants are updated once its own update is done
+const recursiveUpdateFile = "update-recursive"
+
 func fetch(repo *git.Repository, context model.Context) error {
 	head, _ := repo.Head()
 
@@ -32,18 +38,28 @@ func fetch(repo *git.Repository, context model.Context) error {
 	}
 
 	config, _ := repo.Config()
-	remoteName, err := remoteOf(head.Name(), config)
+	remoteName, err := core.RemoteOf(head.Name(), config)
 	if err != nil {
 		return nil
 	}
 
-	remote, _ := repo.Remotes.Lookup(remoteName)
+	return fetchRemote(repo, remoteName, context)
+}
+
+// Fetches the remote and checks out HEAD again if it is one of the fetched refs
+func fetchRemote(repo *git.Repository, remoteName string, context model.Context) error {
+	head, _ := repo.Head()
+
+	remote, err := repo.Remotes.Lookup(remoteName)
+	if err != nil {
+		return err
+	}
 
 	remoteCallbacks := git.RemoteCallbacks{
 		CredentialsCallback:      context.RemoteCallbacks.CredentialsCallback,
 		CertificateCheckCallback: context.RemoteCallbacks.CertificateCheckCallback,
 		UpdateTipsCallback: func(refname string, a *git.Oid, b *git.Oid) git.ErrorCode {
-			if refname == head.Name() {
+			if head != nil && refname == head.Name() {
 				baselineCommit, _ := repo.LookupCommit(a)
 				baselineTree, _ := baselineCommit.Tree()
 				checkoutCommit, _ := repo.LookupCommit(b)
@@ -71,26 +87,12 @@ func fetch(repo *git.Repository, context model.Context) error {
 		RemoteCallbacks: remoteCallbacks,
 	}
 	refspecs, _ := remote.FetchRefspecs()
-	return remote.Fetch(refspecs, fetchOptions, "")
-}
-
-func remoteOf(refname string, config *git.Config) (string, error) {
-	remoteName, _, err := core.ExplodeRemoteRef(refname)
-	if err == nil {
-		return remoteName, nil
-	}
-
-	tipName, err := core.TipName(refname)
-	if err != nil {
-		return "", err
-	}
-
-	base, _ := config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
-
-	return remoteOf(base, config)
+	err = remote.Fetch(refspecs, fetchOptions, "")
+	core.ApproveCredentials(context, err)
+	return err
 }
 
-func UpdateCommand(repo *git.Repository, context model.Context) error {
+func UpdateCommand(repo *git.Repository, recursive bool, context model.Context) error {
 	err := fetch(repo, context)
 	if err != nil {
 		return err
@@ -117,15 +119,31 @@ func UpdateCommand(repo *git.Repository, context model.Context) error {
 		return err
 	}
 
+	if _, landed, _ := core.LandedCommits(repo, tipName); landed {
+		context.Logger.Printf("Tip '%v' has landed on '%v'. Delete it with 'tie delete --merged'.\n", tipName, baseRefName)
+		if !recursive {
+			return nil
+		}
+		// The tips stacked on it are moved onto its base
+		return updateStack(repo, tipName, baseRef, context)
+	}
+
 	annotatedHeadCommit, _ := repo.AnnotatedCommitFromRef(head)
 	annotatedUpstreamCommit, _ := repo.AnnotatedCommitFromRef(tailRef)
 	annotatedOntoCommit, _ := repo.AnnotatedCommitFromRef(baseRef)
 
 	rebaseOpts, _ := git.DefaultRebaseOptions()
 
+	if recursive {
+		// Remembered in case of conflict, for update continue
+		os.MkdirAll(filepath.Join(repo.Path(), core.TieDir), 0755)
+		ioutil.WriteFile(recursiveUpdatePath(repo), []byte(tipName), 0644)
+	}
+
 	rebase, err := repo.InitRebase(annotatedHeadCommit, annotatedUpstreamCommit, annotatedOntoCommit, rebaseOpts)
 
 	if err != nil {
+		os.Remove(recursiveUpdatePath(repo))
 		return err
 	}
 	err = iterate(repo, rebase)
@@ -136,18 +154,200 @@ func UpdateCommand(repo *git.Repository, context model.Context) error {
 
 	tailRef.SetTarget(baseRef.Target(), "tie update")
 
+	var pushErr error
 	if rebase.OperationCount() > 0 {
-		err = core.PushTip(repo, tipName, context)
+		pushErr = core.PushTip(repo, tipName, context)
 	}
 
 	rebase.Free()
 
 	context.Logger.Printf("Upgraded current tip '%v' on top of '%v'\n", tipName, baseRefName)
 
+	if err := updateDescendants(repo, context); err != nil {
+		return err
+	}
+
+	// The tip is upgraded when the pre-push hook fails, only the push is aborted
+	if core.IsHookError(pushErr) {
+		return pushErr
+	}
+
+	return nil
+}
+
+// Outcomes of the update of a tip by update --all
+const (
+	tipUpdated     = "updated"
+	tipUpToDate    = "up to date"
+	tipLanded      = "landed"
+	tipConflict    = "conflict"
+	tipBaseMissing = "base missing"
+	tipNotPushed   = "not pushed"
+	tipFailed      = "failed"
+)
+
+// Fetches every remote the tips are based on, then replays each tip on its base.
+// Tips that would conflict are left untouched.
+func UpdateAllCommand(repo *git.Repository, context model.Context) error {
+	if !core.WorkdirClean(repo) {
+		return errors.New("Status should be clean before updating all tips.")
+	}
+
+	tipNames := core.TipsInOrder(repo)
+	config, _ := repo.Config()
+
+	// Each remote is fetched only once
+	fetched := map[string]bool{}
+	for _, tipName := range tipNames {
+		remoteName, err := core.RemoteOf(core.RefsTips+tipName, config)
+		if err != nil || fetched[remoteName] {
+			continue
+		}
+		fetched[remoteName] = true
+
+		err = fetchRemote(repo, remoteName, context)
+		if err != nil {
+			return err
+		}
+	}
+
+	results := map[string]string{}
+	errs := map[string]error{}
+	for _, tipName := range tipNames {
+		results[tipName], errs[tipName] = updateTip(repo, tipName, context)
+	}
+
+	printUpdateSummary(context, tipNames, results, errs)
+
 	return nil
 }
 
+// Replays the tip on its base without stopping on conflicts.
+// The error tells why a tip failed to be updated or pushed.
+func updateTip(repo *git.Repository, tipName string, context model.Context) (string, error) {
+	config, _ := repo.Config()
+	tip, _ := repo.References.Lookup(core.RefsTips + tipName)
+
+	baseRefName, _ := config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
+	base, err := repo.References.Lookup(baseRefName)
+	if err != nil {
+		return tipBaseMissing, nil
+	}
+
+	var tailOid *git.Oid
+	tail, err := repo.References.Lookup(core.RefsTails + tipName)
+	if err == nil {
+		tailOid = tail.Target()
+	} else if tailOid, err = repo.MergeBase(tip.Target(), base.Target()); err != nil {
+		return tipBaseMissing, nil
+	}
+
+	if tailOid.Equal(base.Target()) {
+		return tipUpToDate, nil
+	}
+
+	landed, fully, _ := core.LandedCommits(repo, tipName)
+	if fully {
+		return tipLanded, nil
+	}
+
+	if err := core.CheckTipNotInWorktree(repo, tipName); err != nil {
+		return tipFailed, err
+	}
+
+	commits, err := core.CommitsBetween(repo, tailOid, tip.Target())
+	if err != nil {
+		return tipConflict, nil
+	}
+
+	// Commits that already landed in the base are dropped
+	remaining := []*git.Commit{}
+	for _, commit := range commits {
+		if !landed[commit.Id().String()] {
+			remaining = append(remaining, commit)
+		}
+	}
+
+	onto, _ := repo.LookupCommit(base.Target())
+	replayed, err := core.ReplayCommits(repo, remaining, onto)
+	if err != nil {
+		return tipConflict, nil
+	}
+
+	head, err := repo.Head()
+	if err == nil && head.Name() == tip.Name() {
+		tipCommit, _ := repo.LookupCommit(tip.Target())
+		baselineTree, _ := tipCommit.Tree()
+		tree, _ := replayed.Tree()
+		err = repo.CheckoutTree(tree, &git.CheckoutOpts{
+			Strategy: git.CheckoutSafe,
+			Baseline: baselineTree,
+		})
+		if err != nil {
+			return tipConflict, nil
+		}
+	}
+
+	// tip is not mutated, .Target will still return the previous one
+	if _, err := tip.SetTarget(replayed.Id(), "tie update --all"); err != nil {
+		return tipFailed, err
+	}
+	if _, err := repo.References.Create(core.RefsTails+tipName, base.Target(), true, "tie update --all"); err != nil {
+		return tipFailed, err
+	}
+
+	// Empty tips are not pushed, neither are the local ones
+	_, notRemote := core.RemoteOf(baseRefName, config)
+	if notRemote == nil && !replayed.Id().Equal(onto.Id()) && !replayed.Id().Equal(tip.Target()) {
+		if err := core.PushTip(repo, tipName, context); err != nil {
+			return tipNotPushed, err
+		}
+	}
+
+	return tipUpdated, nil
+}
+
+func printUpdateSummary(context model.Context, tipNames []string, results map[string]string, errs map[string]error) {
+	buffer := new(bytes.Buffer)
+	writer := tabwriter.NewWriter(buffer, 0, 4, 2, ' ', 0)
+	counts := map[string]int{}
+
+	for _, tipName := range tipNames {
+		if errs[tipName] != nil {
+			fmt.Fprintf(writer, "%v\t%v (%v)\n", tipName, results[tipName], errs[tipName])
+		} else {
+			fmt.Fprintf(writer, "%v\t%v\n", tipName, results[tipName])
+		}
+		counts[results[tipName]]++
+	}
+	writer.Flush()
+
+	summary := fmt.Sprintf("%v %v, %v %v, %v %v, %v %v, %v %v",
+		counts[tipUpdated], tipUpdated,
+		counts[tipUpToDate], tipUpToDate,
+		counts[tipLanded], tipLanded,
+		counts[tipConflict], tipConflict,
+		counts[tipBaseMissing], tipBaseMissing)
+
+	// Failures are only counted when there are some
+	for _, outcome := range []string{tipNotPushed, tipFailed} {
+		if counts[outcome] > 0 {
+			summary += fmt.Sprintf(", %v %v", counts[outcome], outcome)
+		}
+	}
+
+	context.Logger.Print(buffer.String())
+	context.Logger.Println(summary)
+}
+
 func UpdateAbortCommand(repo *git.Repository) error {
+	// A descendant tip stopped on a conflict. The tips already updated stay as they are.
+	if core.RewriteInProgress(repo) {
+		return core.AbortRewrite(repo)
+	}
+
+	os.Remove(recursiveUpdatePath(repo))
+
 	rebaseOpts, _ := git.DefaultRebaseOptions()
 	rebase, _ := repo.OpenRebase(rebaseOpts)
 	rebase.Abort()
@@ -155,18 +355,106 @@ func UpdateAbortCommand(repo *git.Repository) error {
 	return nil
 }
 
-func UpdateContinueCommand(repo *git.Repository) error {
+func UpdateContinueCommand(repo *git.Repository, context model.Context) error {
+	if core.RewriteInProgress(repo) {
+		return core.ContinueRewrite(repo, context)
+	}
+
 	rebaseOpts, _ := git.DefaultRebaseOptions()
 	rebase, _ := repo.OpenRebase(rebaseOpts)
 	currentOperationIndex, _ := rebase.CurrentOperationIndex()
 	commit(repo, rebase, rebase.OperationAt(currentOperationIndex))
 	err := iterate(repo, rebase)
 	rebase.Free()
-	return err
+
+	if err != nil {
+		return err
+	}
+
+	return updateDescendants(repo, context)
+}
+
+func recursiveUpdatePath(repo *git.Repository) string {
+	return filepath.Join(repo.Path(), core.TieDir, recursiveUpdateFile)
+}
+
+// Replays the tips stacked on the tip that has just been updated, if the update is recursive.
+// They are chained in a single rewrite that stops on the first conflict.
+func updateDescendants(repo *git.Repository, context model.Context) error {
+	bytes, err := ioutil.ReadFile(recursiveUpdatePath(repo))
+	if err != nil {
+		return nil
+	}
+	os.Remove(recursiveUpdatePath(repo))
+
+	return updateStack(repo, string(bytes), nil, context)
+}
+
+// Replays the tips stacked on stackBase on their updated base. The tips stacked
+// directly on stackBase are moved onto newBase when it is set.
+func updateStack(repo *git.Repository, stackBase string, newBase *git.Reference, context model.Context) error {
+	bases := core.TipBases(repo)
+	var first, last *core.Rewrite
+
+	for _, tipName := range core.TipDescendants(repo, stackBase) {
+		commits, err := core.TipCommits(repo, tipName)
+		if err != nil {
+			return err
+		}
+
+		// Commits that already landed in the base are dropped
+		landed, _, _ := core.LandedCommits(repo, tipName)
+		steps := core.PickSteps(commits)
+		for i := range steps {
+			if landed[steps[i].Commit] {
+				steps[i].Action = core.RewriteDrop
+			}
+		}
+
+		// Head is left empty so that each tip is replayed on its updated base
+		rewrite := &core.Rewrite{
+			Tip:     tipName,
+			Todo:    steps,
+			Command: "update",
+		}
+		if newBase != nil && bases[tipName] == core.RefsTips+stackBase {
+			rewrite.Head = newBase.Target().String()
+			rewrite.Tail = rewrite.Head
+			rewrite.Base = newBase.Name()
+		}
+
+		if first == nil {
+			first = rewrite
+		} else {
+			last.Next = rewrite
+		}
+		last = rewrite
+	}
+
+	if first == nil {
+		return nil
+	}
+
+	return core.StartRewrite(repo, first, context)
 }
 
 func iterate(repo *git.Repository, rebase *git.Rebase) error {
+	headNameFilepath := filepath.Join(repo.Path(), rebaseMergeDir, headNameFile)
+	bytes, _ := ioutil.ReadFile(headNameFilepath)
+	headName := strings.Trim(string(bytes), "\n")
+	tipName, _ := core.TipName(headName)
+
+	landed, _, _ := core.LandedCommits(repo, tipName)
+
 	for operation, itErr := rebase.Next(); itErr == nil; operation, itErr = rebase.Next() {
+		if landed[operation.Id.String()] {
+			// The commit already landed in the base, drop its changes
+			head, _ := repo.Head()
+			headCommit, _ := repo.LookupCommit(head.Target())
+			repo.ResetToCommit(headCommit, git.ResetHard, &git.CheckoutOpts{Strategy: git.CheckoutForce})
+			continue
+		}
+
 		err := commit(repo, rebase, operation)
 		if err != nil {
 			return err
@@ -174,12 +462,8 @@ func iterate(repo *git.Repository, rebase *git.Rebase) error {
 	}
 
 	ontoFilePath := filepath.Join(repo.Path(), rebaseMergeDir, ontoNameFile)
-	headNameFilepath := filepath.Join(repo.Path(), rebaseMergeDir, headNameFile)
-	bytes, _ := ioutil.ReadFile(ontoFilePath)
+	bytes, _ = ioutil.ReadFile(ontoFilePath)
 	onto, _ := git.NewOid(strings.Trim(string(bytes), "\n"))
-	bytes, _ = ioutil.ReadFile(headNameFilepath)
-	headName := strings.Trim(string(bytes), "\n")
-	tipName, _ := core.TipName(headName)
 
 	repo.References.Create(core.RefsTails+tipName, onto, true, "tie update")
 
@@ -193,5 +477,27 @@ func commit(repo *git.Repository, rebase *git.Rebase, operation *git.RebaseOpera
 	}
 	commit, _ := repo.LookupCommit(operation.Id)
 	committer, _ := repo.DefaultSignature()
-	return rebase.Commit(operation.Id, commit.Author(), committer, commit.Message())
+	err := rebase.Commit(operation.Id, commit.Author(), committer, commit.Message())
+	if git.IsErrorCode(err, git.ErrApplied) {
+		// Nothing left to commit
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// libgit2 can't sign the commits of a rebase. Replace the one it just made by a signed copy,
+	// the next operations and the end of the rebase start from HEAD.
+	if signer := core.ReplaySigner(repo, commit); signer != nil {
+		head, _ := repo.Head()
+		headCommit, _ := repo.LookupCommit(head.Target())
+		tree, _ := headCommit.Tree()
+		oid, err := core.AmendCommit(repo, signer, "", headCommit, headCommit.Author(), headCommit.Committer(), headCommit.Message(), tree)
+		if err != nil {
+			return err
+		}
+		return repo.SetHeadDetached(oid)
+	}
+
+	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/libgit2/git2go.v25"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -27,7 +28,7 @@ func TestUpdateCommand(t *testing.T) {
 			remote, _ := another.Remotes.Lookup("origin")
 			remote.Push([]string{"+refs/heads/master"}, nil)
 
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 			assert.Nil(t, err)
 
 			originMaster, _ := repo.References.Lookup("refs/remotes/origin/master")
@@ -51,7 +52,7 @@ func TestUpdateCommand(t *testing.T) {
 			remote.Push([]string{"+refs/heads/master"}, nil)
 
 			context.OutputBuffer.Reset()
-			err = UpdateCommand(repo, context.Context)
+			err = UpdateCommand(repo, false, context.Context)
 			assert.Nil(t, err)
 
 			originMaster, _ = repo.References.Lookup("refs/remotes/origin/master")
@@ -73,7 +74,7 @@ func TestUpdateCommand(t *testing.T) {
 			remote, _ := another.Remotes.Lookup("origin")
 			remote.Push([]string{"+refs/heads/master"}, nil)
 
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 
 			// Commit again on master from "another"
 			test.WriteFile(another, true, "foo", "foofoo")
@@ -84,7 +85,7 @@ func TestUpdateCommand(t *testing.T) {
 			test.WriteFile(repo, true, "foo", "barbar")
 
 			context.OutputBuffer.Reset()
-			err = UpdateCommand(repo, context.Context)
+			err = UpdateCommand(repo, false, context.Context)
 			// The update should have failed
 			if assert.NotNil(t, err) {
 				assert.Equal(t, "Status should be clean before updating on a remote ref: refs/remotes/origin/master", err.Error())
@@ -100,7 +101,7 @@ func TestUpdateCommand(t *testing.T) {
 		test.RunOnRemote(t, "OnTip", func(t *testing.T, context test.TestContext, repo, origin *git.Repository) {
 			test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
 
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 			assert.Nil(t, err)
 		})
 
@@ -108,7 +109,7 @@ func TestUpdateCommand(t *testing.T) {
 			test.CreateTip(repo, "test", "refs/remotes/origin/master", false)
 			test.CreateTip(repo, "test2", core.RefsTips+"test", true)
 
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 			assert.Nil(t, err)
 		})
 
@@ -116,7 +117,7 @@ func TestUpdateCommand(t *testing.T) {
 			test.CreateTip(repo, "test", "refs/heads/master", false)
 			test.CreateTip(repo, "test2", core.RefsTips+"test", true)
 
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 			assert.Nil(t, err)
 		})
 
@@ -129,7 +130,7 @@ func TestUpdateCommand(t *testing.T) {
 				"refs/heads/master:refs/heads/to_be_deleted",
 			}, nil)
 
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 			assert.Nil(t, err)
 
 			// The two branches should have been fetched
@@ -150,7 +151,7 @@ func TestUpdateCommand(t *testing.T) {
 
 			// Reset the output buffer and rerun update
 			context.OutputBuffer.Reset()
-			err = UpdateCommand(repo, context.Context)
+			err = UpdateCommand(repo, false, context.Context)
 			assert.Nil(t, err)
 
 			// The branch should be pruned
@@ -172,7 +173,7 @@ func TestUpdateCommand(t *testing.T) {
 			repo.References.Create(core.RefsTips+"test", head.Target(), true, "")
 			repo.References.CreateSymbolic("HEAD", core.RefsTips+"test", true, "")
 
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 
 			// Upgrade requires a base to be defined
 			if assert.NotNil(t, err) {
@@ -190,7 +191,7 @@ func TestUpdateCommand(t *testing.T) {
 			config, _ := repo.Config()
 			config.SetString("tip.test.base", "refs/heads/master")
 
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 
 			// Upgrade requires the tip to have a tail
 			if assert.NotNil(t, err) {
@@ -227,7 +228,7 @@ func TestUpdateCommand(t *testing.T) {
 			})
 
 			// Do the upgrade
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 			assert.Nil(t, err)
 
 			// We expect the tip to be on top of origin/master
@@ -276,7 +277,7 @@ func TestUpdateCommand(t *testing.T) {
 			oidBeforeUpgrade, _ := test.Commit(repo, nil)
 
 			// Do the upgrade
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 			if assert.NotNil(t, err) {
 				assert.Equal(t, "Conflict while upgrading", err.Error())
 			}
@@ -312,7 +313,7 @@ func TestUpdateCommand(t *testing.T) {
 			test.Commit(repo, nil)
 
 			// Do the upgrade
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 			if assert.NotNil(t, err) {
 				assert.Equal(t, "Conflict while upgrading", err.Error())
 			}
@@ -327,7 +328,7 @@ func TestUpdateCommand(t *testing.T) {
 			test.WriteFile(repo, true, "foo", "line1 bis")
 
 			// Continue the upgrade
-			err = UpdateContinueCommand(repo)
+			err = UpdateContinueCommand(repo, context.Context)
 			assert.Nil(t, err)
 
 			// HEAD should be one commit ahead of master
@@ -356,7 +357,7 @@ func TestUpdateCommand(t *testing.T) {
 			origin.Push([]string{"refs/heads/master"}, nil)
 
 			// Do the upgrade
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
 
 			assert.Nil(t, err)
 
@@ -376,6 +377,48 @@ func TestUpdateCommand(t *testing.T) {
 			assert.NotNil(t, err)
 		})
 
+		test.RunOnRepo(t, "PartiallyLanded", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+			test.CreateTip(repo, "test", "refs/heads/master", true)
+			test.WriteFile(repo, true, "foo", "foo")
+			test.Commit(repo, nil)
+			test.WriteFile(repo, true, "bar", "bar")
+			test.Commit(repo, &test.CommitParams{Message: "bar"})
+
+			// foo lands on master with another parent
+			test.CommitFiles(repo, "refs/heads/master", map[string]string{"other": "other"})
+			masterOid := test.CommitFiles(repo, "refs/heads/master", map[string]string{"foo": "foo"})
+
+			err := UpdateCommand(repo, false, context.Context)
+			assert.Nil(t, err)
+
+			// Only bar is left on top of master
+			head, _ := repo.Head()
+			headCommit, _ := repo.LookupCommit(head.Target())
+			assert.Equal(t, "bar", headCommit.Message())
+			assert.True(t, headCommit.ParentId(0).Equal(masterOid))
+			test.StatusClean(t, repo)
+		})
+
+		test.RunOnRepo(t, "FullyLanded", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+			test.CreateTip(repo, "test", "refs/heads/master", true)
+			test.WriteFile(repo, true, "foo", "foo")
+			test.Commit(repo, nil)
+			test.WriteFile(repo, true, "bar", "bar")
+			oid, _ := test.Commit(repo, nil)
+
+			// The tip has been squashed on master
+			test.CommitFiles(repo, "refs/heads/master", map[string]string{"other": "other"})
+			test.CommitFiles(repo, "refs/heads/master", map[string]string{"foo": "foo", "bar": "bar"})
+
+			err := UpdateCommand(repo, false, context.Context)
+			assert.Nil(t, err)
+
+			// The tip is left as is
+			head, _ := repo.Head()
+			assert.True(t, head.Target().Equal(oid))
+			assert.Equal(t, "Tip 'test' has landed on 'refs/heads/master'. Delete it with 'tie delete --merged'.\n", context.OutputBuffer.String())
+		})
+
 		test.RunOnRepo(t, "DirtyStateError", func(t *testing.T, context test.TestContext, repo *git.Repository) {
 			// Create a tip on head based on refs/remotes/origin/master
 			test.CreateTip(repo, "test", "refs/heads/master", true)
@@ -385,9 +428,302 @@ func TestUpdateCommand(t *testing.T) {
 
 			test.WriteFile(repo, false, "foo", "bar")
 
-			err := UpdateCommand(repo, context.Context)
+			err := UpdateCommand(repo, false, context.Context)
+
+			assert.NotNil(t, err)
+		})
+	})
+
+	t.Run("Recursive", func(t *testing.T) {
+
+		// Selects the tip and checks it out
+		selectTip := func(repo *git.Repository, tipName string) {
+			tip, _ := repo.References.Lookup(core.RefsTips + tipName)
+			commit, _ := repo.LookupCommit(tip.Target())
+			tree, _ := commit.Tree()
+			repo.CheckoutTree(tree, &git.CheckoutOpts{Strategy: git.CheckoutForce})
+			repo.References.CreateSymbolic("HEAD", tip.Name(), true, "")
+		}
+
+		// Creates the tip on top of the selected one, with one commit adding file
+		stackTip := func(repo *git.Repository, tipName, base, file string) *git.Oid {
+			test.CreateTip(repo, tipName, base, true)
+			test.WriteFile(repo, true, file, tipName)
+			oid, _ := test.Commit(repo, nil)
+			return oid
+		}
+
+		test.RunOnRemote(t, "UpdateDescendants", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+			test.CreateTip(repo, "a", "refs/remotes/origin/master", false)
+
+			// Move origin/master forward
+			masterOid, _ := test.Commit(repo, nil)
+			origin, _ := repo.Remotes.Lookup("origin")
+			origin.Push([]string{"refs/heads/master"}, nil)
+
+			// b is stacked on a, c is stacked on b
+			selectTip(repo, "a")
+			test.WriteFile(repo, true, "a", "a")
+			test.Commit(repo, nil)
+			stackTip(repo, "b", core.RefsTips+"a", "b")
+			stackTip(repo, "c", core.RefsTips+"b", "c")
+			selectTip(repo, "a")
+
+			err := UpdateCommand(repo, true, context.Context)
+			assert.Nil(t, err)
+
+			tip := func(tipName string) *git.Commit {
+				ref, _ := repo.References.Lookup(core.RefsTips + tipName)
+				commit, _ := repo.LookupCommit(ref.Target())
+				return commit
+			}
+
+			tail := func(tipName string) *git.Oid {
+				ref, _ := repo.References.Lookup(core.RefsTails + tipName)
+				return ref.Target()
+			}
+
+			// Each tip has been replayed on its updated base
+			assert.True(t, tip("a").ParentId(0).Equal(masterOid))
+			assert.True(t, tip("b").ParentId(0).Equal(tip("a").Id()))
+			assert.True(t, tip("c").ParentId(0).Equal(tip("b").Id()))
+			assert.True(t, tail("b").Equal(tip("a").Id()))
+			assert.True(t, tail("c").Equal(tip("b").Id()))
+
+			// The stacked tips are pushed too
+			for _, tipName := range []string{"b", "c"} {
+				remoteTip, err := remote.References.Lookup(core.RefsTips + tipName)
+				if assert.Nil(t, err) {
+					assert.True(t, remoteTip.Target().Equal(tip(tipName).Id()))
+				}
+			}
+
+			// a is still selected
+			head, _ := repo.Head()
+			assert.Equal(t, core.RefsTips+"a", head.Name())
+			test.StatusClean(t, repo)
+			assert.False(t, core.RewriteInProgress(repo))
+		})
+
+		test.RunOnRepo(t, "LandedTip", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+			test.CreateTip(repo, "a", "refs/heads/master", false)
+			selectTip(repo, "a")
+			test.WriteFile(repo, true, "a", "a")
+			test.Commit(repo, nil)
+			stackTip(repo, "b", core.RefsTips+"a", "b")
+			stackTip(repo, "c", core.RefsTips+"b", "c")
+
+			// a has been squashed on master
+			test.CommitFiles(repo, "refs/heads/master", map[string]string{"other": "other"})
+			masterOid := test.CommitFiles(repo, "refs/heads/master", map[string]string{"a": "a"})
+			selectTip(repo, "a")
+
+			err := UpdateCommand(repo, true, context.Context)
+			assert.Nil(t, err)
+			assert.Contains(t, context.OutputBuffer.String(), "Tip 'a' has landed on 'refs/heads/master'. Delete it with 'tie delete --merged'.\n")
+
+			tip := func(tipName string) *git.Commit {
+				ref, _ := repo.References.Lookup(core.RefsTips + tipName)
+				commit, _ := repo.LookupCommit(ref.Target())
+				return commit
+			}
+
+			// b has been moved onto master and c replayed on b
+			assert.True(t, tip("b").ParentId(0).Equal(masterOid))
+			assert.True(t, tip("c").ParentId(0).Equal(tip("b").Id()))
+			bTail, _ := repo.References.Lookup(core.RefsTails + "b")
+			assert.True(t, bTail.Target().Equal(masterOid))
+			config, _ := repo.Config()
+			bBase, _ := config.LookupString("tip.b.base")
+			assert.Equal(t, "refs/heads/master", bBase)
+
+			head, _ := repo.Head()
+			assert.Equal(t, core.RefsTips+"a", head.Name())
+			test.StatusClean(t, repo)
+			assert.False(t, core.RewriteInProgress(repo))
+		})
+
+		test.RunOnRemote(t, "ConflictContinue", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+			head, _ := repo.Head()
+			test.CreateTip(repo, "a", "refs/heads/master", false)
+
+			// master conflicts with b
+			test.WriteFile(repo, true, "foo", "master")
+			test.Commit(repo, nil)
+			firstCommit, _ := repo.LookupCommit(head.Target())
+			tree, _ := firstCommit.Tree()
+			repo.CheckoutTree(tree, &git.CheckoutOpts{Strategy: git.CheckoutForce})
+			repo.References.CreateSymbolic("HEAD", core.RefsTips+"a", true, "")
+
+			test.WriteFile(repo, true, "a", "a")
+			test.Commit(repo, nil)
+			bOid := stackTip(repo, "b", core.RefsTips+"a", "foo")
+			cOid := stackTip(repo, "c", core.RefsTips+"b", "c")
+			selectTip(repo, "a")
+
+			err := UpdateCommand(repo, true, context.Context)
+			if assert.NotNil(t, err) {
+				assert.Equal(t, "Conflict while rewriting "+bOid.String()[:7]+". Resolve it and run 'tie update continue'.", err.Error())
+			}
+
+			// The update stopped on b, c is left untouched
+			assert.True(t, core.RewriteInProgress(repo))
+			head, _ = repo.Head()
+			assert.Equal(t, core.RefsTips+"b", head.Name())
+			c, _ := repo.References.Lookup(core.RefsTips + "c")
+			assert.True(t, c.Target().Equal(cOid))
+
+			// Resolve the conflict
+			test.WriteFile(repo, true, "foo", "b")
+
+			err = UpdateContinueCommand(repo, context.Context)
+			assert.Nil(t, err)
+			assert.False(t, core.RewriteInProgress(repo))
+
+			// c has been replayed on top of b
+			a, _ := repo.References.Lookup(core.RefsTips + "a")
+			b, _ := repo.References.Lookup(core.RefsTips + "b")
+			c, _ = repo.References.Lookup(core.RefsTips + "c")
+			bCommit, _ := repo.LookupCommit(b.Target())
+			cCommit, _ := repo.LookupCommit(c.Target())
+			assert.True(t, bCommit.ParentId(0).Equal(a.Target()))
+			assert.True(t, cCommit.ParentId(0).Equal(b.Target()))
+
+			cTail, _ := repo.References.Lookup(core.RefsTails + "c")
+			assert.True(t, cTail.Target().Equal(b.Target()))
+		})
+	})
+
+	t.Run("All", func(t *testing.T) {
+
+		test.RunOnRemote(t, "Summary", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+			head, _ := repo.Head()
+			initial, _ := repo.LookupCommit(head.Target())
+			initialTree, _ := initial.Tree()
+
+			selectTip := func(tipName string) {
+				repo.CheckoutTree(initialTree, &git.CheckoutOpts{Strategy: git.CheckoutForce})
+				repo.References.CreateSymbolic("HEAD", core.RefsTips+tipName, true, "")
+			}
+
+			repo.References.Create("refs/heads/other", initial.Id(), false, "")
+			test.CreateTip(repo, "clean", "refs/remotes/origin/master", false)
+			test.CreateTip(repo, "conflict", "refs/remotes/origin/master", false)
+			test.CreateTip(repo, "uptodate", "refs/heads/other", false)
+			test.CreateTip(repo, "missing", "refs/heads/deleted", false)
+
+			// Move origin/master forward
+			test.WriteFile(repo, true, "foo", "master")
+			masterOid, _ := test.Commit(repo, nil)
+			origin, _ := repo.Remotes.Lookup("origin")
+			origin.Push([]string{"refs/heads/master"}, nil)
+
+			selectTip("clean")
+			test.WriteFile(repo, true, "bar", "clean")
+			test.Commit(repo, nil)
+			test.CreateTip(repo, "stacked", core.RefsTips+"clean", true)
+			test.WriteFile(repo, true, "baz", "stacked")
+			test.Commit(repo, nil)
+
+			selectTip("conflict")
+			test.WriteFile(repo, true, "foo", "conflict")
+			conflictOid, _ := test.Commit(repo, nil)
+
+			selectTip("uptodate")
+
+			err := UpdateAllCommand(repo, context.Context)
+			assert.Nil(t, err)
+
+			commit := func(tipName string) *git.Commit {
+				tip, _ := repo.References.Lookup(core.RefsTips + tipName)
+				commit, _ := repo.LookupCommit(tip.Target())
+				return commit
+			}
+
+			// The clean tips have been replayed and pushed
+			assert.True(t, commit("clean").ParentId(0).Equal(masterOid))
+			assert.True(t, commit("stacked").ParentId(0).Equal(commit("clean").Id()))
+			for _, tipName := range []string{"clean", "stacked"} {
+				remoteTip, err := remote.References.Lookup(core.RefsTips + tipName)
+				if assert.Nil(t, err) {
+					assert.True(t, remoteTip.Target().Equal(commit(tipName).Id()))
+				}
+			}
+
+			// The conflicting tip is untouched
+			assert.True(t, commit("conflict").Id().Equal(conflictOid))
+			conflictTail, _ := repo.References.Lookup(core.RefsTails + "conflict")
+			assert.True(t, conflictTail.Target().Equal(initial.Id()))
 
+			test.StatusClean(t, repo)
+
+			assert.Contains(t, context.OutputBuffer.String(),
+				"clean     updated\n"+
+					"stacked   updated\n"+
+					"conflict  conflict\n"+
+					"missing   base missing\n"+
+					"uptodate  up to date\n"+
+					"2 updated, 1 up to date, 0 landed, 1 conflict, 1 base missing\n")
+		})
+
+		test.RunOnRemote(t, "PushFailure", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+			test.CreateTip(repo, "test", "refs/remotes/origin/master", false)
+			test.CommitFiles(repo, core.RefsTips+"test", map[string]string{"bar": "test"})
+
+			// Move origin/master forward
+			test.WriteFile(repo, true, "foo", "master")
+			test.Commit(repo, nil)
+			origin, _ := repo.Remotes.Lookup("origin")
+			origin.Push([]string{"refs/heads/master"}, nil)
+
+			test.WriteHook(repo, core.HookPrePush, "exit 1")
+
+			err := UpdateAllCommand(repo, context.Context)
+			assert.Nil(t, err)
+
+			// The tip is updated locally only
+			_, err = remote.References.Lookup(core.RefsTips + "test")
 			assert.NotNil(t, err)
+			assert.Contains(t, context.OutputBuffer.String(),
+				"test  not pushed (The pre-push hook failed (exit status 1).)\n"+
+					"0 updated, 0 up to date, 0 landed, 0 conflict, 0 base missing, 1 not pushed\n")
+		})
+
+		test.RunOnRemote(t, "UnbornHead", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+			test.CreateTip(repo, "test", "refs/remotes/origin/master", false)
+
+			// The fetch creates origin/other
+			origin, _ := repo.Remotes.Lookup("origin")
+			origin.Push([]string{"refs/heads/master:refs/heads/other"}, nil)
+			if other, err := repo.References.Lookup("refs/remotes/origin/other"); err == nil {
+				other.Delete()
+			}
+
+			// Nothing checked out
+			repo.References.CreateSymbolic("HEAD", "refs/heads/unborn", true, "")
+			index, _ := repo.Index()
+			index.RemoveAll([]string{"*"}, nil)
+			index.Write()
+			entries, _ := ioutil.ReadDir(repo.Workdir())
+			for _, entry := range entries {
+				if entry.Name() != ".git" {
+					os.RemoveAll(filepath.Join(repo.Workdir(), entry.Name()))
+				}
+			}
+
+			err := UpdateAllCommand(repo, context.Context)
+			assert.Nil(t, err)
+			assert.Contains(t, context.OutputBuffer.String(), "Created refs/remotes/origin/other\n")
+		})
+
+		test.RunOnRepo(t, "DirtyStateError", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+			test.CreateTip(repo, "test", "refs/heads/master", true)
+			test.WriteFile(repo, true, "foo", "bar")
+
+			err := UpdateAllCommand(repo, context.Context)
+			if assert.NotNil(t, err) {
+				assert.Equal(t, "Status should be clean before updating all tips.", err.Error())
+			}
 		})
 	})
 }
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+)
+
+// Lists the works in progress saved by select, one line per tip:
+// the tip, the commit the changes were made on and the number of changed files
+func WipCommand(repo *git.Repository, context model.Context) error {
+	wips, err := core.ListWip(repo)
+	if err != nil {
+		return err
+	}
+
+	if len(wips) == 0 {
+		context.Logger.Println("No work in progress.")
+		return nil
+	}
+
+	for _, wip := range wips {
+		base := wip.Commit.Parent(0)
+		plural := ""
+		if wip.Files > 1 {
+			plural = "s"
+		}
+		context.Logger.Printf("%v  %v %v (%v file%v)\n", wip.Tip, base.Id().String()[:7], base.Summary(), wip.Files, plural)
+	}
+
+	return nil
+}
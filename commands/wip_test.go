@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestWipCommand(t *testing.T) {
+	test.RunOnRepo(t, "List", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteFile(repo, true, "foo", "a")
+		test.Commit(repo, nil)
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		test.WriteFile(repo, false, "foo", "b")
+		core.SaveWip(repo, "test")
+
+		head, _ := repo.Head()
+		err := WipCommand(repo, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t, "test  "+head.Target().String()[:7]+" default message (1 file)\n", context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "Empty", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		err := WipCommand(repo, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t, "No work in progress.\n", context.OutputBuffer.String())
+	})
+}
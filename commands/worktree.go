@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+)
+
+// Creates the linked worktree of the tip, or reuses the one it already has, and prints its path.
+// An empty path puts the worktree at its default location.
+func WorktreeCommand(repo *git.Repository, tip, path string, context model.Context) error {
+	tipName, err := resolveTip(repo, tip, "A tip is required.")
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		path = core.DefaultWorktreePath(repo, tipName)
+	}
+
+	worktree, err := core.AddWorktree(repo, tipName, path)
+	if err != nil {
+		return err
+	}
+
+	context.Logger.Printf("Tip '%v' is checked out in %v\n", tipName, worktree.Path)
+
+	return nil
+}
+
+// Lists the linked worktrees of the tips
+func WorktreeListCommand(repo *git.Repository, context model.Context) error {
+	for _, worktree := range core.TipWorktrees(repo) {
+		context.Logger.Printf("%v  %v\n", worktree.Tip, worktree.Path)
+	}
+
+	return nil
+}
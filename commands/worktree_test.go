@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"github.com/apflieger/tie/core"
+	"github.com/apflieger/tie/model"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorktreeCommand(t *testing.T) {
+	test.RunOnRepo(t, "CreateAndList", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		dir, _ := ioutil.TempDir("", "tie-worktrees-")
+		defer os.RemoveAll(dir)
+		config, _ := repo.Config()
+		config.SetString(core.WorktreesDirConfigKey, dir)
+		test.CreateTip(repo, "a", "refs/heads/master", false)
+		test.CreateTip(repo, "b", "refs/heads/master", false)
+		aPath := filepath.Join(dir, "elsewhere")
+		bPath := filepath.Join(dir, "b")
+
+		err := WorktreeCommand(repo, "a", aPath, context.Context)
+		assert.Nil(t, err)
+		err = WorktreeCommand(repo, "b", "", context.Context)
+		assert.Nil(t, err)
+
+		// The worktree of a is reused
+		err = WorktreeCommand(repo, core.RefsTips+"a", "", context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t,
+			"Tip 'a' is checked out in "+aPath+"\n"+
+				"Tip 'b' is checked out in "+bPath+"\n"+
+				"Tip 'a' is checked out in "+aPath+"\n",
+			context.OutputBuffer.String())
+
+		context.OutputBuffer.Reset()
+		err = WorktreeListCommand(repo, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t, "b  "+bPath+"\na  "+aPath+"\n", context.OutputBuffer.String())
+
+		err = WorktreeCommand(repo, "missing", "", context.Context)
+		assert.Equal(t, "Tip 'missing' doesn't exist.", err.Error())
+	})
+
+	test.RunOnRepo(t, "DeletePrunes", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		dir, _ := ioutil.TempDir("", "tie-worktrees-")
+		defer os.RemoveAll(dir)
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		path := filepath.Join(dir, "test")
+		WorktreeCommand(repo, "test", path, context.Context)
+
+		// Uncommitted changes keep the tip and its worktree
+		ioutil.WriteFile(filepath.Join(path, "foo"), []byte("changed"), 0644)
+		err := DeleteCommand(repo, false, false, []string{core.RefsTips + "test"}, context.Context)
+		assert.Equal(t, "Worktree of tip 'test' at "+path+" has uncommitted changes.", err.Error())
+		_, err = repo.References.Lookup(core.RefsTips + "test")
+		assert.Nil(t, err)
+
+		os.RemoveAll(path)
+		err = DeleteCommand(repo, false, false, []string{core.RefsTips + "test"}, context.Context)
+		assert.Nil(t, err)
+
+		context.OutputBuffer.Reset()
+		WorktreeListCommand(repo, context.Context)
+		assert.Empty(t, context.OutputBuffer.String())
+		assert.Empty(t, core.TipWorktrees(repo))
+	})
+
+	test.RunOnRepo(t, "CommandsInWorktree", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		dir, _ := ioutil.TempDir("", "tie-worktrees-")
+		defer os.RemoveAll(dir)
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		test.CreateTip(repo, "other", "refs/heads/master", false)
+		path := filepath.Join(dir, "test")
+		WorktreeCommand(repo, "test", path, context.Context)
+
+		// tie run from the worktree
+		linked, err := core.DiscoverRepository(path)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		test.WriteFile(linked, true, "foo", "worktree")
+		err = CommitCommand(linked, "in the worktree", model.OptionMissing, false, false, context.Context)
+		assert.Nil(t, err)
+
+		// The tip moved, the main worktree didn't
+		tip, _ := repo.References.Lookup(core.RefsTips + "test")
+		commit, _ := repo.LookupCommit(tip.Target())
+		assert.Equal(t, "in the worktree\n", commit.Message())
+		head, _ := repo.Head()
+		assert.Equal(t, "refs/heads/master", head.Name())
+		test.StatusClean(t, repo)
+		test.StatusClean(t, linked)
+
+		context.OutputBuffer.Reset()
+		err = StatusCommand(linked, context.Context)
+		assert.Nil(t, err)
+		assert.Equal(t, "On tip 'test' based on 'refs/heads/master'\n1 commit ahead, 0 behind 'refs/heads/master'\n", context.OutputBuffer.String())
+
+		// Selecting another tip in the worktree moves it there
+		err = SelectCommand(linked, "other", true, context.Context)
+		assert.Nil(t, err)
+		linkedHead, _ := linked.Head()
+		assert.Equal(t, core.RefsTips+"other", linkedHead.Name())
+		_, err = ioutil.ReadFile(filepath.Join(path, "foo"))
+		assert.NotNil(t, err)
+		assert.Nil(t, core.TipWorktree(repo, "test"))
+
+		err = SelectCommand(repo, "other", true, context.Context)
+		assert.Equal(t, "Tip 'other' is checked out in the worktree "+path+".", err.Error())
+		err = SelectCommand(repo, "test", true, context.Context)
+		assert.Nil(t, err)
+		err = SelectCommand(linked, "test", true, context.Context)
+		assert.Equal(t, "Tip 'test' is selected in the main worktree.", err.Error())
+	})
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+)
+
+// Descriptions of the tips are kept in the message of commits pointed by refs/tipmeta/<tip>,
+// so that they can be pushed along with the tips. Each version of a description is
+// a child of the previous one.
+const RefsTipMeta = "refs/tipmeta/"
+
+// Returns the description of the tip, empty if it has none
+func TipDescription(repo *git.Repository, tipName string) string {
+	meta, err := repo.References.Lookup(RefsTipMeta + tipName)
+	if err != nil {
+		return ""
+	}
+
+	commit, err := repo.LookupCommit(meta.Target())
+	if err != nil {
+		return ""
+	}
+
+	return commit.Message()
+}
+
+// Returns the first line of the description of the tip
+func TipTitle(repo *git.Repository, tipName string) string {
+	return strings.SplitN(TipDescription(repo, tipName), "\n", 2)[0]
+}
+
+// Records a new version of the description. An empty description removes it.
+func SetTipDescription(repo *git.Repository, tipName, description string) error {
+	refname := RefsTipMeta + tipName
+	meta, noMeta := repo.References.Lookup(refname)
+
+	if len(strings.TrimSpace(description)) == 0 {
+		if noMeta != nil {
+			return nil
+		}
+		return meta.Delete()
+	}
+
+	parents := []*git.Commit{}
+	if noMeta == nil {
+		parent, err := repo.LookupCommit(meta.Target())
+		if err != nil {
+			return err
+		}
+		parents = append(parents, parent)
+	}
+
+	builder, err := repo.TreeBuilder()
+	if err != nil {
+		return err
+	}
+	defer builder.Free()
+
+	treeOid, err := builder.Write()
+	if err != nil {
+		return err
+	}
+
+	tree, err := repo.LookupTree(treeOid)
+	if err != nil {
+		return err
+	}
+
+	signature, err := repo.DefaultSignature()
+	if err != nil {
+		return err
+	}
+
+	oid, err := repo.CreateCommit("", signature, signature, description, tree, parents...)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.References.Create(refname, oid, true, "tie describe")
+	return err
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestSetTipDescription(t *testing.T) {
+	test.RunOnRepo(t, "Versions", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		assert.Equal(t, "", TipDescription(repo, "test"))
+
+		err := SetTipDescription(repo, "test", "Title\n\nFirst version\n")
+		assert.Nil(t, err)
+		assert.Equal(t, "Title\n\nFirst version\n", TipDescription(repo, "test"))
+		assert.Equal(t, "Title", TipTitle(repo, "test"))
+
+		first, _ := repo.References.Lookup(RefsTipMeta + "test")
+
+		err = SetTipDescription(repo, "test", "New title\n")
+		assert.Nil(t, err)
+		assert.Equal(t, "New title", TipTitle(repo, "test"))
+
+		// The previous version is kept as the parent
+		meta, _ := repo.References.Lookup(RefsTipMeta + "test")
+		commit, _ := repo.LookupCommit(meta.Target())
+		assert.Equal(t, uint(1), commit.ParentCount())
+		assert.True(t, commit.ParentId(0).Equal(first.Target()))
+
+		// An empty description removes it
+		err = SetTipDescription(repo, "test", "  \n")
+		assert.Nil(t, err)
+		_, err = repo.References.Lookup(RefsTipMeta + "test")
+		assert.NotNil(t, err)
+		assert.Equal(t, "", TipTitle(repo, "test"))
+	})
+
+	test.RunOnRemote(t, "TravelsWithTheTip", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", false)
+		SetTipDescription(repo, "test", "Title\n")
+
+		err := PushTip(repo, "test", context.Context)
+		assert.Nil(t, err)
+		meta, _ := repo.References.Lookup(RefsTipMeta + "test")
+		remoteMeta, err := remote.References.Lookup(RefsTipMeta + "test")
+		assert.Nil(t, err)
+		assert.True(t, remoteMeta.Target().Equal(meta.Target()))
+
+		// Deleting the tip deletes its description, locally and on the remote
+		err = DeleteTip(repo, "test", context.Context)
+		assert.Nil(t, err)
+		_, err = repo.References.Lookup(RefsTipMeta + "test")
+		assert.NotNil(t, err)
+		_, err = remote.References.Lookup(RefsTipMeta + "test")
+		assert.NotNil(t, err)
+	})
+}
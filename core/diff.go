@@ -0,0 +1,134 @@
+package core
+
+import (
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+)
+
+// Returns the diff between the first parent of the commit and the commit
+func CommitDiff(repo *git.Repository, commit *git.Commit) (*git.Diff, error) {
+	var parentTree *git.Tree
+	if commit.ParentCount() > 0 {
+		parentTree, _ = commit.Parent(0).Tree()
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.DiffTreeToTree(parentTree, tree, nil)
+}
+
+// Returns the diff in the unified format
+func DiffPatch(diff *git.Diff) (string, error) {
+	deltas, err := diff.NumDeltas()
+	if err != nil {
+		return "", err
+	}
+
+	text := []string{}
+	for i := 0; i < deltas; i++ {
+		patch, err := diff.Patch(i)
+		if err != nil {
+			return "", err
+		}
+		patchText, err := patch.String()
+		patch.Free()
+		if err != nil {
+			return "", err
+		}
+		text = append(text, patchText)
+	}
+
+	return strings.Join(text, ""), nil
+}
+
+// Returns the files changed by the diff with their count of insertions and deletions, like git diff --stat
+func DiffStat(diff *git.Diff) (string, error) {
+	stats, err := diff.Stats()
+	if err != nil {
+		return "", err
+	}
+	defer stats.Free()
+
+	return stats.String(git.DiffStatsFull, 80)
+}
+
+// Returns the cumulative change introduced by the tip, from its tail to its head
+func TipDiff(repo *git.Repository, tipName string) (*git.Diff, error) {
+	tip, err := repo.References.Lookup(RefsTips + tipName)
+	if err != nil {
+		return nil, err
+	}
+
+	tail, err := repo.References.Lookup(RefsTails + tipName)
+	if err != nil {
+		return nil, err
+	}
+
+	tipTree, err := refTree(repo, tip)
+	if err != nil {
+		return nil, err
+	}
+
+	tailTree, err := refTree(repo, tail)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.DiffTreeToTree(tailTree, tipTree, nil)
+}
+
+func refTree(repo *git.Repository, ref *git.Reference) (*git.Tree, error) {
+	commit, err := repo.LookupCommit(ref.Target())
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+func deltaLetter(status git.Delta) string {
+	switch status {
+	case git.DeltaAdded:
+		return "A"
+	case git.DeltaDeleted:
+		return "D"
+	case git.DeltaModified:
+		return "M"
+	case git.DeltaRenamed:
+		return "R"
+	case git.DeltaCopied:
+		return "C"
+	case git.DeltaTypeChange:
+		return "T"
+	}
+	return "X"
+}
+
+// Returns one line per changed file, like git diff --name-status
+func DiffNameStatus(diff *git.Diff) (string, error) {
+	deltas, err := diff.NumDeltas()
+	if err != nil {
+		return "", err
+	}
+
+	text := ""
+	for i := 0; i < deltas; i++ {
+		delta, err := diff.GetDelta(i)
+		if err != nil {
+			return "", err
+		}
+
+		letter := deltaLetter(delta.Status)
+
+		if delta.Status == git.DeltaRenamed || delta.Status == git.DeltaCopied {
+			text += fmt.Sprintf("%v%03d\t%v\t%v\n", letter, delta.Similarity, delta.OldFile.Path, delta.NewFile.Path)
+		} else {
+			text += fmt.Sprintf("%v\t%v\n", letter, delta.NewFile.Path)
+		}
+	}
+
+	return text, nil
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestCommitDiff(t *testing.T) {
+	test.RunOnRepo(t, "PatchAndStat", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		oid := test.CommitFiles(repo, "refs/heads/master", map[string]string{"foo": "foo\n"})
+		commit, _ := repo.LookupCommit(oid)
+
+		diff, err := CommitDiff(repo, commit)
+		assert.Nil(t, err)
+		defer diff.Free()
+
+		patch, err := DiffPatch(diff)
+		assert.Nil(t, err)
+		assert.Contains(t, patch, "diff --git a/foo b/foo\nnew file mode 100644\n")
+		assert.Contains(t, patch, "--- /dev/null\n+++ b/foo\n@@ -0,0 +1 @@\n+foo\n")
+
+		stat, err := DiffStat(diff)
+		assert.Nil(t, err)
+		assert.Contains(t, stat, " foo | 1 +\n")
+		assert.Contains(t, stat, "1 file changed, 1 insertion(+)")
+	})
+}
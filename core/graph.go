@@ -0,0 +1,127 @@
+package core
+
+import (
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"sort"
+	"strings"
+)
+
+// Returns the base of every tip, indexed by tip name
+func TipBases(repo *git.Repository) map[string]string {
+	bases := map[string]string{}
+
+	config, _ := repo.Config()
+	it, err := config.NewIteratorGlob(`^tip\..*\.base$`)
+	if err != nil {
+		return bases
+	}
+	defer it.Free()
+
+	for entry, end := it.Next(); end == nil; entry, end = it.Next() {
+		tipName := strings.TrimSuffix(strings.TrimPrefix(entry.Name, "tip."), ".base")
+		bases[tipName] = entry.Value
+	}
+
+	return bases
+}
+
+// Returns the tips stacked on tipName, directly or not. A tip always comes
+// after its base, so that they can be updated in this order.
+func TipDescendants(repo *git.Repository, tipName string) []string {
+	children := map[string][]string{}
+	for tip, base := range TipBases(repo) {
+		if baseTip, err := TipName(base); err == nil {
+			children[baseTip] = append(children[baseTip], tip)
+		}
+	}
+
+	descendants := []string{}
+	visited := map[string]bool{tipName: true}
+	queue := []string{tipName}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		sort.Strings(children[current])
+		for _, child := range children[current] {
+			// Guards against tips based on each other
+			if visited[child] {
+				continue
+			}
+			visited[child] = true
+			descendants = append(descendants, child)
+			queue = append(queue, child)
+		}
+	}
+
+	return descendants
+}
+
+// Returns the remote where the tips based on refname are pushed
+func RemoteOf(refname string, config *git.Config) (string, error) {
+	visited := map[string]bool{}
+
+	for !visited[refname] {
+		visited[refname] = true
+
+		remoteName, _, err := ExplodeRemoteRef(refname)
+		if err == nil {
+			return remoteName, nil
+		}
+
+		tipName, err := TipName(refname)
+		if err != nil {
+			return "", err
+		}
+
+		refname, _ = config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
+	}
+
+	return "", fmt.Errorf("'%v' is not based on a remote ref.", refname)
+}
+
+// Returns the name of every tip in refs/tips, each one after its base
+func TipsInOrder(repo *git.Repository) []string {
+	names := []string{}
+	exists := map[string]bool{}
+
+	it, _ := repo.NewReferenceIteratorGlob(RefsTips + "*")
+	refNames := it.Names()
+	for name, end := refNames.Next(); end == nil; name, end = refNames.Next() {
+		tipName, _ := TipName(name)
+		names = append(names, tipName)
+		exists[tipName] = true
+	}
+	sort.Strings(names)
+
+	bases := TipBases(repo)
+	ordered := []string{}
+	added := map[string]bool{}
+
+	add := func(tipName string) {
+		if exists[tipName] && !added[tipName] {
+			added[tipName] = true
+			ordered = append(ordered, tipName)
+		}
+	}
+
+	for _, tipName := range names {
+		// Stacked tips come with their base
+		if baseTip, err := TipName(bases[tipName]); err == nil && exists[baseTip] {
+			continue
+		}
+		add(tipName)
+		for _, descendant := range TipDescendants(repo, tipName) {
+			add(descendant)
+		}
+	}
+
+	// Tips based on each other
+	for _, tipName := range names {
+		add(tipName)
+	}
+
+	return ordered
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestTipDescendants(t *testing.T) {
+	test.RunOnRepo(t, "TopologicalOrder", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "a", "refs/heads/master", false)
+		test.CreateTip(repo, "c", RefsTips+"b", false)
+		test.CreateTip(repo, "b", RefsTips+"a", false)
+		test.CreateTip(repo, "d", RefsTips+"a", false)
+		test.CreateTip(repo, "other", "refs/heads/master", false)
+
+		assert.Equal(t, []string{"b", "d", "c"}, TipDescendants(repo, "a"))
+		assert.Equal(t, []string{"c"}, TipDescendants(repo, "b"))
+		assert.Equal(t, []string{}, TipDescendants(repo, "other"))
+	})
+
+	test.RunOnRepo(t, "Cycle", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "a", RefsTips+"b", false)
+		test.CreateTip(repo, "b", RefsTips+"a", false)
+
+		assert.Equal(t, []string{"b"}, TipDescendants(repo, "a"))
+	})
+}
+
+func TestTipsInOrder(t *testing.T) {
+	test.RunOnRepo(t, "BasesFirst", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "c", RefsTips+"b", false)
+		test.CreateTip(repo, "b", RefsTips+"a", false)
+		test.CreateTip(repo, "a", "refs/heads/master", false)
+		test.CreateTip(repo, "d", "refs/heads/master", false)
+		// Based on a tip that doesn't exist anymore
+		test.CreateTip(repo, "e", RefsTips+"deleted", false)
+
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, TipsInOrder(repo))
+	})
+}
+
+func TestRemoteOf(t *testing.T) {
+	test.RunOnRepo(t, "StackedTips", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "a", "refs/remotes/origin/master", false)
+		test.CreateTip(repo, "b", RefsTips+"a", false)
+		test.CreateTip(repo, "local", "refs/heads/master", false)
+		config, _ := repo.Config()
+
+		remote, err := RemoteOf(RefsTips+"b", config)
+		assert.Nil(t, err)
+		assert.Equal(t, "origin", remote)
+
+		remote, err = RemoteOf(RefsRemoteTips+"upstream/test", config)
+		assert.Nil(t, err)
+		assert.Equal(t, "upstream", remote)
+
+		_, err = RemoteOf(RefsTips+"local", config)
+		assert.NotNil(t, err)
+	})
+}
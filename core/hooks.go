@@ -0,0 +1,131 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// libgit2 doesn't run the hooks, so tie runs the ones git would run for its commands
+const (
+	HookPreCommit        = "pre-commit"
+	HookPrepareCommitMsg = "prepare-commit-msg"
+	HookCommitMsg        = "commit-msg"
+	HookPostCheckout     = "post-checkout"
+	HookPrePush          = "pre-push"
+)
+
+const zeroOid = "0000000000000000000000000000000000000000"
+
+// Returns the directory of the hooks, core.hooksPath if it's set.
+// The hooks are shared by all the worktrees.
+func HooksDir(repo *git.Repository) string {
+	config, _ := repo.Config()
+	hooksPath, err := config.LookupString("core.hooksPath")
+
+	if err != nil || hooksPath == "" {
+		return filepath.Join(CommonDir(repo), "hooks")
+	}
+
+	// Like git, relative paths are relative to the top of the working tree
+	if !filepath.IsAbs(hooksPath) && !repo.IsBare() {
+		return filepath.Join(repo.Workdir(), hooksPath)
+	}
+
+	return hooksPath
+}
+
+// Runs the hook with the given arguments and stdin. Missing or non executable hooks are ignored.
+// The hook fails if it exits with a non-zero status.
+func RunHook(repo *git.Repository, name, stdin string, args ...string) error {
+	hook := filepath.Join(HooksDir(repo), name)
+
+	info, err := os.Stat(hook)
+	if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+		return nil
+	}
+
+	cmd := exec.Command(hook, args...)
+	if !repo.IsBare() {
+		cmd.Dir = repo.Workdir()
+	}
+	// git sends the output of the hooks to stderr
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+
+	if err := cmd.Run(); err != nil {
+		return &HookError{Hook: name, Err: err}
+	}
+
+	return nil
+}
+
+// Returned when a hook exits with a non-zero status
+type HookError struct {
+	Hook string
+	Err  error
+}
+
+func (err *HookError) Error() string {
+	return fmt.Sprintf("The %v hook failed (%v).", err.Hook, err.Err)
+}
+
+func IsHookError(err error) bool {
+	_, isHookErr := err.(*HookError)
+	return isHookErr
+}
+
+// Runs the pre-push hook before pushing the refspecs to the remote.
+// The hook reads one line per pushed ref: <local ref> <local oid> <remote ref> <remote oid>
+func RunPrePushHook(repo *git.Repository, remote *git.Remote, refspecs []string) error {
+	stdin := new(bytes.Buffer)
+
+	for _, refspec := range refspecs {
+		parts := strings.SplitN(strings.TrimPrefix(refspec, "+"), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		src, dst := parts[0], parts[1]
+
+		localOid := zeroOid
+		if src == "" {
+			src = "(delete)"
+		} else if ref, err := repo.References.Lookup(src); err == nil {
+			if resolved, err := ref.Resolve(); err == nil {
+				localOid = resolved.Target().String()
+			}
+		}
+
+		remoteOid := zeroOid
+		if tracking := trackingRef(remote.Name(), dst); tracking != "" {
+			if ref, err := repo.References.Lookup(tracking); err == nil {
+				remoteOid = ref.Target().String()
+			}
+		}
+
+		stdin.WriteString(fmt.Sprintf("%v %v %v %v\n", src, localOid, dst, remoteOid))
+	}
+
+	return RunHook(repo, HookPrePush, stdin.String(), remote.Name(), remote.Url())
+}
+
+// Returns the local ref following the given ref of the remote, empty if there's none
+func trackingRef(remoteName, remoteRef string) string {
+	if strings.HasPrefix(remoteRef, RefsTips) {
+		return RefsRemoteTips + remoteName + "/" + strings.TrimPrefix(remoteRef, RefsTips)
+	}
+
+	if strings.HasPrefix(remoteRef, "refs/heads/") {
+		return "refs/remotes/" + remoteName + "/" + strings.TrimPrefix(remoteRef, "refs/heads/")
+	}
+
+	return ""
+}
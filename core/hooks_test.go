@@ -0,0 +1,101 @@
+package core
+
+import (
+	"fmt"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunHook(t *testing.T) {
+	test.RunOnRepo(t, "Arguments", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		out := filepath.Join(repo.Workdir(), "out")
+		test.WriteHook(repo, HookCommitMsg, `echo "$@" > out`)
+
+		err := RunHook(repo, HookCommitMsg, "", "file")
+		assert.Nil(t, err)
+
+		content, _ := ioutil.ReadFile(out)
+		assert.Equal(t, "file\n", string(content))
+	})
+
+	test.RunOnRepo(t, "Failure", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteHook(repo, HookPreCommit, "exit 1")
+
+		err := RunHook(repo, HookPreCommit, "")
+		assert.True(t, IsHookError(err))
+		assert.Equal(t, "The pre-commit hook failed (exit status 1).", err.Error())
+	})
+
+	test.RunOnRepo(t, "Missing", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		assert.Nil(t, RunHook(repo, HookPreCommit, ""))
+	})
+
+	test.RunOnRepo(t, "NotExecutable", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteHook(repo, HookPreCommit, "exit 1")
+		os.Chmod(filepath.Join(repo.Path(), "hooks", HookPreCommit), 0644)
+
+		assert.Nil(t, RunHook(repo, HookPreCommit, ""))
+	})
+
+	test.RunOnRepo(t, "HooksPath", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteHook(repo, HookPreCommit, "exit 0")
+		os.MkdirAll(filepath.Join(repo.Workdir(), "githooks"), 0755)
+		ioutil.WriteFile(filepath.Join(repo.Workdir(), "githooks", HookPreCommit), []byte("#!/bin/sh\nexit 1\n"), 0755)
+
+		config, _ := repo.Config()
+		config.SetString("core.hooksPath", "githooks")
+
+		assert.Equal(t, filepath.Join(repo.Workdir(), "githooks"), HooksDir(repo))
+		assert.NotNil(t, RunHook(repo, HookPreCommit, ""))
+	})
+}
+
+func TestRunPrePushHook(t *testing.T) {
+	test.RunOnRemote(t, "Stdin", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
+		tip, _ := repo.References.Lookup(RefsTips + "test")
+		test.WriteHook(repo, HookPrePush, `echo "$@" > out; cat >> out`)
+
+		origin, _ := repo.Remotes.Lookup("origin")
+		err := RunPrePushHook(repo, origin, []string{"+refs/tips/test:refs/tips/test", ":refs/tips/gone"})
+		assert.Nil(t, err)
+
+		content, _ := ioutil.ReadFile(filepath.Join(repo.Workdir(), "out"))
+		expected := fmt.Sprintf("origin %v\nrefs/tips/test %v refs/tips/test %v\n(delete) %v refs/tips/gone %v\n",
+			origin.Url(), tip.Target(), zeroOid, zeroOid, zeroOid)
+		assert.Equal(t, expected, string(content))
+	})
+
+	test.RunOnRemote(t, "AbortsPush", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
+		test.WriteHook(repo, HookPrePush, "exit 1")
+
+		err := PushTip(repo, "test", context.Context)
+		assert.True(t, IsHookError(err))
+		_, err = remote.References.Lookup(RefsTips + "test")
+		assert.NotNil(t, err)
+
+		// --no-verify
+		context.NoVerify = true
+		err = PushTip(repo, "test", context.Context)
+		assert.Nil(t, err)
+		_, err = remote.References.Lookup(RefsTips + "test")
+		assert.Nil(t, err)
+	})
+
+	test.RunOnRemote(t, "AbortsDelete", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
+		PushTip(repo, "test", context.Context)
+		test.WriteHook(repo, HookPrePush, "exit 1")
+
+		_, err := DeleteRemoteTip(repo, "test", "refs/remotes/origin/master", false, context.Context)
+		assert.True(t, IsHookError(err))
+		_, err = remote.References.Lookup(RefsTips + "test")
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,335 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A line of a hunk. Origin is ' ' for context, '-' for a removal and '+' for an addition.
+// Content includes the line terminator, if the line has one.
+type HunkLine struct {
+	Origin  byte
+	Content string
+}
+
+// Changes of a file between the index and the working tree
+type Hunk struct {
+	Path string
+	// Positions of the first line of the hunk in the old and the new content, starting at 0
+	OldStart int
+	NewStart int
+	Lines    []HunkLine
+}
+
+// Returns the hunks of the working tree that are not staged, file by file.
+// Untracked and binary files are left out.
+func WorkdirHunks(repo *git.Repository) ([][]Hunk, error) {
+	index, err := repo.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := repo.DiffIndexToWorkdir(index, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer diff.Free()
+
+	files := [][]Hunk{}
+
+	err = diff.ForEach(func(delta git.DiffDelta, progress float64) (git.DiffForEachHunkCallback, error) {
+		if delta.Flags&git.DiffFlagBinary != 0 {
+			return nil, nil
+		}
+
+		files = append(files, []Hunk{})
+		file := len(files) - 1
+
+		return func(diffHunk git.DiffHunk) (git.DiffForEachLineCallback, error) {
+			hunk := Hunk{
+				Path:     delta.NewFile.Path,
+				OldStart: diffHunk.OldStart - 1,
+				NewStart: diffHunk.NewStart - 1,
+			}
+			// Hunks that only add or remove lines are positioned after the line they follow
+			if diffHunk.OldLines == 0 {
+				hunk.OldStart++
+			}
+			if diffHunk.NewLines == 0 {
+				hunk.NewStart++
+			}
+			files[file] = append(files[file], hunk)
+			h := len(files[file]) - 1
+
+			return func(line git.DiffLine) error {
+				origin := byte(line.Origin)
+				if origin == ' ' || origin == '+' || origin == '-' {
+					files[file][h].Lines = append(files[file][h].Lines, HunkLine{origin, line.Content})
+				}
+				return nil
+			}, nil
+		}, nil
+	}, git.DiffDetailLines)
+
+	// Binary files have no hunk
+	nonEmpty := [][]Hunk{}
+	for _, hunks := range files {
+		if len(hunks) > 0 {
+			nonEmpty = append(nonEmpty, hunks)
+		}
+	}
+
+	return nonEmpty, err
+}
+
+func (hunk Hunk) counts() (oldLines, newLines int) {
+	for _, line := range hunk.Lines {
+		if line.Origin != '+' {
+			oldLines++
+		}
+		if line.Origin != '-' {
+			newLines++
+		}
+	}
+	return oldLines, newLines
+}
+
+// Returns the hunk in the unified diff format
+func (hunk Hunk) String() string {
+	oldLines, newLines := hunk.counts()
+	oldStart, newStart := hunk.OldStart+1, hunk.NewStart+1
+	if oldLines == 0 {
+		oldStart--
+	}
+	if newLines == 0 {
+		newStart--
+	}
+
+	text := fmt.Sprintf("@@ -%v,%v +%v,%v @@\n", oldStart, oldLines, newStart, newLines)
+	for _, line := range hunk.Lines {
+		text += string(line.Origin) + line.Content
+		if !strings.HasSuffix(line.Content, "\n") {
+			text += "\n\\ No newline at end of file\n"
+		}
+	}
+	return text
+}
+
+// Splits the hunk where its changes are separated by context lines.
+// Returns the hunk itself if it can't be split.
+func (hunk Hunk) Split() []Hunk {
+	hunks := []Hunk{}
+	oldPos, newPos := hunk.OldStart, hunk.NewStart
+	var current *Hunk
+	// Context lines seen since the last change
+	context := []HunkLine{}
+
+	for _, line := range hunk.Lines {
+		if line.Origin == ' ' {
+			if current != nil {
+				current.Lines = append(current.Lines, line)
+			}
+			context = append(context, line)
+		} else {
+			if current == nil || len(context) > 0 && current.Lines[len(current.Lines)-1].Origin == ' ' {
+				// A new change starts after context lines: the context is shared
+				// between the end of the previous hunk and the start of this one
+				if current != nil {
+					hunks = append(hunks, *current)
+				}
+				current = &Hunk{
+					Path:     hunk.Path,
+					OldStart: oldPos - len(context),
+					NewStart: newPos - len(context),
+					Lines:    append([]HunkLine{}, context...),
+				}
+			}
+			current.Lines = append(current.Lines, line)
+			context = []HunkLine{}
+		}
+
+		if line.Origin != '+' {
+			oldPos++
+		}
+		if line.Origin != '-' {
+			newPos++
+		}
+	}
+
+	if current != nil {
+		hunks = append(hunks, *current)
+	}
+
+	if len(hunks) < 2 {
+		return []Hunk{hunk}
+	}
+	return hunks
+}
+
+// Returns the hunk as edited by the user. The removals and the context lines must be
+// the ones of the original hunk, without the removals turned into context lines.
+func ParseEditedHunk(hunk Hunk, text string) (Hunk, error) {
+	edited := Hunk{Path: hunk.Path, OldStart: hunk.OldStart, NewStart: hunk.NewStart}
+
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if len(line) == 0 || line[0] == '#' || line[0] == '@' || line[0] == '\\' {
+			continue
+		}
+
+		origin := line[0]
+		content := line[1:]
+		// Keep the missing newline of the original last line
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\\") {
+			content = strings.TrimSuffix(content, "\n")
+		}
+
+		switch origin {
+		case ' ', '-', '+':
+			edited.Lines = append(edited.Lines, HunkLine{origin, content})
+		case '\n':
+			// Empty context lines may lose their leading space in the editor
+			edited.Lines = append(edited.Lines, HunkLine{' ', "\n"})
+		default:
+			return hunk, fmt.Errorf("Invalid line in the edited hunk: %v", strings.TrimSuffix(line, "\n"))
+		}
+	}
+
+	if len(edited.Lines) == 0 {
+		return hunk, errors.New("The edited hunk is empty.")
+	}
+
+	if _, err := ApplyHunks(oldSide(hunk), []Hunk{shift(edited, -hunk.OldStart)}); err != nil {
+		return hunk, errors.New("The edited hunk doesn't apply.")
+	}
+
+	return edited, nil
+}
+
+// Content of the lines the hunk applies on
+func oldSide(hunk Hunk) string {
+	content := ""
+	for _, line := range hunk.Lines {
+		if line.Origin != '+' {
+			content += line.Content
+		}
+	}
+	return content
+}
+
+func shift(hunk Hunk, offset int) Hunk {
+	hunk.OldStart += offset
+	return hunk
+}
+
+// Applies the hunks on the content. The hunks must be sorted by position.
+func ApplyHunks(content string, hunks []Hunk) (string, error) {
+	old := strings.SplitAfter(content, "\n")
+	if len(old[len(old)-1]) == 0 {
+		old = old[:len(old)-1]
+	}
+
+	result := []string{}
+	pos := 0
+
+	for _, hunk := range hunks {
+		if hunk.OldStart > len(old) {
+			return "", fmt.Errorf("Hunk of %v doesn't apply.", hunk.Path)
+		}
+
+		// Hunks split from the same hunk share their context lines
+		lines := hunk.Lines
+		for overlap := pos - hunk.OldStart; overlap > 0; overlap-- {
+			if len(lines) == 0 || lines[0].Origin != ' ' {
+				return "", fmt.Errorf("Hunk of %v doesn't apply.", hunk.Path)
+			}
+			lines = lines[1:]
+		}
+
+		if hunk.OldStart > pos {
+			result = append(result, old[pos:hunk.OldStart]...)
+			pos = hunk.OldStart
+		}
+
+		for _, line := range lines {
+			switch line.Origin {
+			case ' ', '-':
+				if pos >= len(old) || old[pos] != line.Content {
+					return "", fmt.Errorf("Hunk of %v doesn't apply.", hunk.Path)
+				}
+				if line.Origin == ' ' {
+					result = append(result, old[pos])
+				}
+				pos++
+			case '+':
+				result = append(result, line.Content)
+			}
+		}
+	}
+
+	result = append(result, old[pos:]...)
+
+	return strings.Join(result, ""), nil
+}
+
+// Stages the hunks of a file, on top of its content in the index
+func StageHunks(repo *git.Repository, path string, hunks []Hunk) error {
+	index, err := repo.Index()
+	if err != nil {
+		return err
+	}
+
+	entry, err := index.EntryByPath(path, 0)
+	if err != nil {
+		return err
+	}
+
+	blob, err := repo.LookupBlob(entry.Id)
+	if err != nil {
+		return err
+	}
+
+	content, err := ApplyHunks(string(blob.Contents()), hunks)
+	if err != nil {
+		return err
+	}
+
+	// The whole content of a deleted file has been removed
+	if _, err := os.Lstat(filepath.Join(repo.Workdir(), path)); os.IsNotExist(err) && len(content) == 0 {
+		if err := index.RemoveByPath(path); err != nil {
+			return err
+		}
+		return index.Write()
+	}
+
+	oid, err := repo.CreateBlobFromBuffer([]byte(content))
+	if err != nil {
+		return err
+	}
+
+	entry.Id = oid
+	entry.Size = uint32(len(content))
+	if err := index.Add(entry); err != nil {
+		return err
+	}
+
+	return index.Write()
+}
+
+// Stages the modifications and the deletions of the tracked files, like git add -u
+func StageTracked(repo *git.Repository) error {
+	index, err := repo.Index()
+	if err != nil {
+		return err
+	}
+
+	if err := index.UpdateAll([]string{}, nil); err != nil {
+		return err
+	}
+
+	return index.Write()
+}
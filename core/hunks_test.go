@@ -0,0 +1,95 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+	"testing"
+)
+
+func TestHunks(t *testing.T) {
+	content := "a\nb\nc\nd\ne\n"
+	hunk := Hunk{
+		Path:     "file",
+		OldStart: 0,
+		NewStart: 0,
+		Lines: []HunkLine{
+			{' ', "a\n"},
+			{'-', "b\n"},
+			{' ', "c\n"},
+			{'+', "x\n"},
+			{' ', "d\n"},
+		},
+	}
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, "@@ -1,4 +1,4 @@\n a\n-b\n c\n+x\n d\n", hunk.String())
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		result, err := ApplyHunks(content, []Hunk{hunk})
+		assert.Nil(t, err)
+		assert.Equal(t, "a\nc\nx\nd\ne\n", result)
+
+		_, err = ApplyHunks("a\nB\nc\nd\ne\n", []Hunk{hunk})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Split", func(t *testing.T) {
+		split := hunk.Split()
+		assert.Equal(t, 2, len(split))
+		assert.Equal(t, "@@ -1,3 +1,2 @@\n a\n-b\n c\n", split[0].String())
+		assert.Equal(t, "@@ -3,2 +2,3 @@\n c\n+x\n d\n", split[1].String())
+
+		// The hunks can be applied separately or together
+		result, _ := ApplyHunks(content, split[1:])
+		assert.Equal(t, "a\nb\nc\nx\nd\ne\n", result)
+		result, _ = ApplyHunks(content, split)
+		assert.Equal(t, "a\nc\nx\nd\ne\n", result)
+
+		// Hunks with a single change can't be split
+		assert.Equal(t, 1, len(split[0].Split()))
+	})
+
+	t.Run("Edit", func(t *testing.T) {
+		// Keep b and add y instead of x
+		edited, err := ParseEditedHunk(hunk, "# comment\n@@ -1,4 +1,4 @@\n a\n b\n c\n+y\n d\n")
+		assert.Nil(t, err)
+		result, _ := ApplyHunks(content, []Hunk{edited})
+		assert.Equal(t, "a\nb\nc\ny\nd\ne\n", result)
+
+		_, err = ParseEditedHunk(hunk, " a\n-z\n c\n d\n")
+		assert.Equal(t, "The edited hunk doesn't apply.", err.Error())
+
+		_, err = ParseEditedHunk(hunk, "# nothing left\n")
+		assert.Equal(t, "The edited hunk is empty.", err.Error())
+	})
+}
+
+func TestStageHunks(t *testing.T) {
+	test.RunOnRepo(t, "FirstHunk", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		lines := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+		test.WriteFile(repo, true, "file", lines...)
+		test.Commit(repo, nil)
+
+		changed := append([]string{}, lines...)
+		changed[0] = "one"
+		changed[9] = "ten"
+		test.WriteFile(repo, false, "file", changed...)
+
+		files, err := WorkdirHunks(repo)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(files))
+		assert.Equal(t, 2, len(files[0]))
+
+		err = StageHunks(repo, "file", files[0][:1])
+		assert.Nil(t, err)
+
+		index, _ := repo.Index()
+		entry, _ := index.EntryByPath("file", 0)
+		blob, _ := repo.LookupBlob(entry.Id)
+		staged := append([]string{"one"}, lines[1:]...)
+		assert.Equal(t, strings.Join(staged, "\n"), string(blob.Contents()))
+	})
+}
@@ -0,0 +1,284 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const journalFile = "journal"
+
+// Number of operations kept in the journal
+const journalSize = 100
+
+// Prefix of the config entries of a State
+const ConfigEntryPrefix = "config:"
+
+// Values of HEAD, of the refs and of the config entries managed by tie.
+// A symbolic HEAD is stored as "ref: <target>".
+type State map[string]string
+
+// Value of an entry before and after an operation. Empty means missing.
+type Change struct {
+	Name   string
+	Before string
+	After  string
+}
+
+// A command recorded in the journal
+type Operation struct {
+	Id      int
+	Time    time.Time
+	Command string
+	Changes []Change
+	Undone  bool
+}
+
+func journalPath(repo *git.Repository) string {
+	return filepath.Join(repo.Path(), TieDir, journalFile)
+}
+
+func TakeSnapshot(repo *git.Repository) State {
+	state := State{}
+
+	head, err := repo.References.Lookup("HEAD")
+	if err == nil {
+		if head.Type() == git.ReferenceSymbolic {
+			state["HEAD"] = "ref: " + head.SymbolicTarget()
+		} else {
+			state["HEAD"] = head.Target().String()
+		}
+	}
+
+	for _, glob := range []string{"refs/heads/*", RefsTips + "*", RefsTails + "*", RefsTipMeta + "*", RefsWip + "*", RefsRemoteTips + "*"} {
+		it, err := repo.NewReferenceIteratorGlob(glob)
+		if err != nil {
+			continue
+		}
+		for ref, end := it.Next(); end == nil; ref, end = it.Next() {
+			if ref.Type() == git.ReferenceOid {
+				state[ref.Name()] = ref.Target().String()
+			}
+		}
+	}
+
+	for tipName, base := range TipBases(repo) {
+		state[fmt.Sprintf("%vtip.%v.base", ConfigEntryPrefix, tipName)] = base
+	}
+
+	return state
+}
+
+func diffStates(before, after State) []Change {
+	names := []string{}
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, found := before[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []Change{}
+	for _, name := range names {
+		if before[name] != after[name] {
+			changes = append(changes, Change{Name: name, Before: before[name], After: after[name]})
+		}
+	}
+	return changes
+}
+
+// Returns the recorded operations, from the oldest to the most recent
+func LoadJournal(repo *git.Repository) ([]Operation, error) {
+	operations := []Operation{}
+
+	bytes, err := ioutil.ReadFile(journalPath(repo))
+	if os.IsNotExist(err) {
+		return operations, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(bytes, &operations)
+	return operations, err
+}
+
+func saveJournal(repo *git.Repository, operations []Operation) error {
+	if len(operations) > journalSize {
+		operations = operations[len(operations)-journalSize:]
+	}
+	bytes, _ := json.MarshalIndent(operations, "", "  ")
+	os.MkdirAll(filepath.Join(repo.Path(), TieDir), 0755)
+	return ioutil.WriteFile(journalPath(repo), bytes, 0644)
+}
+
+// Records the changes made by command since the before snapshot.
+// Commands that didn't change anything are not recorded.
+func RecordOperation(repo *git.Repository, command string, before State) error {
+	changes := diffStates(before, TakeSnapshot(repo))
+	if len(changes) == 0 {
+		return nil
+	}
+
+	operations, err := LoadJournal(repo)
+	if err != nil {
+		return err
+	}
+
+	id := 1
+	if len(operations) > 0 {
+		id = operations[len(operations)-1].Id + 1
+	}
+
+	// Undone operations can't be redone after a new one
+	kept := []Operation{}
+	for _, operation := range operations {
+		if !operation.Undone {
+			kept = append(kept, operation)
+		}
+	}
+
+	kept = append(kept, Operation{
+		Id:      id,
+		Time:    time.Now(),
+		Command: command,
+		Changes: changes,
+	})
+
+	return saveJournal(repo, kept)
+}
+
+// Puts back the values that were there before the last operation
+func UndoOperation(repo *git.Repository) (*Operation, error) {
+	operations, err := LoadJournal(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(operations) - 1; i >= 0; i-- {
+		if !operations[i].Undone {
+			err = applyChanges(repo, operations[i].Changes, true)
+			if err != nil {
+				return nil, err
+			}
+			operations[i].Undone = true
+			return &operations[i], saveJournal(repo, operations)
+		}
+	}
+
+	return nil, errors.New("Nothing to undo.")
+}
+
+// Applies again the last undone operation
+func RedoOperation(repo *git.Repository) (*Operation, error) {
+	operations, err := LoadJournal(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range operations {
+		if operations[i].Undone {
+			err = applyChanges(repo, operations[i].Changes, false)
+			if err != nil {
+				return nil, err
+			}
+			operations[i].Undone = false
+			return &operations[i], saveJournal(repo, operations)
+		}
+	}
+
+	return nil, errors.New("Nothing to redo.")
+}
+
+// Sets the entries to their Before value if undo, to their After value otherwise.
+// The working tree follows HEAD.
+func applyChanges(repo *git.Repository, changes []Change, undo bool) error {
+	verb := "redone"
+	if undo {
+		verb = "undone"
+	}
+
+	current := TakeSnapshot(repo)
+	for _, change := range changes {
+		expected := change.Before
+		if undo {
+			expected = change.After
+		}
+		if current[change.Name] != expected {
+			return fmt.Errorf("'%v' has changed since the operation. It can't be %v.", change.Name, verb)
+		}
+	}
+
+	var baselineTree *git.Tree
+	if head, err := repo.Head(); err == nil {
+		commit, _ := repo.LookupCommit(head.Target())
+		baselineTree, _ = commit.Tree()
+	}
+
+	config, _ := repo.Config()
+	message := "tie undo"
+	if !undo {
+		message = "tie redo"
+	}
+
+	for _, change := range changes {
+		value := change.After
+		if undo {
+			value = change.Before
+		}
+
+		var err error
+
+		switch {
+		case change.Name == "HEAD" && strings.HasPrefix(value, "ref: "):
+			_, err = repo.References.CreateSymbolic("HEAD", strings.TrimPrefix(value, "ref: "), true, message)
+		case change.Name == "HEAD":
+			oid, _ := git.NewOid(value)
+			err = repo.SetHeadDetached(oid)
+		case strings.HasPrefix(change.Name, ConfigEntryPrefix):
+			key := strings.TrimPrefix(change.Name, ConfigEntryPrefix)
+			if len(value) == 0 {
+				err = config.Delete(key)
+			} else {
+				err = config.SetString(key, value)
+			}
+		case len(value) == 0:
+			ref, lookupErr := repo.References.Lookup(change.Name)
+			if lookupErr == nil {
+				err = ref.Delete()
+			}
+		default:
+			oid, _ := git.NewOid(value)
+			_, err = repo.References.Create(change.Name, oid, true, message)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil
+	}
+	commit, _ := repo.LookupCommit(head.Target())
+	tree, _ := commit.Tree()
+
+	if baselineTree == nil || !baselineTree.Id().Equal(tree.Id()) {
+		return repo.CheckoutTree(tree, &git.CheckoutOpts{
+			Strategy: git.CheckoutSafe,
+			Baseline: baselineTree,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	test.RunOnRepo(t, "UndoRedo", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+
+		before := TakeSnapshot(repo)
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		err := RecordOperation(repo, "create test", before)
+		assert.Nil(t, err)
+
+		operations, _ := LoadJournal(repo)
+		if assert.Equal(t, 1, len(operations)) {
+			assert.Equal(t, "create test", operations[0].Command)
+			assert.Equal(t, []Change{
+				{Name: "HEAD", Before: "ref: refs/heads/master", After: "ref: " + RefsTips + "test"},
+				{Name: ConfigEntryPrefix + "tip.test.base", Before: "", After: "refs/heads/master"},
+				{Name: RefsTails + "test", Before: "", After: head.Target().String()},
+				{Name: RefsTips + "test", Before: "", After: head.Target().String()},
+			}, operations[0].Changes)
+		}
+
+		operation, err := UndoOperation(repo)
+		assert.Nil(t, err)
+		assert.Equal(t, "create test", operation.Command)
+
+		// The tip doesn't exist anymore
+		_, err = repo.References.Lookup(RefsTips + "test")
+		assert.NotNil(t, err)
+		head, _ = repo.Head()
+		assert.Equal(t, "refs/heads/master", head.Name())
+		config, _ := repo.Config()
+		_, err = config.LookupString("tip.test.base")
+		assert.NotNil(t, err)
+
+		_, err = UndoOperation(repo)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Nothing to undo.", err.Error())
+		}
+
+		_, err = RedoOperation(repo)
+		assert.Nil(t, err)
+
+		head, _ = repo.Head()
+		assert.Equal(t, RefsTips+"test", head.Name())
+
+		_, err = RedoOperation(repo)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Nothing to redo.", err.Error())
+		}
+	})
+
+	test.RunOnRepo(t, "WorkingTree", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+
+		before := TakeSnapshot(repo)
+		test.WriteFile(repo, true, "foo", "bar")
+		test.Commit(repo, nil)
+		RecordOperation(repo, "commit", before)
+
+		UndoOperation(repo)
+
+		// The commit is undone in the working tree too
+		_, err := repo.RevparseSingle("HEAD:foo")
+		assert.NotNil(t, err)
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "ChangedSince", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		before := TakeSnapshot(repo)
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		RecordOperation(repo, "create test", before)
+
+		// The tip moved after the operation
+		test.CommitFiles(repo, RefsTips+"test", map[string]string{"foo": "bar"})
+
+		_, err := UndoOperation(repo)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "'"+RefsTips+"test' has changed since the operation. It can't be undone.", err.Error())
+		}
+	})
+
+	test.RunOnRepo(t, "NewOperationDropsRedo", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		before := TakeSnapshot(repo)
+		test.CreateTip(repo, "a", "refs/heads/master", false)
+		RecordOperation(repo, "create a", before)
+		UndoOperation(repo)
+
+		before = TakeSnapshot(repo)
+		test.CreateTip(repo, "b", "refs/heads/master", false)
+		RecordOperation(repo, "create b", before)
+
+		operations, _ := LoadJournal(repo)
+		if assert.Equal(t, 1, len(operations)) {
+			assert.Equal(t, "create b", operations[0].Command)
+			assert.Equal(t, 2, operations[0].Id)
+		}
+	})
+
+	test.RunOnRepo(t, "NothingChanged", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		RecordOperation(repo, "list", TakeSnapshot(repo))
+
+		operations, _ := LoadJournal(repo)
+		assert.Equal(t, 0, len(operations))
+	})
+
+	test.RunOnRepo(t, "RemoteTips", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+
+		before := TakeSnapshot(repo)
+		repo.References.Create(RefsRemoteTips+"origin/test", head.Target(), false, "")
+		RecordOperation(repo, "push test", before)
+
+		_, err := UndoOperation(repo)
+		assert.Nil(t, err)
+
+		// The remote tip is put back with the tips
+		_, err = repo.References.Lookup(RefsRemoteTips + "origin/test")
+		assert.NotNil(t, err)
+	})
+}
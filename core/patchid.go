@@ -0,0 +1,136 @@
+package core
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+	"unicode"
+)
+
+// Returns an id of the changes introduced by the commit, which doesn't depend on
+// its parents nor on the line numbers. Like git patch-id, whitespaces are ignored.
+func PatchId(repo *git.Repository, commit *git.Commit) (string, error) {
+	var parentTree *git.Tree
+	if commit.ParentCount() > 0 {
+		parentTree, _ = commit.Parent(0).Tree()
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	return diffPatchId(repo, parentTree, tree)
+}
+
+func diffPatchId(repo *git.Repository, from, to *git.Tree) (string, error) {
+	diff, err := repo.DiffTreeToTree(from, to, nil)
+	if err != nil {
+		return "", err
+	}
+	defer diff.Free()
+
+	deltas, _ := diff.NumDeltas()
+	hash := sha1.New()
+
+	for i := 0; i < deltas; i++ {
+		patch, err := diff.Patch(i)
+		if err != nil {
+			return "", err
+		}
+		text, _ := patch.String()
+		patch.Free()
+
+		scanner := bufio.NewScanner(strings.NewReader(text))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "index ") || strings.HasPrefix(line, "@@") {
+				continue
+			}
+			hash.Write([]byte(strings.Map(func(r rune) rune {
+				if unicode.IsSpace(r) {
+					return -1
+				}
+				return r
+			}, line)))
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Returns the commits of the tip that have an equivalent commit in its base since the tail,
+// and whether the whole tip has landed. A tip squashed in a single commit counts as landed.
+func LandedCommits(repo *git.Repository, tipName string) (landed map[string]bool, fully bool, err error) {
+	landed = map[string]bool{}
+
+	config, _ := repo.Config()
+	baseRefName, err := config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
+	if err != nil {
+		return landed, false, err
+	}
+
+	base, err := repo.References.Lookup(baseRefName)
+	if err != nil {
+		return landed, false, err
+	}
+
+	tail, err := repo.References.Lookup(RefsTails + tipName)
+	if err != nil {
+		return landed, false, err
+	}
+
+	tipCommits, err := TipCommits(repo, tipName)
+	if err != nil || len(tipCommits) == 0 {
+		return landed, false, err
+	}
+
+	baseCommits, err := CommitsBetween(repo, tail.Target(), base.Target())
+	if err != nil {
+		return landed, false, err
+	}
+
+	basePatchIds := map[string]bool{}
+	for _, commit := range baseCommits {
+		patchId, err := PatchId(repo, commit)
+		if err != nil {
+			return landed, false, err
+		}
+		basePatchIds[patchId] = true
+	}
+
+	for _, commit := range tipCommits {
+		patchId, err := PatchId(repo, commit)
+		if err != nil {
+			return landed, false, err
+		}
+		if basePatchIds[patchId] {
+			landed[commit.Id().String()] = true
+		}
+	}
+
+	if len(landed) == len(tipCommits) {
+		return landed, true, nil
+	}
+
+	// The tip may have been squashed
+	tailCommit, _ := repo.LookupCommit(tail.Target())
+	tailTree, _ := tailCommit.Tree()
+	tipTree, _ := tipCommits[len(tipCommits)-1].Tree()
+	squashPatchId, err := diffPatchId(repo, tailTree, tipTree)
+	if err != nil {
+		return landed, false, err
+	}
+
+	if basePatchIds[squashPatchId] {
+		for _, commit := range tipCommits {
+			landed[commit.Id().String()] = true
+		}
+		return landed, true, nil
+	}
+
+	return landed, false, nil
+}
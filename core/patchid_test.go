@@ -0,0 +1,73 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestPatchId(t *testing.T) {
+	test.RunOnRepo(t, "IndependentOfParent", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		repo.References.Create("refs/heads/other", head.Target(), false, "")
+
+		test.CommitFiles(repo, "refs/heads/master", map[string]string{"bar": "bar"})
+		onMaster := test.CommitFiles(repo, "refs/heads/master", map[string]string{"foo": "foo"})
+		onOther := test.CommitFiles(repo, "refs/heads/other", map[string]string{"foo": "foo"})
+		different := test.CommitFiles(repo, "refs/heads/other", map[string]string{"foo": "foo bis"})
+
+		patchId := func(oid *git.Oid) string {
+			commit, _ := repo.LookupCommit(oid)
+			id, err := PatchId(repo, commit)
+			assert.Nil(t, err)
+			return id
+		}
+
+		assert.Equal(t, patchId(onMaster), patchId(onOther))
+		assert.NotEqual(t, patchId(onMaster), patchId(different))
+	})
+}
+
+func TestLandedCommits(t *testing.T) {
+	// Creates a tip on master adding foo then bar, and moves master forward
+	setup := func(repo *git.Repository) (foo, bar *git.Oid) {
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		foo = test.CommitFiles(repo, RefsTips+"test", map[string]string{"foo": "foo"})
+		bar = test.CommitFiles(repo, RefsTips+"test", map[string]string{"bar": "bar"})
+		test.CommitFiles(repo, "refs/heads/master", map[string]string{"other": "other"})
+		return foo, bar
+	}
+
+	test.RunOnRepo(t, "NotLanded", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		setup(repo)
+
+		landed, fully, err := LandedCommits(repo, "test")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(landed))
+		assert.False(t, fully)
+	})
+
+	test.RunOnRepo(t, "PartiallyLanded", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		foo, bar := setup(repo)
+		// foo has been cherry-picked
+		test.CommitFiles(repo, "refs/heads/master", map[string]string{"foo": "foo"})
+
+		landed, fully, err := LandedCommits(repo, "test")
+		assert.Nil(t, err)
+		assert.True(t, landed[foo.String()])
+		assert.False(t, landed[bar.String()])
+		assert.False(t, fully)
+	})
+
+	test.RunOnRepo(t, "Squashed", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		foo, bar := setup(repo)
+		test.CommitFiles(repo, "refs/heads/master", map[string]string{"foo": "foo", "bar": "bar"})
+
+		landed, fully, err := LandedCommits(repo, "test")
+		assert.Nil(t, err)
+		assert.True(t, landed[foo.String()])
+		assert.True(t, landed[bar.String()])
+		assert.True(t, fully)
+	})
+}
@@ -0,0 +1,381 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"regexp"
+	"strings"
+)
+
+// A commit of the old version of a tip matched with a commit of the new version.
+// Old or New is nil when the commit has no counterpart.
+type RangeDiffPair struct {
+	Old *git.Commit
+	New *git.Commit
+	// Position of the commits in their version, starting at 1
+	OldIndex int
+	NewIndex int
+	// Differences between the message and the patch of the two commits
+	Interdiff string
+}
+
+// Returns '=' for identical commits, '!' for modified ones, '<' for removed ones and '>' for added ones
+func (pair RangeDiffPair) Status() string {
+	switch {
+	case pair.New == nil:
+		return "<"
+	case pair.Old == nil:
+		return ">"
+	case len(pair.Interdiff) == 0:
+		return "="
+	}
+	return "!"
+}
+
+// Returns the commits of the remote tip which are not part of the tip's base,
+// as last pushed, and the name of the remote tip.
+func RemoteTipCommits(repo *git.Repository, tipName string) ([]*git.Commit, string, error) {
+	config, _ := repo.Config()
+	baseRefName, err := config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
+	if err != nil {
+		return nil, "", err
+	}
+
+	remoteName, err := RemoteOf(baseRefName, config)
+	if err != nil {
+		return nil, "", err
+	}
+
+	remoteTipName := RefsRemoteTips + remoteName + "/" + tipName
+	remoteTip, err := repo.References.Lookup(remoteTipName)
+	if err != nil {
+		return nil, remoteTipName, fmt.Errorf("Tip '%v' hasn't been pushed to %v.", tipName, remoteName)
+	}
+
+	walk, err := repo.Walk()
+	if err != nil {
+		return nil, remoteTipName, err
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTopological | git.SortReverse)
+	walk.Push(remoteTip.Target())
+
+	// The remote tip may have been pushed before the tip got updated,
+	// so the old tail might be an ancestor of the current base.
+	if tail, err := repo.References.Lookup(RefsTails + tipName); err == nil {
+		walk.Hide(tail.Target())
+	}
+	if base, err := repo.References.Lookup(baseRefName); err == nil {
+		walk.Hide(base.Target())
+	}
+
+	commits := []*git.Commit{}
+	err = walk.Iterate(func(commit *git.Commit) bool {
+		commits = append(commits, commit)
+		return true
+	})
+
+	return commits, remoteTipName, err
+}
+
+// Matches the commits of two versions of a tip, like git range-diff. Commits are
+// paired by patch-id first, then by summary. The pairs follow the order of the new version,
+// the removed commits coming after the commit that preceded them.
+func RangeDiff(repo *git.Repository, old, updated []*git.Commit) ([]RangeDiffPair, error) {
+	oldIds := make([]string, len(old))
+	for i, commit := range old {
+		id, err := PatchId(repo, commit)
+		if err != nil {
+			return nil, err
+		}
+		oldIds[i] = id
+	}
+
+	// matches[j] is the index in old of the counterpart of updated[j], -1 if none
+	matches := make([]int, len(updated))
+	matched := make([]bool, len(old))
+
+	for j, commit := range updated {
+		matches[j] = -1
+		id, err := PatchId(repo, commit)
+		if err != nil {
+			return nil, err
+		}
+		for i := range old {
+			if !matched[i] && oldIds[i] == id {
+				matches[j] = i
+				matched[i] = true
+				break
+			}
+		}
+	}
+
+	for j, commit := range updated {
+		if matches[j] >= 0 {
+			continue
+		}
+		for i := range old {
+			if !matched[i] && old[i].Summary() == commit.Summary() {
+				matches[j] = i
+				matched[i] = true
+				break
+			}
+		}
+	}
+
+	pairs := []RangeDiffPair{}
+	shown := make([]bool, len(old))
+	i := 0
+
+	for j := 0; j < len(updated); j++ {
+		// Removed commits are shown before the new commits that come after them
+		for ; i < len(old) && (shown[i] || !matched[i]); i++ {
+			if !shown[i] {
+				pairs = append(pairs, RangeDiffPair{Old: old[i], OldIndex: i + 1})
+				shown[i] = true
+			}
+		}
+
+		if matches[j] < 0 {
+			pairs = append(pairs, RangeDiffPair{New: updated[j], NewIndex: j + 1})
+			continue
+		}
+
+		o := matches[j]
+		interdiff, err := interdiff(repo, old[o], updated[j])
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, RangeDiffPair{
+			Old:       old[o],
+			New:       updated[j],
+			OldIndex:  o + 1,
+			NewIndex:  j + 1,
+			Interdiff: interdiff,
+		})
+		shown[o] = true
+	}
+
+	for ; i < len(old); i++ {
+		if !shown[i] {
+			pairs = append(pairs, RangeDiffPair{Old: old[i], OldIndex: i + 1})
+		}
+	}
+
+	return pairs, nil
+}
+
+var hunkHeaderRegexp = regexp.MustCompile(`^@@ [^@]* @@`)
+
+// Returns the message and the patch of the commit, without the parts that depend on
+// where the commit is applied: blob ids and line numbers.
+func commitText(repo *git.Repository, commit *git.Commit) ([]string, error) {
+	diff, err := CommitDiff(repo, commit)
+	if err != nil {
+		return nil, err
+	}
+	defer diff.Free()
+
+	patch, err := DiffPatch(diff)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimRight(commit.Message(), "\n"), "\n")
+	lines = append(lines, "")
+
+	for _, line := range strings.Split(strings.TrimSuffix(patch, "\n"), "\n") {
+		if strings.HasPrefix(line, "index ") {
+			continue
+		}
+		lines = append(lines, hunkHeaderRegexp.ReplaceAllString(line, "@@"))
+	}
+
+	return lines, nil
+}
+
+func interdiff(repo *git.Repository, old, updated *git.Commit) (string, error) {
+	if old.Id().Equal(updated.Id()) {
+		return "", nil
+	}
+
+	oldText, err := commitText(repo, old)
+	if err != nil {
+		return "", err
+	}
+
+	newText, err := commitText(repo, updated)
+	if err != nil {
+		return "", err
+	}
+
+	return LineDiff(oldText, newText, 3), nil
+}
+
+// Returns the lines removed from a and added to b, prefixed with '-' and '+', surrounded
+// by context lines prefixed with ' '. Distant changes are separated by "@@" lines.
+// Returns an empty string when a and b are identical.
+func LineDiff(a, b []string, context int) string {
+	commonA := make([]bool, len(a))
+	commonB := make([]bool, len(b))
+	markCommon(a, b, commonA, commonB)
+
+	lines := []string{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && !commonA[i]:
+			lines = append(lines, "-"+a[i])
+			i++
+		case j < len(b) && !commonB[j]:
+			lines = append(lines, "+"+b[j])
+			j++
+		default:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		}
+	}
+
+	// Keeps the changed lines and their context
+	keep := make([]bool, len(lines))
+	changed := false
+	for k, line := range lines {
+		if line[0] == ' ' {
+			continue
+		}
+		changed = true
+		for c := k - context; c <= k+context; c++ {
+			if c >= 0 && c < len(lines) {
+				keep[c] = true
+			}
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+
+	text := new(bytes.Buffer)
+	for k, line := range lines {
+		if !keep[k] {
+			continue
+		}
+		if k > 0 && !keep[k-1] && text.Len() > 0 {
+			text.WriteString("@@\n")
+		}
+		text.WriteString(line + "\n")
+	}
+
+	return text.String()
+}
+
+// Marks the lines of a and b that are part of their longest common subsequence. Uses the
+// linear space variant of Myers' diff: the sequences are split around the middle of an
+// optimal edit path, found by searching from both ends at once.
+func markCommon(a, b []string, commonA, commonB []bool) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		commonA[0], commonB[0] = true, true
+		a, b, commonA, commonB = a[1:], b[1:], commonA[1:], commonB[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		commonA[len(a)-1], commonB[len(b)-1] = true, true
+		a, b, commonA, commonB = a[:len(a)-1], b[:len(b)-1], commonA[:len(a)-1], commonB[:len(b)-1]
+	}
+
+	if len(a) == 0 || len(b) == 0 {
+		return
+	}
+
+	x, y, found := middleSnake(a, b)
+	if !found {
+		return
+	}
+
+	markCommon(a[:x], b[:y], commonA[:x], commonB[:y])
+	markCommon(a[x:], b[y:], commonA[x:], commonB[y:])
+}
+
+// Returns a point of an optimal edit path from a to b, found where the paths going
+// forward from the start and backward from the end meet. a and b must differ at both ends.
+// Returns false when a and b have nothing in common.
+func middleSnake(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	length := 2*maxD + 2
+
+	// forward[offset+k] is the furthest x reached on the diagonal k = x - y from the start,
+	// backward[offset+k] the furthest distance from the end on the diagonal k of the reversed sequences
+	forward := make([]int, length)
+	backward := make([]int, length)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	// The paths meet going forward when the delta is odd, going backward otherwise
+	front := delta%2 != 0
+
+	// Diagonals that went past an end of the sequences are trimmed
+	kStart, kEnd, rStart, rEnd := 0, 0, 0, 0
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + kStart; k <= d-kEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			if x > n {
+				kEnd += 2
+			} else if y > m {
+				kStart += 2
+			} else if front {
+				r := offset + delta - k
+				if r >= 0 && r < length && backward[r] != -1 && x >= n-backward[r] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			if x > n {
+				rEnd += 2
+			} else if y > m {
+				rStart += 2
+			} else if !front {
+				f := offset + delta - k
+				if f >= 0 && f < length && forward[f] != -1 && forward[f] >= n-x {
+					return forward[f], forward[f] - (f - offset), true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestRangeDiff(t *testing.T) {
+	test.RunOnRepo(t, "Pairs", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		repo.References.Create("refs/heads/old", head.Target(), false, "")
+		repo.References.Create("refs/heads/new", head.Target(), false, "")
+
+		commit := func(refname, message string, files map[string]string) *git.Commit {
+			oid := test.CommitFiles(repo, refname, files)
+			commit, _ := repo.LookupCommit(oid)
+			tree, _ := commit.Tree()
+			oid, _ = commit.Amend(refname, commit.Author(), commit.Committer(), message, tree)
+			commit, _ = repo.LookupCommit(oid)
+			return commit
+		}
+
+		old := []*git.Commit{
+			commit("refs/heads/old", "a", map[string]string{"foo": "foo"}),
+			commit("refs/heads/old", "b", map[string]string{"bar": "bar"}),
+			commit("refs/heads/old", "c", map[string]string{"baz": "baz"}),
+		}
+
+		// The new version is based on another commit
+		commit("refs/heads/new", "base", map[string]string{"other": "other"})
+		new := []*git.Commit{
+			commit("refs/heads/new", "a", map[string]string{"foo": "foo"}),
+			commit("refs/heads/new", "b", map[string]string{"bar": "bar2"}),
+			commit("refs/heads/new", "d", map[string]string{"qux": "qux"}),
+		}
+
+		pairs, err := RangeDiff(repo, old, new)
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(pairs))
+
+		assert.Equal(t, "=", pairs[0].Status())
+		assert.Equal(t, old[0], pairs[0].Old)
+		assert.Equal(t, new[0], pairs[0].New)
+
+		assert.Equal(t, "!", pairs[1].Status())
+		assert.Equal(t, 2, pairs[1].OldIndex)
+		assert.Equal(t, 2, pairs[1].NewIndex)
+		assert.Contains(t, pairs[1].Interdiff, "-+bar\n")
+		assert.Contains(t, pairs[1].Interdiff, "++bar2\n")
+
+		assert.Equal(t, "<", pairs[2].Status())
+		assert.Equal(t, old[2], pairs[2].Old)
+		assert.Equal(t, 3, pairs[2].OldIndex)
+
+		assert.Equal(t, ">", pairs[3].Status())
+		assert.Equal(t, new[2], pairs[3].New)
+		assert.Equal(t, 3, pairs[3].NewIndex)
+	})
+}
+
+func TestLineDiff(t *testing.T) {
+	a := []string{"x1", "x2", "x3", "x4", "x5", "x6", "x7", "x8"}
+	b := []string{"x1", "y2", "x3", "x4", "x5", "x6", "x7", "y8"}
+
+	assert.Equal(t, " x1\n-x2\n+y2\n x3\n@@\n x7\n-x8\n+y8\n", LineDiff(a, b, 1))
+	assert.Equal(t, "", LineDiff(a, a, 3))
+
+	// Lines moved around keep the longest common part
+	assert.Equal(t, "-x1\n x2\n x3\n+x1\n", LineDiff([]string{"x1", "x2", "x3"}, []string{"x2", "x3", "x1"}, 3))
+	assert.Equal(t, "+y0\n x1\n-x2\n", LineDiff([]string{"x1", "x2"}, []string{"y0", "x1"}, 3))
+}
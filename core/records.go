@@ -0,0 +1,147 @@
+package core
+
+import (
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"strings"
+	"time"
+)
+
+// Kinds of refs
+const (
+	KindTip    = "tip"
+	KindRtip   = "rtip"
+	KindBranch = "branch"
+	KindRemote = "remote"
+	KindOther  = "ref"
+)
+
+// Machine-readable description of a ref. The json names are part of tie's output format
+// and must stay stable.
+type RefRecord struct {
+	Ref  string `json:"ref"`
+	Kind string `json:"kind"`
+	// Base, tail and counts are only set for tips
+	Base   string `json:"base"`
+	Tail   string `json:"tail"`
+	Tip    string `json:"tip"`
+	Ahead  int    `json:"ahead"`
+	Behind int    `json:"behind"`
+	// The remote tip points to the same commit as the tip
+	Pushed   bool `json:"pushed"`
+	Selected bool `json:"selected"`
+	// First line of the description of the tip. It's left out of the porcelain
+	// format, whose fields are stable.
+	Title string `json:"title,omitempty"`
+}
+
+// Machine-readable status of the repository
+type StatusRecord struct {
+	Head RefRecord `json:"head"`
+	// Remote tip of the selected tip, empty when it hasn't been pushed
+	RemoteTip    string       `json:"remote_tip"`
+	RemoteAhead  int          `json:"remote_ahead"`
+	RemoteBehind int          `json:"remote_behind"`
+	Changes      []FileChange `json:"changes"`
+	Operations   []string     `json:"operations"`
+}
+
+// Machine-readable description of a commit of a tip
+type CommitRecord struct {
+	Ref     string    `json:"ref"`
+	Oid     string    `json:"oid"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	Summary string    `json:"summary"`
+	Message string    `json:"message"`
+}
+
+func RefKind(refname string) string {
+	switch {
+	case strings.HasPrefix(refname, RefsTips):
+		return KindTip
+	case strings.HasPrefix(refname, RefsRemoteTips):
+		return KindRtip
+	case strings.HasPrefix(refname, "refs/heads/"):
+		return KindBranch
+	case strings.HasPrefix(refname, "refs/remotes/"):
+		return KindRemote
+	}
+	return KindOther
+}
+
+// Describes the ref. selected is the name of the ref HEAD points to.
+func NewRefRecord(repo *git.Repository, refname, selected string) (RefRecord, error) {
+	record := RefRecord{
+		Ref:      refname,
+		Kind:     RefKind(refname),
+		Selected: refname == selected,
+	}
+
+	ref, err := repo.References.Lookup(refname)
+	if err != nil {
+		return record, err
+	}
+	record.Tip = ref.Target().String()
+
+	if record.Kind != KindTip {
+		return record, nil
+	}
+
+	tipName, _ := TipName(refname)
+	status, err := GetTipStatus(repo, tipName)
+	if err != nil {
+		return record, err
+	}
+
+	record.Base = status.Base
+	record.Tail = status.TailOid
+	record.Ahead = status.Ahead
+	record.Behind = status.Behind
+	record.Pushed = status.Pushed && status.RemoteAhead == 0 && status.RemoteBehind == 0
+	record.Title = TipTitle(repo, tipName)
+
+	return record, nil
+}
+
+func NewCommitRecord(refname string, commit *git.Commit) CommitRecord {
+	author := commit.Author()
+	return CommitRecord{
+		Ref:     refname,
+		Oid:     commit.Id().String(),
+		Author:  author.Name,
+		Email:   author.Email,
+		Date:    author.When,
+		Summary: commit.Summary(),
+		Message: commit.Message(),
+	}
+}
+
+// Tab separated fields, in the order of the json ones but the title. Empty fields are written "-".
+func (record RefRecord) Porcelain() string {
+	return porcelain("ref", record.Ref, record.Kind, record.Base, record.Tail, record.Tip,
+		record.Ahead, record.Behind, record.Pushed, record.Selected)
+}
+
+// Tab separated fields, without the message which may span several lines
+func (record CommitRecord) Porcelain() string {
+	return porcelain("commit", record.Ref, record.Oid, record.Author, record.Email,
+		record.Date.Format(time.RFC3339), record.Summary)
+}
+
+func (change FileChange) Porcelain() string {
+	return porcelain("change", change.Path, change.Change, change.Staged)
+}
+
+func porcelain(fields ...interface{}) string {
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		value := fmt.Sprint(field)
+		if len(value) == 0 {
+			value = "-"
+		}
+		values[i] = value
+	}
+	return strings.Join(values, "\t")
+}
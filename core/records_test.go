@@ -0,0 +1,60 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestRefKind(t *testing.T) {
+	assert.Equal(t, KindTip, RefKind("refs/tips/test"))
+	assert.Equal(t, KindRtip, RefKind("refs/rtips/origin/test"))
+	assert.Equal(t, KindBranch, RefKind("refs/heads/master"))
+	assert.Equal(t, KindRemote, RefKind("refs/remotes/origin/master"))
+	assert.Equal(t, KindOther, RefKind("refs/tags/v1"))
+}
+
+func TestNewRefRecord(t *testing.T) {
+	test.RunOnRemote(t, "Tip", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", true)
+		tail, _ := repo.References.Lookup(RefsTails + "test")
+		oid := test.CommitFiles(repo, RefsTips+"test", map[string]string{"foo": "foo"})
+		PushTip(repo, "test", context.Context)
+
+		record, err := NewRefRecord(repo, RefsTips+"test", RefsTips+"test")
+		assert.Nil(t, err)
+		assert.Equal(t, RefRecord{
+			Ref:      RefsTips + "test",
+			Kind:     KindTip,
+			Base:     "refs/remotes/origin/master",
+			Tail:     tail.Target().String(),
+			Tip:      oid.String(),
+			Ahead:    1,
+			Behind:   0,
+			Pushed:   true,
+			Selected: true,
+		}, record)
+		assert.Equal(t, "ref\trefs/tips/test\ttip\trefs/remotes/origin/master\t"+tail.Target().String()+"\t"+oid.String()+"\t1\t0\ttrue\ttrue", record.Porcelain())
+
+		// Not pushed anymore once the tip moved
+		test.CommitFiles(repo, RefsTips+"test", map[string]string{"bar": "bar"})
+		record, _ = NewRefRecord(repo, RefsTips+"test", "")
+		assert.False(t, record.Pushed)
+		assert.False(t, record.Selected)
+	})
+
+	test.RunOnRepo(t, "Branch", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+
+		record, err := NewRefRecord(repo, "refs/heads/master", "refs/heads/master")
+		assert.Nil(t, err)
+		assert.Equal(t, RefRecord{
+			Ref:      "refs/heads/master",
+			Kind:     KindBranch,
+			Tip:      head.Target().String(),
+			Selected: true,
+		}, record)
+		assert.Equal(t, "ref\trefs/heads/master\tbranch\t-\t-\t"+head.Target().String()+"\t0\t0\tfalse\ttrue", record.Porcelain())
+	})
+}
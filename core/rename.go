@@ -0,0 +1,137 @@
+package core
+
+import (
+	"fmt"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+)
+
+// Moves the tip, its tail, its base and its description to the new name. HEAD and the tips
+// stacked on the tip follow it. The renaming is then pushed in a single push.
+func RenameTip(repo *git.Repository, oldName, newName string, context model.Context) error {
+	tip, err := repo.References.Lookup(RefsTips + oldName)
+	if err != nil {
+		return fmt.Errorf("Tip '%v' doesn't exist.", oldName)
+	}
+
+	if !git.ReferenceIsValidName(RefsTips + newName) {
+		return fmt.Errorf("'%v' is not a valid tip name.", newName)
+	}
+
+	if _, err := repo.References.Lookup(RefsTips + newName); err == nil {
+		return fmt.Errorf("Tip '%v' already exists.", newName)
+	}
+
+	config, _ := repo.Config()
+	base, _ := config.LookupString(fmt.Sprintf("tip.%v.base", oldName))
+	remoteName, noRemote := RemoteOf(base, config)
+
+	if noRemote == nil {
+		if _, err := repo.References.Lookup(RefsRemoteTips + remoteName + "/" + newName); err == nil {
+			return fmt.Errorf("Tip '%v' already exists on %v.", newName, remoteName)
+		}
+	}
+
+	tx := NewTransaction(repo, "tie rename")
+	tx.CreateRef(RefsTips+newName, tip.Target(), false)
+
+	if tail, err := repo.References.Lookup(RefsTails + oldName); err == nil {
+		tx.CreateRef(RefsTails+newName, tail.Target(), true)
+		tx.DeleteRef(RefsTails + oldName)
+	}
+
+	if wip, err := repo.References.Lookup(RefsWip + oldName); err == nil {
+		tx.CreateRef(RefsWip+newName, wip.Target(), true)
+		tx.DeleteRef(RefsWip + oldName)
+	}
+
+	meta, noMeta := repo.References.Lookup(RefsTipMeta + oldName)
+	if noMeta == nil {
+		tx.CreateRef(RefsTipMeta+newName, meta.Target(), true)
+		tx.DeleteRef(RefsTipMeta + oldName)
+	}
+
+	tx.SetConfig(fmt.Sprintf("tip.%v.base", newName), base)
+	tx.DeleteConfig(fmt.Sprintf("tip.%v.base", oldName))
+
+	for child, childBase := range TipBases(repo) {
+		if childBase == RefsTips+oldName {
+			tx.SetConfig(fmt.Sprintf("tip.%v.base", child), RefsTips+newName)
+		}
+	}
+
+	if head, err := repo.References.Lookup("HEAD"); err == nil && head.SymbolicTarget() == RefsTips+oldName {
+		tx.CreateSymbolicRef("HEAD", RefsTips+newName)
+	}
+
+	tx.DeleteRef(RefsTips + oldName)
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if err := RenameWorktreeTip(repo, oldName, newName); err != nil {
+		return err
+	}
+
+	context.Logger.Printf("Renamed tip '%v' to '%v'\n", oldName, newName)
+
+	if noRemote != nil {
+		return nil
+	}
+
+	return pushRename(repo, oldName, newName, remoteName, noMeta == nil, context)
+}
+
+// Pushes the tip under its new name and deletes the old one on the remote
+func pushRename(repo *git.Repository, oldName, newName, remoteName string, description bool, context model.Context) error {
+	remote, err := repo.Remotes.Lookup(remoteName)
+	if err != nil {
+		return err
+	}
+
+	config, _ := repo.Config()
+	compat, noCompat := config.LookupString(PushTipsAsConfigKey)
+
+	refspecs := []string{fmt.Sprintf("+%v:%v", RefsTips+newName, RefsTips+newName)}
+	if noCompat == nil {
+		refspecs = append(refspecs, fmt.Sprintf("+%v:%v%v", RefsTips+newName, compat, newName))
+	}
+	if description {
+		refspecs = append(refspecs, fmt.Sprintf("+%v:%v", RefsTipMeta+newName, RefsTipMeta+newName))
+	}
+
+	// The old name only exists on the remote if it has been pushed
+	oldRtip, notPushed := repo.References.Lookup(RefsRemoteTips + remoteName + "/" + oldName)
+	if notPushed == nil {
+		refspecs = append(refspecs, ":"+RefsTips+oldName)
+		if noCompat == nil {
+			refspecs = append(refspecs, ":"+compat+oldName)
+		}
+		if description {
+			refspecs = append(refspecs, ":"+RefsTipMeta+oldName)
+		}
+	}
+
+	if !context.NoVerify {
+		err = RunPrePushHook(repo, remote, refspecs)
+	}
+	if err == nil {
+		err = remote.Push(refspecs, &git.PushOptions{RemoteCallbacks: context.RemoteCallbacks})
+		ApproveCredentials(context, err)
+	}
+
+	if err != nil {
+		context.Logger.Println(err.Error())
+		context.Logger.Printf("Tip '%v' has been renamed locally but not on %v.\n", oldName, remoteName)
+		return err
+	}
+
+	tip, _ := repo.References.Lookup(RefsTips + newName)
+	repo.References.Create(RefsRemoteTips+remoteName+"/"+newName, tip.Target(), true, "push tip")
+	if notPushed == nil {
+		oldRtip.Delete()
+	}
+
+	return nil
+}
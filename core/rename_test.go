@@ -0,0 +1,84 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestRenameTip(t *testing.T) {
+	test.RunOnRepo(t, "Local", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "old", "refs/heads/master", true)
+		tipOid := test.CommitFiles(repo, RefsTips+"old", map[string]string{"foo": "foo"})
+		tail, _ := repo.References.Lookup(RefsTails + "old")
+		SetTipDescription(repo, "old", "Title\n")
+		config, _ := repo.Config()
+		config.SetString("tip.child.base", RefsTips+"old")
+
+		err := RenameTip(repo, "old", "new", context.Context)
+		assert.Nil(t, err)
+
+		for _, refname := range []string{RefsTips + "old", RefsTails + "old", RefsTipMeta + "old"} {
+			_, err = repo.References.Lookup(refname)
+			assert.NotNil(t, err, refname)
+		}
+		_, err = config.LookupString("tip.old.base")
+		assert.NotNil(t, err)
+
+		tip, _ := repo.References.Lookup(RefsTips + "new")
+		assert.True(t, tip.Target().Equal(tipOid))
+		newTail, _ := repo.References.Lookup(RefsTails + "new")
+		assert.True(t, newTail.Target().Equal(tail.Target()))
+		base, _ := config.LookupString("tip.new.base")
+		assert.Equal(t, "refs/heads/master", base)
+		assert.Equal(t, "Title", TipTitle(repo, "new"))
+
+		// HEAD and the stacked tips follow the tip
+		head, _ := repo.Head()
+		assert.Equal(t, RefsTips+"new", head.Name())
+		childBase, _ := config.LookupString("tip.child.base")
+		assert.Equal(t, RefsTips+"new", childBase)
+
+		assert.Equal(t, "Renamed tip 'old' to 'new'\n", context.OutputBuffer.String())
+	})
+
+	test.RunOnRepo(t, "AlreadyExists", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "old", "refs/heads/master", false)
+		test.CreateTip(repo, "new", "refs/heads/master", false)
+
+		err := RenameTip(repo, "old", "new", context.Context)
+		assert.Equal(t, "Tip 'new' already exists.", err.Error())
+		_, err = repo.References.Lookup(RefsTips + "old")
+		assert.Nil(t, err)
+	})
+
+	test.RunOnRemote(t, "Remote", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		config, _ := repo.Config()
+		config.SetString(PushTipsAsConfigKey, "refs/heads/tips/")
+		test.CreateTip(repo, "old", "refs/remotes/origin/master", false)
+		tipOid := test.CommitFiles(repo, RefsTips+"old", map[string]string{"foo": "foo"})
+		PushTip(repo, "old", context.Context)
+
+		err := RenameTip(repo, "old", "new", context.Context)
+		assert.Nil(t, err)
+
+		// The old names are gone from the remote
+		for _, refname := range []string{RefsTips + "old", "refs/heads/tips/old"} {
+			_, err = remote.References.Lookup(refname)
+			assert.NotNil(t, err, refname)
+		}
+		_, err = repo.References.Lookup(RefsRemoteTips + "origin/old")
+		assert.NotNil(t, err)
+
+		// The new ones are pushed
+		for _, refname := range []string{RefsTips + "new", "refs/heads/tips/new"} {
+			ref, err := remote.References.Lookup(refname)
+			assert.Nil(t, err, refname)
+			assert.True(t, ref.Target().Equal(tipOid))
+		}
+		rtip, err := repo.References.Lookup(RefsRemoteTips + "origin/new")
+		assert.Nil(t, err)
+		assert.True(t, rtip.Target().Equal(tipOid))
+	})
+}
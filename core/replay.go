@@ -0,0 +1,47 @@
+package core
+
+import (
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+)
+
+// Replays commits on top of onto without touching the index nor the working tree.
+// Returns the last replayed commit, or an error on the first conflict.
+func ReplayCommits(repo *git.Repository, commits []*git.Commit, onto *git.Commit) (*git.Commit, error) {
+	head := onto
+	committer, _ := repo.DefaultSignature()
+
+	for _, commit := range commits {
+		// Keep the commits that don't need to change
+		if commit.ParentCount() > 0 && commit.ParentId(0).Equal(head.Id()) {
+			head = commit
+			continue
+		}
+
+		index, err := cherrypickIndex(repo, commit, head)
+		if err != nil {
+			return nil, err
+		}
+
+		if index.HasConflicts() {
+			return nil, fmt.Errorf("Conflict while replaying %v.", commit.Id().String()[:7])
+		}
+
+		treeOid, _ := index.WriteTreeTo(repo)
+
+		// Drop the commits that have nothing left to apply
+		if treeOid.Equal(head.TreeId()) && !isEmpty(commit) {
+			continue
+		}
+
+		tree, _ := repo.LookupTree(treeOid)
+		oid, err := CreateCommit(repo, ReplaySigner(repo, commit), "", commit.Author(), committer, commit.Message(), tree, head)
+		if err != nil {
+			return nil, err
+		}
+
+		head, _ = repo.LookupCommit(oid)
+	}
+
+	return head, nil
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestReplayCommits(t *testing.T) {
+	// Commits file on master then selects a tip created before that commit
+	setup := func(repo *git.Repository, file string) (initial, master *git.Commit) {
+		head, _ := repo.Head()
+		initial, _ = repo.LookupCommit(head.Target())
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+
+		test.WriteFile(repo, true, file, "master")
+		masterOid, _ := test.Commit(repo, nil)
+		master, _ = repo.LookupCommit(masterOid)
+
+		tree, _ := initial.Tree()
+		repo.CheckoutTree(tree, &git.CheckoutOpts{Strategy: git.CheckoutForce})
+		repo.References.CreateSymbolic("HEAD", RefsTips+"test", true, "")
+		return initial, master
+	}
+
+	test.RunOnRepo(t, "OnNewBase", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		initial, master := setup(repo, "master")
+		test.WriteFile(repo, true, "foo", "foo")
+		test.Commit(repo, nil)
+		test.WriteFile(repo, true, "bar", "bar")
+		test.Commit(repo, nil)
+
+		commits, _ := TipCommits(repo, "test")
+		replayed, err := ReplayCommits(repo, commits, master)
+		assert.Nil(t, err)
+		assert.True(t, replayed.Parent(0).ParentId(0).Equal(master.Id()))
+
+		// Replaying on the same base keeps the commits
+		same, err := ReplayCommits(repo, commits, initial)
+		assert.Nil(t, err)
+		assert.True(t, same.Id().Equal(commits[1].Id()))
+
+		// Nothing has been checked out
+		tip, _ := repo.References.Lookup(RefsTips + "test")
+		assert.True(t, tip.Target().Equal(commits[1].Id()))
+		test.StatusClean(t, repo)
+	})
+
+	test.RunOnRepo(t, "Conflict", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		_, master := setup(repo, "foo")
+		test.WriteFile(repo, true, "foo", "tip")
+		oid, _ := test.Commit(repo, nil)
+
+		commits, _ := TipCommits(repo, "test")
+		_, err := ReplayCommits(repo, commits, master)
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Conflict while replaying "+oid.String()[:7]+".", err.Error())
+		}
+	})
+}
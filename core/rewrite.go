@@ -0,0 +1,592 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/apflieger/tie/model"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Directory under .git where tie keeps its own state files
+const TieDir = "tie"
+
+const rewriteStateFile = "rewrite"
+
+// Actions of a rewrite step. They mimic the verbs of git rebase -i.
+const (
+	RewritePick   = "pick"
+	RewriteReword = "reword"
+	RewriteEdit   = "edit"
+	RewriteSquash = "squash"
+	RewriteFixup  = "fixup"
+	RewriteDrop   = "drop"
+)
+
+type RewriteStep struct {
+	Action string
+	Commit string
+	// Replaces the message of the commit when not empty
+	Message string
+	// Open the editor on the message (reword and squash only)
+	Edit bool
+}
+
+// Resumable state of a rewrite, persisted in .git/tie/rewrite
+type Rewrite struct {
+	Tip string
+	// Target of the tip before the rewrite started
+	Orig string
+	// Last commit produced by the rewrite
+	Head     string
+	Todo     []RewriteStep
+	Stopped  *RewriteStep
+	Conflict bool
+	// Optional new tail and base of the tip, set when the rewrite finishes
+	Tail string
+	Base string
+	// Rewrite to run once this one has finished
+	Next *Rewrite
+	// Command continuing the rewrite after a conflict, 'rewrite' when empty
+	Command string
+	// Tips rewritten by the previous rewrites of the chain, put back on abort
+	Done []RewrittenTip
+}
+
+// Tip as it was before a rewrite of the chain. Tail and Base are empty when they didn't exist.
+type RewrittenTip struct {
+	Tip  string
+	Orig string
+	Tail string
+	Base string
+}
+
+func rewriteStatePath(repo *git.Repository) string {
+	return filepath.Join(repo.Path(), TieDir, rewriteStateFile)
+}
+
+func RewriteInProgress(repo *git.Repository) bool {
+	_, err := os.Stat(rewriteStatePath(repo))
+	return err == nil
+}
+
+func LoadRewrite(repo *git.Repository) (*Rewrite, error) {
+	bytes, err := ioutil.ReadFile(rewriteStatePath(repo))
+	if err != nil {
+		return nil, errors.New("Not in a rewrite sequence.")
+	}
+
+	rewrite := &Rewrite{}
+	err = json.Unmarshal(bytes, rewrite)
+
+	return rewrite, err
+}
+
+func (rewrite *Rewrite) save(repo *git.Repository) error {
+	bytes, _ := json.MarshalIndent(rewrite, "", "  ")
+	os.MkdirAll(filepath.Join(repo.Path(), TieDir), 0755)
+	return ioutil.WriteFile(rewriteStatePath(repo), bytes, 0644)
+}
+
+// Returns the commits of the tip, from the oldest to the most recent
+func TipCommits(repo *git.Repository, tipName string) ([]*git.Commit, error) {
+	tip, err := repo.References.Lookup(RefsTips + tipName)
+	if err != nil {
+		return nil, err
+	}
+
+	tail, err := repo.References.Lookup(RefsTails + tipName)
+	if err != nil {
+		return nil, err
+	}
+
+	return CommitsBetween(repo, tail.Target(), tip.Target())
+}
+
+// Returns the commits of from..to, from the oldest to the most recent
+func CommitsBetween(repo *git.Repository, from, to *git.Oid) ([]*git.Commit, error) {
+	walk, err := repo.Walk()
+	if err != nil {
+		return nil, err
+	}
+	defer walk.Free()
+
+	walk.Sorting(git.SortTopological | git.SortReverse)
+	walk.Push(to)
+	walk.Hide(from)
+
+	commits := []*git.Commit{}
+	err = walk.Iterate(func(commit *git.Commit) bool {
+		commits = append(commits, commit)
+		return true
+	})
+
+	return commits, err
+}
+
+func WorkdirClean(repo *git.Repository) bool {
+	statusList, _ := repo.StatusList(&git.StatusOptions{
+		Show: git.StatusShowIndexAndWorkdir,
+	})
+	statusCount, _ := statusList.EntryCount()
+	return statusCount == 0
+}
+
+// Starts the rewrite of rewrite.Tip. The steps are replayed on top of rewrite.Head,
+// or on top of the base of the tip if Head is empty.
+func StartRewrite(repo *git.Repository, rewrite *Rewrite, context model.Context) error {
+	if RewriteInProgress(repo) {
+		return errors.New("A rewrite is already in progress. Run 'tie rewrite continue' or 'tie rewrite abort'.")
+	}
+
+	if !WorkdirClean(repo) {
+		return errors.New("Status should be clean before rewriting.")
+	}
+
+	if err := validateSteps(rewrite.Todo); err != nil {
+		return err
+	}
+
+	// The tips rewritten afterwards are checked now, not once the first one is done
+	for next := rewrite; next != nil; next = next.Next {
+		if err := CheckTipNotInWorktree(repo, next.Tip); err != nil {
+			return err
+		}
+	}
+
+	tip, err := repo.References.Lookup(RefsTips + rewrite.Tip)
+	if err != nil {
+		return err
+	}
+
+	rewrite.Orig = tip.Target().String()
+
+	if len(rewrite.Head) == 0 {
+		// Replay on the current target of the base, which becomes the new tail
+		config, _ := repo.Config()
+		baseRefName, err := config.LookupString(fmt.Sprintf("tip.%v.base", rewrite.Tip))
+		if err != nil {
+			return err
+		}
+		base, err := repo.References.Lookup(baseRefName)
+		if err != nil {
+			return err
+		}
+		rewrite.Head = base.Target().String()
+		rewrite.Tail = rewrite.Head
+	}
+
+	return rewrite.run(repo, context)
+}
+
+// Returns the steps that replay the commits as they are
+func PickSteps(commits []*git.Commit) []RewriteStep {
+	steps := []RewriteStep{}
+	for _, commit := range commits {
+		steps = append(steps, RewriteStep{
+			Action: RewritePick,
+			Commit: commit.Id().String(),
+		})
+	}
+	return steps
+}
+
+func validateSteps(steps []RewriteStep) error {
+	for _, step := range steps {
+		switch step.Action {
+		case RewriteDrop:
+			continue
+		case RewriteSquash, RewriteFixup:
+			return fmt.Errorf("Cannot %v without a previous commit.", step.Action)
+		case RewritePick, RewriteReword, RewriteEdit:
+			return nil
+		default:
+			return fmt.Errorf("Unknown rewrite action '%v'.", step.Action)
+		}
+	}
+	return nil
+}
+
+// Resumes a rewrite that stopped on a conflict or on an edit step
+func ContinueRewrite(repo *git.Repository, context model.Context) error {
+	rewrite, err := LoadRewrite(repo)
+	if err != nil {
+		return err
+	}
+
+	if rewrite.Stopped != nil {
+		index, _ := repo.Index()
+		if index.HasConflicts() {
+			return errors.New("Conflicts must be resolved before continuing.")
+		}
+
+		// Commits made or amended while stopped are part of the rewrite
+		tip, err := repo.References.Lookup(RefsTips + rewrite.Tip)
+		if err != nil {
+			return err
+		}
+		rewrite.Head = tip.Target().String()
+
+		treeOid, _ := index.WriteTree()
+		tree, _ := repo.LookupTree(treeOid)
+
+		if rewrite.Conflict {
+			err = rewrite.apply(repo, *rewrite.Stopped, tree, context)
+		} else {
+			err = rewrite.amendHead(repo, tree)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		rewrite.Stopped = nil
+		rewrite.Conflict = false
+	}
+
+	return rewrite.run(repo, context)
+}
+
+// Puts the tip back where it was before the rewrite, and so are the tips
+// already rewritten by the previous rewrites of the chain
+func AbortRewrite(repo *git.Repository) error {
+	rewrite, err := LoadRewrite(repo)
+	if err != nil {
+		return err
+	}
+
+	if err := resetTip(repo, rewrite.Tip, rewrite.Orig); err != nil {
+		return err
+	}
+
+	config, _ := repo.Config()
+	for i := len(rewrite.Done) - 1; i >= 0; i-- {
+		done := rewrite.Done[i]
+		if err := resetTip(repo, done.Tip, done.Orig); err != nil {
+			return err
+		}
+		if tail, err := git.NewOid(done.Tail); err == nil {
+			repo.References.Create(RefsTails+done.Tip, tail, true, "tie rewrite abort")
+		}
+		if len(done.Base) > 0 {
+			config.SetString(fmt.Sprintf("tip.%v.base", done.Tip), done.Base)
+		}
+	}
+
+	return os.Remove(rewriteStatePath(repo))
+}
+
+func resetTip(repo *git.Repository, tipName, orig string) error {
+	origOid, _ := git.NewOid(orig)
+	tip, err := repo.References.Lookup(RefsTips + tipName)
+	if err != nil {
+		return err
+	}
+
+	head, _ := repo.Head()
+	if head != nil && head.Name() == tip.Name() {
+		// Also discards the conflicts and the changes made while stopped
+		commit, _ := repo.LookupCommit(origOid)
+		return repo.ResetToCommit(commit, git.ResetHard, &git.CheckoutOpts{Strategy: git.CheckoutForce})
+	}
+
+	_, err = tip.SetTarget(origOid, "tie rewrite abort")
+	return err
+}
+
+func (rewrite *Rewrite) run(repo *git.Repository, context model.Context) error {
+	for len(rewrite.Todo) > 0 {
+		step := rewrite.Todo[0]
+		rewrite.Todo = rewrite.Todo[1:]
+
+		if step.Action == RewriteDrop {
+			continue
+		}
+
+		stepOid, _ := git.NewOid(step.Commit)
+		commit, err := repo.LookupCommit(stepOid)
+		if err != nil {
+			return err
+		}
+
+		headOid, _ := git.NewOid(rewrite.Head)
+		headCommit, _ := repo.LookupCommit(headOid)
+
+		// Keep the commits that don't need to change
+		if fastForward(step, commit, headOid) {
+			rewrite.Head = step.Commit
+		} else if err := rewrite.replay(repo, step, commit, headCommit, context); err != nil {
+			return err
+		}
+
+		if step.Action == RewriteEdit {
+			rewrite.Stopped = &step
+			rewrite.stop(repo, nil)
+			context.Logger.Printf("Stopped at %v %v\n", commit.Id().String()[:7], commit.Summary())
+			context.Logger.Println("Change the files and run 'tie rewrite continue' once done.")
+			return nil
+		}
+	}
+
+	return rewrite.finish(repo, context)
+}
+
+// Returns the command that started the rewrite, which continues or aborts it
+func (rewrite *Rewrite) CommandName() string {
+	if rewrite.Command == "" {
+		return "rewrite"
+	}
+	return rewrite.Command
+}
+
+func fastForward(step RewriteStep, commit *git.Commit, onto *git.Oid) bool {
+	return (step.Action == RewritePick || step.Action == RewriteEdit) &&
+		len(step.Message) == 0 &&
+		commit.ParentCount() > 0 &&
+		commit.ParentId(0).Equal(onto)
+}
+
+// Applies the changes of commit on top of headCommit
+func (rewrite *Rewrite) replay(repo *git.Repository, step RewriteStep, commit, headCommit *git.Commit, context model.Context) error {
+	index, err := cherrypickIndex(repo, commit, headCommit)
+	if err != nil {
+		return err
+	}
+
+	if index.HasConflicts() {
+		rewrite.Stopped = &step
+		rewrite.Conflict = true
+		rewrite.stop(repo, index)
+		return fmt.Errorf("Conflict while rewriting %v. Resolve it and run 'tie %v continue'.", commit.Id().String()[:7], rewrite.CommandName())
+	}
+
+	treeOid, _ := index.WriteTreeTo(repo)
+	tree, _ := repo.LookupTree(treeOid)
+
+	// Drop the commits that have nothing left to apply
+	if step.Action == RewritePick && treeOid.Equal(headCommit.TreeId()) && !isEmpty(commit) {
+		return nil
+	}
+
+	return rewrite.apply(repo, step, tree, context)
+}
+
+func isEmpty(commit *git.Commit) bool {
+	return commit.ParentCount() > 0 && commit.TreeId().Equal(commit.Parent(0).TreeId())
+}
+
+// Merges the changes of commit on top of onto
+func cherrypickIndex(repo *git.Repository, commit, onto *git.Commit) (*git.Index, error) {
+	var ancestor *git.Tree
+	if commit.ParentCount() > 0 {
+		ancestor, _ = commit.Parent(0).Tree()
+	}
+
+	ours, _ := onto.Tree()
+	theirs, _ := commit.Tree()
+
+	return repo.MergeTrees(ancestor, ours, theirs, nil)
+}
+
+// Creates the commit of the step with the given tree
+func (rewrite *Rewrite) apply(repo *git.Repository, step RewriteStep, tree *git.Tree, context model.Context) error {
+	stepOid, _ := git.NewOid(step.Commit)
+	commit, _ := repo.LookupCommit(stepOid)
+	headOid, _ := git.NewOid(rewrite.Head)
+	headCommit, _ := repo.LookupCommit(headOid)
+	committer, _ := repo.DefaultSignature()
+
+	author := commit.Author()
+	message := commit.Message()
+	parents := []*git.Commit{headCommit}
+
+	switch step.Action {
+	case RewriteSquash, RewriteFixup:
+		// Replace the previous commit
+		author = headCommit.Author()
+		parents = []*git.Commit{}
+		for i := uint(0); i < headCommit.ParentCount(); i++ {
+			parents = append(parents, headCommit.Parent(i))
+		}
+		if step.Action == RewriteSquash {
+			message = headCommit.Message() + "\n" + commit.Message()
+		} else {
+			message = headCommit.Message()
+		}
+	}
+
+	if len(step.Message) > 0 {
+		message = FormatCommitMessage(step.Message)
+	}
+
+	if step.Edit {
+		var err error
+		message, err = editMessage(repo, message, context)
+		if err != nil {
+			return err
+		}
+	}
+
+	oid, err := CreateCommit(repo, ReplaySigner(repo, commit), "", author, committer, message, tree, parents...)
+	if err != nil {
+		return err
+	}
+
+	rewrite.Head = oid.String()
+
+	return nil
+}
+
+func editMessage(repo *git.Repository, message string, context model.Context) (string, error) {
+	config, _ := repo.Config()
+	commitEditMsgFile := filepath.Join(repo.Path(), "COMMIT_EDITMSG")
+	ioutil.WriteFile(commitEditMsgFile, []byte(message), 0644)
+
+	edited, err := context.OpenEditor(config, commitEditMsgFile)
+	if err != nil {
+		return "", err
+	}
+
+	return FormatCommitMessage(edited), nil
+}
+
+// Replaces the last rewritten commit if the given tree is different
+func (rewrite *Rewrite) amendHead(repo *git.Repository, tree *git.Tree) error {
+	headOid, _ := git.NewOid(rewrite.Head)
+	headCommit, _ := repo.LookupCommit(headOid)
+
+	if headCommit.TreeId().Equal(tree.Id()) {
+		return nil
+	}
+
+	committer, _ := repo.DefaultSignature()
+	oid, err := AmendCommit(repo, CommitSigner(repo), "", headCommit, headCommit.Author(), committer, headCommit.Message(), tree)
+	if err != nil {
+		return err
+	}
+
+	rewrite.Head = oid.String()
+
+	return nil
+}
+
+// Selects the tip on the last rewritten commit and saves the state.
+// conflicts is the index to checkout in case of a conflict.
+func (rewrite *Rewrite) stop(repo *git.Repository, conflicts *git.Index) error {
+	tip, _ := repo.References.Lookup(RefsTips + rewrite.Tip)
+	headOid, _ := git.NewOid(rewrite.Head)
+	headCommit, _ := repo.LookupCommit(headOid)
+	headTree, _ := headCommit.Tree()
+
+	checkout(repo, tip, headCommit)
+
+	if conflicts != nil {
+		repo.CheckoutIndex(conflicts, &git.CheckoutOpts{
+			Strategy: git.CheckoutSafe | git.CheckoutAllowConflicts,
+			Baseline: headTree,
+		})
+	}
+
+	return rewrite.save(repo)
+}
+
+// Checks out commit and moves the tip on it. If the tip is not selected, it gets selected.
+func checkout(repo *git.Repository, tip *git.Reference, commit *git.Commit) {
+	head, _ := repo.Head()
+
+	if head == nil || head.Name() != tip.Name() {
+		commit, _ := repo.LookupCommit(tip.Target())
+		tipTree, _ := commit.Tree()
+		repo.CheckoutTree(tipTree, &git.CheckoutOpts{Strategy: git.CheckoutSafe})
+		repo.References.CreateSymbolic("HEAD", tip.Name(), true, "tie rewrite")
+	}
+
+	baseline, _ := repo.LookupCommit(tip.Target())
+	baselineTree, _ := baseline.Tree()
+	tree, _ := commit.Tree()
+	repo.CheckoutTree(tree, &git.CheckoutOpts{
+		Strategy: git.CheckoutSafe,
+		Baseline: baselineTree,
+	})
+
+	tip.SetTarget(commit.Id(), "tie rewrite")
+}
+
+func (rewrite *Rewrite) finish(repo *git.Repository, context model.Context) error {
+	tip, err := repo.References.Lookup(RefsTips + rewrite.Tip)
+	if err != nil {
+		return err
+	}
+
+	headOid, _ := git.NewOid(rewrite.Head)
+
+	head, _ := repo.Head()
+	if head != nil && head.Name() == tip.Name() {
+		headCommit, _ := repo.LookupCommit(headOid)
+		checkout(repo, tip, headCommit)
+	} else {
+		tip.SetTarget(headOid, "tie rewrite")
+	}
+
+	done := RewrittenTip{Tip: rewrite.Tip, Orig: rewrite.Orig}
+	if oldTail, err := repo.References.Lookup(RefsTails + rewrite.Tip); err == nil {
+		done.Tail = oldTail.Target().String()
+	}
+
+	if len(rewrite.Tail) > 0 {
+		tail, _ := git.NewOid(rewrite.Tail)
+		repo.References.Create(RefsTails+rewrite.Tip, tail, true, "tie rewrite")
+	}
+
+	config, _ := repo.Config()
+	baseKey := fmt.Sprintf("tip.%v.base", rewrite.Tip)
+	oldBase, _ := config.LookupString(baseKey)
+	done.Base = oldBase
+
+	if len(rewrite.Base) > 0 {
+		config.SetString(baseKey, rewrite.Base)
+	}
+
+	os.Remove(rewriteStatePath(repo))
+
+	pushErr := PushTip(repo, rewrite.Tip, context)
+
+	// A tip moved to a base of another remote leaves the previous one
+	if len(rewrite.Base) > 0 && rewrite.Base != oldBase {
+		oldRemote, notRemote := RemoteOf(oldBase, config)
+		newRemote, _ := RemoteOf(rewrite.Base, config)
+		if notRemote == nil && oldRemote != newRemote {
+			_, noMeta := repo.References.Lookup(RefsTipMeta + rewrite.Tip)
+			if _, err := DeleteRemoteTip(repo, rewrite.Tip, oldBase, noMeta == nil, context); err == nil {
+				context.Logger.Printf("Deleted tip '%v' on %v\n", rewrite.Tip, oldRemote)
+			}
+		}
+	}
+
+	context.Logger.Printf("Rewrote tip '%v'\n", rewrite.Tip)
+
+	// Local tips are not pushed, so only a failed push of a remote tip is reported
+	base, _ := config.LookupString(baseKey)
+	if remoteName, notRemote := RemoteOf(base, config); notRemote == nil && pushErr != nil && !IsHookError(pushErr) {
+		context.Logger.Println(pushErr.Error())
+		context.Logger.Printf("Tip '%v' has been rewritten locally but not on %v.\n", rewrite.Tip, remoteName)
+	}
+
+	if rewrite.Next != nil {
+		rewrite.Next.Command = rewrite.Command
+		rewrite.Next.Done = append(rewrite.Done, done)
+		if err := StartRewrite(repo, rewrite.Next, context); err != nil {
+			return err
+		}
+	}
+
+	// The tip is rewritten when the pre-push hook fails, only the push is aborted
+	if IsHookError(pushErr) {
+		return pushErr
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestTipCommits(t *testing.T) {
+	test.RunOnRepo(t, "OldestFirst", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+
+		first, _ := test.Commit(repo, nil)
+		second, _ := test.Commit(repo, nil)
+
+		commits, err := TipCommits(repo, "test")
+		assert.Nil(t, err)
+		if assert.Equal(t, 2, len(commits)) {
+			assert.True(t, commits[0].Id().Equal(first))
+			assert.True(t, commits[1].Id().Equal(second))
+		}
+	})
+
+	test.RunOnRepo(t, "EmptyTip", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+
+		commits, err := TipCommits(repo, "test")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(commits))
+	})
+
+	test.RunOnRepo(t, "NoTail", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		repo.References.Create(RefsTips+"test", head.Target(), false, "")
+
+		_, err := TipCommits(repo, "test")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestRewrite(t *testing.T) {
+	test.RunOnRepo(t, "DirtyStateError", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		oid, _ := test.Commit(repo, nil)
+		head, _ := repo.Head()
+
+		test.WriteFile(repo, true, "foo", "bar")
+
+		err := StartRewrite(repo, &Rewrite{
+			Tip:  "test",
+			Head: head.Target().String(),
+			Todo: []RewriteStep{{Action: RewritePick, Commit: oid.String()}},
+		}, context.Context)
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Status should be clean before rewriting.", err.Error())
+		}
+		assert.False(t, RewriteInProgress(repo))
+	})
+
+	test.RunOnRepo(t, "SquashFirstError", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+		oid, _ := test.Commit(repo, nil)
+		tail, _ := repo.References.Lookup(RefsTails + "test")
+
+		err := StartRewrite(repo, &Rewrite{
+			Tip:  "test",
+			Head: tail.Target().String(),
+			Todo: []RewriteStep{{Action: RewriteSquash, Commit: oid.String()}},
+		}, context.Context)
+
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "Cannot squash without a previous commit.", err.Error())
+		}
+	})
+}
@@ -0,0 +1,272 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Values of gpg.format
+const (
+	SigningFormatOpenPGP = "openpgp"
+	SigningFormatX509    = "x509"
+	SigningFormatSSH     = "ssh"
+)
+
+// When set, replayed commits that were signed are signed again, even without commit.gpgsign
+const KeepSignaturesConfigKey = "tie.keepSignatures"
+
+// Signs commits through an external program, like git does
+type Signer struct {
+	Format  string
+	Key     string
+	Program string
+}
+
+// Returns the signer configured by gpg.format, user.signingkey and gpg.<format>.program
+func NewSigner(repo *git.Repository) *Signer {
+	config, _ := repo.Config()
+
+	format, _ := config.LookupString("gpg.format")
+	if format == "" {
+		format = SigningFormatOpenPGP
+	}
+
+	key, _ := config.LookupString("user.signingkey")
+
+	program, _ := config.LookupString(fmt.Sprintf("gpg.%v.program", format))
+	if program == "" && format == SigningFormatOpenPGP {
+		program, _ = config.LookupString("gpg.program")
+	}
+	if program == "" {
+		switch format {
+		case SigningFormatSSH:
+			program = "ssh-keygen"
+		case SigningFormatX509:
+			program = "gpgsm"
+		default:
+			program = "gpg"
+		}
+	}
+
+	return &Signer{Format: format, Key: key, Program: program}
+}
+
+// Returns the signer of the new commits, nil unless commit.gpgsign is set
+func CommitSigner(repo *git.Repository) *Signer {
+	config, _ := repo.Config()
+	if sign, _ := config.LookupBool("commit.gpgsign"); !sign {
+		return nil
+	}
+
+	return NewSigner(repo)
+}
+
+// Returns the signer of a commit replaying original, nil if it shouldn't be signed
+func ReplaySigner(repo *git.Repository, original *git.Commit) *Signer {
+	if signer := CommitSigner(repo); signer != nil {
+		return signer
+	}
+
+	config, _ := repo.Config()
+	if keep, _ := config.LookupBool(KeepSignaturesConfigKey); keep && IsSigned(repo, original) {
+		return NewSigner(repo)
+	}
+
+	return nil
+}
+
+// Tells whether the commit carries a signature
+func IsSigned(repo *git.Repository, commit *git.Commit) bool {
+	odb, err := repo.Odb()
+	if err != nil {
+		return false
+	}
+
+	object, err := odb.Read(commit.Id())
+	if err != nil {
+		return false
+	}
+	defer object.Free()
+
+	header := strings.SplitN(string(object.Data()), "\n\n", 2)[0]
+	for _, line := range strings.Split(header, "\n") {
+		if strings.HasPrefix(line, "gpgsig ") || strings.HasPrefix(line, "gpgsig-sha256 ") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the detached signature of the payload. The committer identifies the
+// key when user.signingkey isn't set.
+func (signer *Signer) Sign(payload []byte, committer *git.Signature) (string, error) {
+	switch signer.Format {
+	case SigningFormatOpenPGP, SigningFormatX509:
+		return signer.signGpg(payload, committer)
+	case SigningFormatSSH:
+		return signer.signSsh(payload)
+	default:
+		return "", fmt.Errorf("Unsupported signing format '%v'.", signer.Format)
+	}
+}
+
+func (signer *Signer) signGpg(payload []byte, committer *git.Signature) (string, error) {
+	key := signer.Key
+	if key == "" {
+		key = fmt.Sprintf("%v <%v>", committer.Name, committer.Email)
+	}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd := exec.Command(signer.Program, "--status-fd=2", "-bsau", key)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	// Like git, rely on the status of gpg rather than on its exit code
+	if err := cmd.Run(); err != nil || !strings.Contains(stderr.String(), "[GNUPG:] SIG_CREATED ") {
+		return "", signingError(err, stderr)
+	}
+
+	return stdout.String(), nil
+}
+
+func (signer *Signer) signSsh(payload []byte) (string, error) {
+	if signer.Key == "" {
+		return "", errors.New("user.signingkey is required to sign with ssh.")
+	}
+
+	payloadFile, err := writeTempFile("tie-signing-payload", payload)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(payloadFile)
+	defer os.Remove(payloadFile + ".sig")
+
+	args := []string{"-Y", "sign", "-n", "git", "-f"}
+
+	// The key can be given literally, in which case the private key is in the ssh agent
+	if literal := strings.TrimPrefix(signer.Key, "key::"); literal != signer.Key || strings.HasPrefix(literal, "ssh-") {
+		keyFile, err := writeTempFile("tie-signing-key", []byte(literal))
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(keyFile)
+		args = append(args, keyFile, "-U")
+	} else {
+		args = append(args, signer.Key)
+	}
+
+	stderr := new(bytes.Buffer)
+	cmd := exec.Command(signer.Program, append(args, payloadFile)...)
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", signingError(err, stderr)
+	}
+
+	signature, err := ioutil.ReadFile(payloadFile + ".sig")
+	if err != nil {
+		return "", signingError(err, stderr)
+	}
+
+	return string(signature), nil
+}
+
+func writeTempFile(prefix string, content []byte) (string, error) {
+	file, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	_, err = file.Write(content)
+	return file.Name(), err
+}
+
+func signingError(err error, stderr *bytes.Buffer) error {
+	detail := strings.TrimSpace(stderr.String())
+	if detail == "" && err != nil {
+		detail = err.Error()
+	}
+
+	return fmt.Errorf("Failed to sign the commit (%v).", detail)
+}
+
+// Creates a commit signed by the signer, or a regular one when the signer is nil.
+// Like repo.CreateCommit, the reference is moved to the commit when refname isn't empty.
+func CreateCommit(repo *git.Repository, signer *Signer, refname string, author, committer *git.Signature, message string, tree *git.Tree, parents ...*git.Commit) (*git.Oid, error) {
+	if signer == nil {
+		return repo.CreateCommit(refname, author, committer, message, tree, parents...)
+	}
+
+	header := new(bytes.Buffer)
+	header.WriteString(fmt.Sprintf("tree %v\n", tree.Id()))
+	for _, parent := range parents {
+		header.WriteString(fmt.Sprintf("parent %v\n", parent.Id()))
+	}
+	header.WriteString(fmt.Sprintf("author %v\n", formatSignature(author)))
+	header.WriteString(fmt.Sprintf("committer %v\n", formatSignature(committer)))
+
+	signature, err := signer.Sign([]byte(header.String()+"\n"+message), committer)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature is a header whose continuation lines start with a space
+	signed := header.String() +
+		"gpgsig " + strings.Replace(strings.TrimRight(signature, "\n"), "\n", "\n ", -1) + "\n" +
+		"\n" + message
+
+	odb, err := repo.Odb()
+	if err != nil {
+		return nil, err
+	}
+
+	oid, err := odb.Write([]byte(signed), git.ObjectCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	if refname != "" {
+		summary := strings.SplitN(message, "\n", 2)[0]
+		if _, err := repo.References.Create(refname, oid, true, "commit: "+summary); err != nil {
+			return nil, err
+		}
+	}
+
+	return oid, nil
+}
+
+// Replaces commit by a new one having the same parents, signed by the signer if it's not nil
+func AmendCommit(repo *git.Repository, signer *Signer, refname string, commit *git.Commit, author, committer *git.Signature, message string, tree *git.Tree) (*git.Oid, error) {
+	if signer == nil {
+		return commit.Amend(refname, author, committer, message, tree)
+	}
+
+	parents := []*git.Commit{}
+	for i := uint(0); i < commit.ParentCount(); i++ {
+		parents = append(parents, commit.Parent(i))
+	}
+
+	return CreateCommit(repo, signer, refname, author, committer, message, tree, parents...)
+}
+
+// Formats a signature as in the author and committer headers: Name <email> timestamp offset
+func formatSignature(signature *git.Signature) string {
+	_, offset := signature.When.Zone()
+
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+
+	return fmt.Sprintf("%v <%v> %v %c%02d%02d", signature.Name, signature.Email, signature.When.Unix(), sign, offset/3600, offset%3600/60)
+}
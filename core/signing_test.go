@@ -0,0 +1,138 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fakeSignature = "-----BEGIN SIGNATURE-----\nc2lnbmF0dXJl\n-----END SIGNATURE-----\n"
+
+// Installs a fake signing program in the git dir
+func writeSigningProgram(repo *git.Repository, script string) string {
+	program := filepath.Join(repo.Path(), "sign")
+	ioutil.WriteFile(program, []byte("#!/bin/sh\n"+script+"\n"), 0755)
+	return program
+}
+
+func rawCommit(repo *git.Repository, oid *git.Oid) string {
+	odb, _ := repo.Odb()
+	object, _ := odb.Read(oid)
+	return string(object.Data())
+}
+
+func TestCreateCommit(t *testing.T) {
+	test.RunOnRepo(t, "OpenPGP", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		args := filepath.Join(repo.Path(), "args")
+		payload := filepath.Join(repo.Path(), "payload")
+		signer := &Signer{Format: SigningFormatOpenPGP, Key: "ABCD"}
+		signer.Program = writeSigningProgram(repo, `echo "$@" > `+args+`; cat > `+payload+`
+echo "[GNUPG:] SIG_CREATED D 1 8 00" >&2
+printf -- "`+strings.Replace(fakeSignature, "\n", "\\n", -1)+`"`)
+
+		head, _ := repo.Head()
+		parent, _ := repo.LookupCommit(head.Target())
+		tree, _ := parent.Tree()
+		signature, _ := repo.DefaultSignature()
+
+		oid, err := CreateCommit(repo, signer, head.Name(), signature, signature, "Signed\n", tree, parent)
+		assert.Nil(t, err)
+
+		commit, _ := repo.LookupCommit(oid)
+		assert.Equal(t, "Signed\n", commit.Message())
+		assert.True(t, commit.Parent(0).Id().Equal(parent.Id()))
+		assert.True(t, IsSigned(repo, commit))
+		assert.False(t, IsSigned(repo, parent))
+
+		head, _ = repo.Head()
+		assert.True(t, head.Target().Equal(oid))
+
+		content, _ := ioutil.ReadFile(args)
+		assert.Equal(t, "--status-fd=2 -bsau ABCD\n", string(content))
+
+		// The payload is the commit without its signature
+		raw := rawCommit(repo, oid)
+		signedPayload, _ := ioutil.ReadFile(payload)
+		assert.Contains(t, raw, "\ngpgsig -----BEGIN SIGNATURE-----\n c2lnbmF0dXJl\n -----END SIGNATURE-----\n\nSigned\n")
+		withoutSignature := strings.Replace(raw, "gpgsig -----BEGIN SIGNATURE-----\n c2lnbmF0dXJl\n -----END SIGNATURE-----\n", "", 1)
+		assert.Equal(t, withoutSignature, string(signedPayload))
+	})
+
+	test.RunOnRepo(t, "SSH", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		args := filepath.Join(repo.Path(), "args")
+		signer := &Signer{Format: SigningFormatSSH, Key: "/home/me/.ssh/id_ed25519"}
+		signer.Program = writeSigningProgram(repo, `echo "$@" > `+args+`
+for file; do :; done
+printf -- "`+strings.Replace(fakeSignature, "\n", "\\n", -1)+`" > "$file.sig"`)
+
+		head, _ := repo.Head()
+		parent, _ := repo.LookupCommit(head.Target())
+		tree, _ := parent.Tree()
+		signature, _ := repo.DefaultSignature()
+
+		oid, err := CreateCommit(repo, signer, "", signature, signature, "Signed\n", tree, parent)
+		assert.Nil(t, err)
+
+		commit, _ := repo.LookupCommit(oid)
+		assert.True(t, IsSigned(repo, commit))
+
+		content, _ := ioutil.ReadFile(args)
+		assert.True(t, strings.HasPrefix(string(content), "-Y sign -n git -f /home/me/.ssh/id_ed25519 "))
+	})
+
+	test.RunOnRepo(t, "Failure", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		signer := &Signer{Format: SigningFormatOpenPGP, Key: "ABCD"}
+		signer.Program = writeSigningProgram(repo, "echo 'gpg: no secret key' >&2; exit 2")
+
+		head, _ := repo.Head()
+		parent, _ := repo.LookupCommit(head.Target())
+		tree, _ := parent.Tree()
+		signature, _ := repo.DefaultSignature()
+
+		_, err := CreateCommit(repo, signer, head.Name(), signature, signature, "Signed\n", tree, parent)
+		assert.Equal(t, "Failed to sign the commit (gpg: no secret key).", err.Error())
+
+		head2, _ := repo.Head()
+		assert.True(t, head2.Target().Equal(parent.Id()))
+	})
+}
+
+func TestSigners(t *testing.T) {
+	test.RunOnRepo(t, "Config", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		config, _ := repo.Config()
+		assert.Nil(t, CommitSigner(repo))
+
+		config.SetBool("commit.gpgsign", true)
+		assert.Equal(t, &Signer{Format: SigningFormatOpenPGP, Program: "gpg"}, CommitSigner(repo))
+
+		config.SetString("gpg.format", SigningFormatSSH)
+		config.SetString("user.signingkey", "~/.ssh/id_rsa.pub")
+		assert.Equal(t, &Signer{Format: SigningFormatSSH, Key: "~/.ssh/id_rsa.pub", Program: "ssh-keygen"}, CommitSigner(repo))
+
+		config.SetString("gpg.ssh.program", "/usr/local/bin/ssh-keygen")
+		assert.Equal(t, "/usr/local/bin/ssh-keygen", CommitSigner(repo).Program)
+	})
+
+	test.RunOnRepo(t, "KeepSignatures", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		signer := &Signer{Format: SigningFormatOpenPGP}
+		signer.Program = writeSigningProgram(repo, `cat > /dev/null; echo "[GNUPG:] SIG_CREATED " >&2; echo signature`)
+
+		head, _ := repo.Head()
+		parent, _ := repo.LookupCommit(head.Target())
+		tree, _ := parent.Tree()
+		signature, _ := repo.DefaultSignature()
+		oid, _ := CreateCommit(repo, signer, "", signature, signature, "Signed\n", tree, parent)
+		signed, _ := repo.LookupCommit(oid)
+
+		assert.Nil(t, ReplaySigner(repo, signed))
+
+		config, _ := repo.Config()
+		config.SetBool(KeepSignaturesConfigKey, true)
+		assert.NotNil(t, ReplaySigner(repo, signed))
+		assert.Nil(t, ReplaySigner(repo, parent))
+	})
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+)
+
+// Position of a tip relative to its base and to its remote tip
+type TipStatus struct {
+	Tip  string
+	Base string
+	// Missing refs leave the oids empty
+	TailOid string
+	TipOid  string
+	BaseOid string
+	// The tail matches the base, no update is needed
+	UpToDate bool
+	Ahead    int
+	Behind   int
+	// Remote tip, empty if the tip is not based on a remote
+	RemoteTip    string
+	Pushed       bool
+	RemoteAhead  int
+	RemoteBehind int
+}
+
+func GetTipStatus(repo *git.Repository, tipName string) (*TipStatus, error) {
+	tip, err := repo.References.Lookup(RefsTips + tipName)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TipStatus{
+		Tip:    tipName,
+		TipOid: tip.Target().String(),
+	}
+
+	config, _ := repo.Config()
+	status.Base, _ = config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
+
+	tail, err := repo.References.Lookup(RefsTails + tipName)
+	if err == nil {
+		status.TailOid = tail.Target().String()
+	}
+
+	base, err := repo.References.Lookup(status.Base)
+	if err == nil {
+		status.BaseOid = base.Target().String()
+		status.UpToDate = status.TailOid == status.BaseOid
+		status.Ahead, status.Behind, _ = repo.AheadBehind(tip.Target(), base.Target())
+	}
+
+	if remoteName, err := RemoteOf(status.Base, config); err == nil {
+		status.RemoteTip = RefsRemoteTips + remoteName + "/" + tipName
+		rtip, err := repo.References.Lookup(status.RemoteTip)
+		if err == nil {
+			status.Pushed = true
+			status.RemoteAhead, status.RemoteBehind, _ = repo.AheadBehind(tip.Target(), rtip.Target())
+		}
+	}
+
+	return status, nil
+}
+
+// A changed file of the working tree or of the index
+type FileChange struct {
+	Path string `json:"path"`
+	// Short description of the change, like "modified" or "new file"
+	Change string `json:"change"`
+	Staged bool   `json:"staged"`
+}
+
+// Returns the changes of the index then the ones of the working tree, untracked files included
+func WorkdirChanges(repo *git.Repository) ([]FileChange, error) {
+	statusList, err := repo.StatusList(&git.StatusOptions{
+		Show:  git.StatusShowIndexAndWorkdir,
+		Flags: git.StatusOptIncludeUntracked | git.StatusOptRenamesHeadToIndex,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer statusList.Free()
+
+	staged := []FileChange{}
+	unstaged := []FileChange{}
+
+	count, _ := statusList.EntryCount()
+	for i := 0; i < count; i++ {
+		entry, _ := statusList.ByIndex(i)
+		s := entry.Status
+
+		switch {
+		case s&git.StatusConflicted != 0:
+			path := entry.IndexToWorkdir.OldFile.Path
+			if len(path) == 0 {
+				path = entry.HeadToIndex.OldFile.Path
+			}
+			unstaged = append(unstaged, FileChange{Path: path, Change: "conflict"})
+			continue
+		case s&git.StatusIndexNew != 0:
+			staged = append(staged, FileChange{Path: entry.HeadToIndex.NewFile.Path, Change: "new file", Staged: true})
+		case s&git.StatusIndexModified != 0:
+			staged = append(staged, FileChange{Path: entry.HeadToIndex.NewFile.Path, Change: "modified", Staged: true})
+		case s&git.StatusIndexDeleted != 0:
+			staged = append(staged, FileChange{Path: entry.HeadToIndex.OldFile.Path, Change: "deleted", Staged: true})
+		case s&git.StatusIndexRenamed != 0:
+			staged = append(staged, FileChange{Path: entry.HeadToIndex.OldFile.Path + " -> " + entry.HeadToIndex.NewFile.Path, Change: "renamed", Staged: true})
+		case s&git.StatusIndexTypeChange != 0:
+			staged = append(staged, FileChange{Path: entry.HeadToIndex.NewFile.Path, Change: "typechange", Staged: true})
+		}
+
+		switch {
+		case s&git.StatusWtNew != 0:
+			unstaged = append(unstaged, FileChange{Path: entry.IndexToWorkdir.NewFile.Path, Change: "untracked"})
+		case s&git.StatusWtModified != 0:
+			unstaged = append(unstaged, FileChange{Path: entry.IndexToWorkdir.NewFile.Path, Change: "modified"})
+		case s&git.StatusWtDeleted != 0:
+			unstaged = append(unstaged, FileChange{Path: entry.IndexToWorkdir.OldFile.Path, Change: "deleted"})
+		case s&git.StatusWtTypeChange != 0:
+			unstaged = append(unstaged, FileChange{Path: entry.IndexToWorkdir.NewFile.Path, Change: "typechange"})
+		}
+	}
+
+	return append(staged, unstaged...), nil
+}
+
+// Operations that stopped and wait to be continued or aborted
+const (
+	OperationRewrite    = "rewrite"
+	OperationUpdate     = "update"
+	OperationRebase     = "rebase"
+	OperationMerge      = "merge"
+	OperationCherryPick = "cherry-pick"
+	OperationRevert     = "revert"
+	OperationUnknown    = "unknown"
+)
+
+// Returns the tie and git operations in progress
+func OperationsInProgress(repo *git.Repository) []string {
+	operations := []string{}
+
+	if RewriteInProgress(repo) {
+		operations = append(operations, OperationRewrite)
+	}
+
+	switch repo.State() {
+	case git.RepositoryStateNone:
+	case git.RepositoryStateRebaseMerge:
+		// tie update runs libgit2 rebases
+		operations = append(operations, OperationUpdate)
+	case git.RepositoryStateRebase, git.RepositoryStateRebaseInteractive, git.RepositoryStateApplyMailboxOrRebase:
+		operations = append(operations, OperationRebase)
+	case git.RepositoryStateMerge:
+		operations = append(operations, OperationMerge)
+	case git.RepositoryStateCherrypick:
+		operations = append(operations, OperationCherryPick)
+	case git.RepositoryStateRevert:
+		operations = append(operations, OperationRevert)
+	default:
+		operations = append(operations, OperationUnknown)
+	}
+
+	return operations
+}
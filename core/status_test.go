@@ -0,0 +1,58 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestGetTipStatus(t *testing.T) {
+	test.RunOnRemote(t, "AheadBehind", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		test.CreateTip(repo, "test", "refs/remotes/origin/master", false)
+		test.CommitFiles(repo, RefsTips+"test", map[string]string{"foo": "foo"})
+		PushTip(repo, "test", context.Context)
+		test.CommitFiles(repo, RefsTips+"test", map[string]string{"bar": "bar"})
+		test.CommitFiles(repo, "refs/remotes/origin/master", map[string]string{"baz": "baz"})
+
+		status, err := GetTipStatus(repo, "test")
+		assert.Nil(t, err)
+		assert.Equal(t, "refs/remotes/origin/master", status.Base)
+		assert.False(t, status.UpToDate)
+		assert.Equal(t, 2, status.Ahead)
+		assert.Equal(t, 1, status.Behind)
+		assert.Equal(t, RefsRemoteTips+"origin/test", status.RemoteTip)
+		assert.True(t, status.Pushed)
+		assert.Equal(t, 1, status.RemoteAhead)
+		assert.Equal(t, 0, status.RemoteBehind)
+	})
+
+	test.RunOnRepo(t, "LocalBase", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+
+		status, err := GetTipStatus(repo, "test")
+		assert.Nil(t, err)
+		assert.True(t, status.UpToDate)
+		assert.Equal(t, "", status.RemoteTip)
+		assert.False(t, status.Pushed)
+	})
+}
+
+func TestWorkdirChanges(t *testing.T) {
+	test.RunOnRepo(t, "StagedFirst", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteFile(repo, true, "tracked", "foo")
+		test.Commit(repo, nil)
+
+		test.WriteFile(repo, false, "tracked", "bar")
+		test.WriteFile(repo, false, "untracked", "foo")
+		test.WriteFile(repo, true, "added", "foo")
+
+		changes, err := WorkdirChanges(repo)
+		assert.Nil(t, err)
+		assert.Equal(t, []FileChange{
+			{Path: "added", Change: "new file", Staged: true},
+			{Path: "tracked", Change: "modified"},
+			{Path: "untracked", Change: "untracked"},
+		}, changes)
+	})
+}
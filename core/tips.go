@@ -24,7 +24,8 @@ func PushTip(repo *git.Repository, tipName string, context model.Context) error
 	config, _ := repo.Config()
 	base, _ := config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
 
-	remoteName, _, notRemote := ExplodeRemoteRef(base)
+	// Tips stacked on other tips are pushed with them
+	remoteName, notRemote := RemoteOf(base, config)
 	if notRemote != nil {
 		return notRemote
 	}
@@ -46,11 +47,23 @@ func PushTip(repo *git.Repository, tipName string, context model.Context) error
 		refspecs = append(refspecs, fmt.Sprintf("+%v:%v%v", RefsTips+tipName, compat, tipName))
 	}
 
+	// The description travels with the tip
+	if _, noMeta := repo.References.Lookup(RefsTipMeta + tipName); noMeta == nil {
+		refspecs = append(refspecs, fmt.Sprintf("+%v:%v", RefsTipMeta+tipName, RefsTipMeta+tipName))
+	}
+
+	if !context.NoVerify {
+		if err := RunPrePushHook(repo, remote, refspecs); err != nil {
+			return err
+		}
+	}
+
 	pushOptions := &git.PushOptions{
 		RemoteCallbacks: context.RemoteCallbacks,
 	}
 
 	pushErr := remote.Push(refspecs, pushOptions)
+	ApproveCredentials(context, pushErr)
 
 	if pushErr != nil {
 		return pushErr
@@ -61,6 +74,14 @@ func PushTip(repo *git.Repository, tipName string, context model.Context) error
 	return nil
 }
 
+// Lets the credential store keep the credentials of a successful remote operation,
+// and start over for the next one
+func ApproveCredentials(context model.Context, err error) {
+	if context.ApproveCredentials != nil {
+		context.ApproveCredentials(err == nil)
+	}
+}
+
 // Removes comments (#) and empty lines before/after the content
 func FormatCommitMessage(s string) string {
 	if s == "" {
@@ -78,48 +99,132 @@ func FormatCommitMessage(s string) string {
 	return buffer.String()
 }
 
-func DeleteTip(repo *git.Repository, tipName string, context model.Context) {
-	// Delete the tip locally
-	tip, _ := repo.References.Lookup(RefsTips + tipName)
-	tip.Delete()
+// Creates the tip and its tail on target and selects it
+func CreateTip(repo *git.Repository, tipName string, target *git.Oid, base, message string) error {
+	tx := NewTransaction(repo, message)
+	tx.CreateRef(RefsTips+tipName, target, false)
+	// A tail left by a deleted tip is outdated
+	tx.CreateRef(RefsTails+tipName, target, true)
+	tx.SetConfig(fmt.Sprintf("tip.%v.base", tipName), base)
+	tx.CreateSymbolicRef("HEAD", RefsTips+tipName)
+	return tx.Commit()
+}
+
+func DeleteTip(repo *git.Repository, tipName string, context model.Context) error {
+	return deleteTip(repo, tipName, "", context)
+}
+
+// Deletes the tip selected by HEAD. HEAD is moved to newHead in the same transaction,
+// the working tree must already be on newHead.
+func DeleteSelectedTip(repo *git.Repository, tipName, newHead string, context model.Context) error {
+	return deleteTip(repo, tipName, newHead, context)
+}
 
-	tail, _ := repo.References.Lookup(RefsTails + tipName)
-	tail.Delete()
+func deleteTip(repo *git.Repository, tipName, newHead string, context model.Context) error {
+	// The worktree of the tip goes away with it
+	if err := RemoveWorktree(repo, tipName); err != nil {
+		return err
+	}
 
 	config, _ := repo.Config()
 	baseKey := fmt.Sprintf("tip.%v.base", tipName)
 	base, _ := config.LookupString(baseKey)
-	config.Delete(baseKey)
 
-	// Delete the tip on the remote
-	var pushErr error
-	remoteName, _, err := ExplodeRemoteRef(base)
-	if err == nil {
-		remote, _ := repo.Remotes.Lookup(remoteName)
-		pushOptions := &git.PushOptions{
-			RemoteCallbacks: context.RemoteCallbacks,
-		}
-		refspecs := []string{":" + tip.Name()}
+	// Delete the tip locally
+	tx := NewTransaction(repo, "tie delete")
+	if newHead != "" {
+		tx.CreateSymbolicRef("HEAD", newHead)
+	}
+	tx.DeleteRef(RefsTips + tipName)
+	tx.DeleteRef(RefsTails + tipName)
+	tx.DeleteConfig(baseKey)
 
-		compatRef, noCompatErr := config.LookupString(PushTipsAsConfigKey)
-		if noCompatErr == nil {
-			refspecs = append(refspecs, ":"+compatRef+tipName)
-		}
+	_, noMeta := repo.References.Lookup(RefsTipMeta + tipName)
+	if noMeta == nil {
+		tx.DeleteRef(RefsTipMeta + tipName)
+	}
 
-		pushErr = remote.Push(refspecs, pushOptions)
+	// The work in progress saved for the tip can't be restored anymore
+	if _, noWip := repo.References.Lookup(RefsWip + tipName); noWip == nil {
+		tx.DeleteRef(RefsWip + tipName)
+	}
 
-		if pushErr == nil {
-			rtip, noRtip := repo.References.Lookup(RefsRemoteTips + remoteName + "/" + tipName)
-			if noRtip == nil {
-				rtip.Delete()
-			}
-		}
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 
+	// Delete the tip on the remote
+	remoteName, pushErr := DeleteRemoteTip(repo, tipName, base, noMeta == nil, context)
+
 	if pushErr != nil {
 		context.Logger.Println(pushErr.Error())
 		context.Logger.Printf("Tip '%v' has been deleted locally but not on %v.\n", tipName, remoteName)
 	} else {
 		context.Logger.Printf("Deleted tip '%v'", tipName)
 	}
+
+	return nil
+}
+
+// Deletes the tip on the remote of base, if base has one, with its description if it had one
+func DeleteRemoteTip(repo *git.Repository, tipName, base string, description bool, context model.Context) (remoteName string, err error) {
+	config, _ := repo.Config()
+	refspecs := []string{":" + RefsTips + tipName}
+
+	compatRef, noCompatErr := config.LookupString(PushTipsAsConfigKey)
+	if noCompatErr == nil {
+		refspecs = append(refspecs, ":"+compatRef+tipName)
+	}
+
+	if description {
+		refspecs = append(refspecs, ":"+RefsTipMeta+tipName)
+	}
+
+	remoteName, err = deleteRemoteRefs(repo, base, refspecs, context)
+
+	if err == nil {
+		rtip, noRtip := repo.References.Lookup(RefsRemoteTips + remoteName + "/" + tipName)
+		if noRtip == nil {
+			rtip.Delete()
+		}
+	}
+
+	return remoteName, err
+}
+
+// Deletes the description of the tip on its remote
+func DeleteRemoteDescription(repo *git.Repository, tipName string, context model.Context) error {
+	config, _ := repo.Config()
+	base, _ := config.LookupString(fmt.Sprintf("tip.%v.base", tipName))
+	_, err := deleteRemoteRefs(repo, base, []string{":" + RefsTipMeta + tipName}, context)
+	return err
+}
+
+// Pushes the deletion refspecs on the remote of base, if base has one
+func deleteRemoteRefs(repo *git.Repository, base string, refspecs []string, context model.Context) (remoteName string, err error) {
+	config, _ := repo.Config()
+	remoteName, notRemote := RemoteOf(base, config)
+	if notRemote != nil {
+		return "", nil
+	}
+
+	remote, err := repo.Remotes.Lookup(remoteName)
+	if err != nil {
+		return remoteName, err
+	}
+
+	if !context.NoVerify {
+		if err := RunPrePushHook(repo, remote, refspecs); err != nil {
+			return remoteName, err
+		}
+	}
+
+	pushOptions := &git.PushOptions{
+		RemoteCallbacks: context.RemoteCallbacks,
+	}
+
+	err = remote.Push(refspecs, pushOptions)
+	ApproveCredentials(context, err)
+
+	return remoteName, err
 }
@@ -99,6 +99,31 @@ func TestPushTip(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 
+	test.RunOnRemote(t, "StackedTip", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
+		// setup a tip based on another tip based on origin/master
+		test.CreateTip(repo, "base", "refs/remotes/origin/master", false)
+		test.CreateTip(repo, "test", RefsTips+"base", false)
+
+		oid, _ := test.Commit(repo, &test.CommitParams{
+			Refname: RefsTips + "test",
+		})
+
+		// push the tip
+		err := PushTip(repo, "test", context.Context)
+		assert.Nil(t, err)
+
+		// the tip should be pushed on the remote of its base
+		rtip, err := repo.References.Lookup(RefsRemoteTips + "origin/test")
+		if assert.Nil(t, err) {
+			assert.True(t, rtip.Target().Equal(oid))
+		}
+
+		originTip, err := remote.References.Lookup(RefsTips + "test")
+		if assert.Nil(t, err) {
+			assert.True(t, originTip.Target().Equal(oid))
+		}
+	})
+
 	test.RunOnRemote(t, "BranchCompatibilityMode", func(t *testing.T, context test.TestContext, repo, remote *git.Repository) {
 		// configure the repo to branch compatibility mode
 		config, _ := repo.Config()
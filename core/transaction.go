@@ -0,0 +1,175 @@
+package core
+
+import (
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+)
+
+// Error of a transaction step. The steps applied before it have been rolled back,
+// unless RollbackErr is set.
+type TransactionError struct {
+	// Description of the step that failed, like "create refs/tips/foo"
+	Step        string
+	Err         error
+	RollbackErr error
+}
+
+func (err *TransactionError) Error() string {
+	message := fmt.Sprintf("Failed to %v: %v", err.Step, err.Err.Error())
+	if err.RollbackErr != nil {
+		return fmt.Sprintf("%v Rolling back failed too: %v", message, err.RollbackErr.Error())
+	}
+	return message
+}
+
+type transactionStep struct {
+	description string
+	// Applies the step and returns how to revert it
+	apply func() (revert func() error, err error)
+}
+
+// Ref and config changes applied all together or not at all
+type Transaction struct {
+	repo    *git.Repository
+	message string
+	steps   []transactionStep
+}
+
+func NewTransaction(repo *git.Repository, message string) *Transaction {
+	return &Transaction{repo: repo, message: message}
+}
+
+func (tx *Transaction) add(description string, apply func() (func() error, error)) {
+	tx.steps = append(tx.steps, transactionStep{description, apply})
+}
+
+// Creates or moves the ref. Fails if the ref exists and force is false.
+func (tx *Transaction) CreateRef(name string, target *git.Oid, force bool) {
+	tx.add("create "+name, func() (func() error, error) {
+		revert := tx.refReverter(name)
+		_, err := tx.repo.References.Create(name, target, force, tx.message)
+		return revert, err
+	})
+}
+
+func (tx *Transaction) CreateSymbolicRef(name, target string) {
+	tx.add("create "+name, func() (func() error, error) {
+		revert := tx.refReverter(name)
+		_, err := tx.repo.References.CreateSymbolic(name, target, true, tx.message)
+		return revert, err
+	})
+}
+
+// Deletes the ref if it exists
+func (tx *Transaction) DeleteRef(name string) {
+	tx.add("delete "+name, func() (func() error, error) {
+		ref, err := tx.repo.References.Lookup(name)
+		if err != nil {
+			return nil, nil
+		}
+		revert := tx.refReverter(name)
+		return revert, ref.Delete()
+	})
+}
+
+func (tx *Transaction) SetConfig(key, value string) {
+	tx.add("set "+key, func() (func() error, error) {
+		config, err := tx.repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		revert := configReverter(config, key)
+		return revert, config.SetString(key, value)
+	})
+}
+
+// Deletes the config entry if it exists
+func (tx *Transaction) DeleteConfig(key string) {
+	tx.add("unset "+key, func() (func() error, error) {
+		config, err := tx.repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := config.LookupString(key); err != nil {
+			return nil, nil
+		}
+		revert := configReverter(config, key)
+		return revert, config.Delete(key)
+	})
+}
+
+// Returns a function that puts the ref back in its current state
+func (tx *Transaction) refReverter(name string) func() error {
+	ref, err := tx.repo.References.Lookup(name)
+
+	if err != nil {
+		return func() error {
+			ref, err := tx.repo.References.Lookup(name)
+			if err != nil {
+				return nil
+			}
+			return ref.Delete()
+		}
+	}
+
+	if ref.Type() == git.ReferenceSymbolic {
+		target := ref.SymbolicTarget()
+		return func() error {
+			_, err := tx.repo.References.CreateSymbolic(name, target, true, tx.message+" (rollback)")
+			return err
+		}
+	}
+
+	target := ref.Target()
+	return func() error {
+		_, err := tx.repo.References.Create(name, target, true, tx.message+" (rollback)")
+		return err
+	}
+}
+
+func configReverter(config *git.Config, key string) func() error {
+	value, err := config.LookupString(key)
+
+	if err != nil {
+		return func() error {
+			if _, err := config.LookupString(key); err != nil {
+				return nil
+			}
+			return config.Delete(key)
+		}
+	}
+
+	return func() error {
+		return config.SetString(key, value)
+	}
+}
+
+// Applies the steps in order. If one fails, the previous ones are reverted
+// and a *TransactionError is returned.
+func (tx *Transaction) Commit() error {
+	reverts := []func() error{}
+
+	for _, step := range tx.steps {
+		revert, err := step.apply()
+
+		if err != nil {
+			txErr := &TransactionError{Step: step.description, Err: err}
+			// Reverting a step that failed is harmless and cleans up partial changes
+			if revert != nil {
+				reverts = append(reverts, revert)
+			}
+			for i := len(reverts) - 1; i >= 0; i-- {
+				if rollbackErr := reverts[i](); rollbackErr != nil && txErr.RollbackErr == nil {
+					txErr.RollbackErr = rollbackErr
+				}
+			}
+			return txErr
+		}
+
+		if revert != nil {
+			reverts = append(reverts, revert)
+		}
+	}
+
+	return nil
+}
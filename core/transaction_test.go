@@ -0,0 +1,107 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	test.RunOnRepo(t, "Commit", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		config, _ := repo.Config()
+		config.SetString("tie.test", "before")
+
+		tx := NewTransaction(repo, "test")
+		tx.CreateRef("refs/heads/test", head.Target(), false)
+		tx.SetConfig("tip.test.base", "refs/heads/master")
+		tx.DeleteConfig("tie.test")
+		tx.DeleteConfig("tie.missing")
+		tx.DeleteRef("refs/heads/missing")
+		assert.Nil(t, tx.Commit())
+
+		_, err := repo.References.Lookup("refs/heads/test")
+		assert.Nil(t, err)
+		base, _ := config.LookupString("tip.test.base")
+		assert.Equal(t, "refs/heads/master", base)
+		_, err = config.LookupString("tie.test")
+		assert.NotNil(t, err)
+	})
+
+	test.RunOnRepo(t, "Rollback", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		oid := test.CommitFiles(repo, "refs/heads/master", map[string]string{"foo": "bar"})
+		repo.References.Create("refs/heads/existing", head.Target(), false, "")
+		config, _ := repo.Config()
+		config.SetString("tip.test.base", "before")
+
+		tx := NewTransaction(repo, "test")
+		tx.CreateRef("refs/heads/test", oid, false)
+		tx.CreateRef("refs/heads/master", head.Target(), true)
+		tx.SetConfig("tip.test.base", "after")
+		tx.DeleteRef("refs/heads/existing")
+		tx.CreateRef("refs/heads/existing", oid, false)
+		tx.CreateRef("refs/heads/existing", oid, false)
+		err := tx.Commit()
+
+		if assert.NotNil(t, err) {
+			txErr, typed := err.(*TransactionError)
+			if assert.True(t, typed) {
+				assert.Equal(t, "create refs/heads/existing", txErr.Step)
+				assert.Nil(t, txErr.RollbackErr)
+				assert.Equal(t, "Failed to create refs/heads/existing: "+txErr.Err.Error(), err.Error())
+			}
+		}
+
+		// Everything is back as it was
+		_, err = repo.References.Lookup("refs/heads/test")
+		assert.NotNil(t, err)
+		master, _ := repo.References.Lookup("refs/heads/master")
+		assert.True(t, master.Target().Equal(oid))
+		existing, _ := repo.References.Lookup("refs/heads/existing")
+		assert.True(t, existing.Target().Equal(head.Target()))
+		base, _ := config.LookupString("tip.test.base")
+		assert.Equal(t, "before", base)
+	})
+}
+
+func TestCreateTip(t *testing.T) {
+	test.RunOnRepo(t, "Success", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+
+		err := CreateTip(repo, "test", head.Target(), "refs/heads/master", "test")
+		assert.Nil(t, err)
+
+		head, _ = repo.Head()
+		assert.Equal(t, RefsTips+"test", head.Name())
+		tail, _ := repo.References.Lookup(RefsTails + "test")
+		assert.True(t, tail.Target().Equal(head.Target()))
+		config, _ := repo.Config()
+		base, _ := config.LookupString("tip.test.base")
+		assert.Equal(t, "refs/heads/master", base)
+	})
+
+	test.RunOnRepo(t, "NothingHalfCreated", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		// The tail can't be created because refs/tails/test/sub is taken
+		repo.References.Create(RefsTails+"test/sub", head.Target(), false, "")
+
+		err := CreateTip(repo, "test", head.Target(), "refs/heads/master", "test")
+		if assert.NotNil(t, err) {
+			txErr, typed := err.(*TransactionError)
+			if assert.True(t, typed) {
+				assert.Equal(t, "create "+RefsTails+"test", txErr.Step)
+			}
+		}
+
+		// The tip created before has been removed
+		_, err = repo.References.Lookup(RefsTips + "test")
+		assert.NotNil(t, err)
+		config, _ := repo.Config()
+		_, err = config.LookupString("tip.test.base")
+		assert.NotNil(t, err)
+		head, _ = repo.Head()
+		assert.Equal(t, "refs/heads/master", head.Name())
+	})
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"sort"
+	"strings"
+)
+
+// Uncommitted changes of a tip, saved when another tip is selected. refs/tie/wip/<tip> points
+// to a stash commit, kept out of the stash list of the user.
+const RefsWip = "refs/tie/wip/"
+
+type Wip struct {
+	Tip string
+	// The stash commit. Its first parent is the commit the changes were made on.
+	Commit *git.Commit
+	// Number of files changed, untracked files included
+	Files int
+}
+
+// Stashes the uncommitted changes of the tip, untracked files included, and cleans the
+// working tree. Returns false if there was nothing to save.
+func SaveWip(repo *git.Repository, tipName string) (bool, error) {
+	refname := RefsWip + tipName
+	stasher, _ := repo.DefaultSignature()
+
+	oid, err := repo.Stashes.Save(stasher, "WIP on "+tipName, git.StashIncludeUntracked)
+	if git.IsErrorCode(err, git.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := repo.References.Create(refname, oid, false, "save wip of "+tipName); err != nil {
+		// Put the changes back rather than overwrite the previous ones
+		options, _ := git.DefaultStashApplyOptions()
+		options.Flags = git.StashApplyReinstateIndex
+		repo.Stashes.Pop(0, options)
+		return false, fmt.Errorf("Tip '%v' already has work in progress saved in %v.", tipName, refname)
+	}
+
+	return true, repo.Stashes.Drop(0)
+}
+
+// Applies the work in progress saved for the tip and deletes it. Returns false if the tip had none.
+// The work in progress is kept if it can't be applied.
+func RestoreWip(repo *git.Repository, tipName string) (bool, error) {
+	refname := RefsWip + tipName
+	wip, err := repo.References.Lookup(refname)
+	if err != nil {
+		return false, nil
+	}
+
+	// libgit2 only applies the entries of the stash list, so the stash goes through it
+	repo.References.EnsureLog("refs/stash")
+	if _, err := repo.References.Create("refs/stash", wip.Target(), true, "restore wip of "+tipName); err != nil {
+		return false, err
+	}
+
+	options, _ := git.DefaultStashApplyOptions()
+	options.Flags = git.StashApplyReinstateIndex
+
+	if err := repo.Stashes.Pop(0, options); err != nil {
+		repo.Stashes.Drop(0)
+		return false, fmt.Errorf("Cannot restore the work in progress of tip '%v' (%v). It's kept in %v.", tipName, err, refname)
+	}
+
+	return true, wip.Delete()
+}
+
+// Returns the saved works in progress, sorted by tip
+func ListWip(repo *git.Repository) ([]Wip, error) {
+	it, err := repo.NewReferenceIteratorGlob(RefsWip + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	commits := map[string]*git.Commit{}
+	tips := []string{}
+	for ref, end := it.Next(); end == nil; ref, end = it.Next() {
+		commit, err := repo.LookupCommit(ref.Target())
+		if err != nil {
+			continue
+		}
+
+		tip := strings.TrimPrefix(ref.Name(), RefsWip)
+		commits[tip] = commit
+		tips = append(tips, tip)
+	}
+	sort.Strings(tips)
+
+	wips := []Wip{}
+	for _, tip := range tips {
+		wips = append(wips, Wip{Tip: tip, Commit: commits[tip], Files: wipFiles(repo, commits[tip])})
+	}
+
+	return wips, nil
+}
+
+// Counts the files changed by the stash commit: the ones of its tree, and the untracked
+// ones committed in its third parent
+func wipFiles(repo *git.Repository, commit *git.Commit) int {
+	if commit.ParentCount() == 0 {
+		return 0
+	}
+
+	files := 0
+
+	base, _ := commit.Parent(0).Tree()
+	tree, _ := commit.Tree()
+	if diff, err := repo.DiffTreeToTree(base, tree, nil); err == nil {
+		files, _ = diff.NumDeltas()
+	}
+
+	if commit.ParentCount() > 2 {
+		untracked, _ := commit.Parent(2).Tree()
+		untracked.Walk(func(root string, entry *git.TreeEntry) int {
+			if entry.Type == git.ObjectBlob {
+				files++
+			}
+			return 0
+		})
+	}
+
+	return files
+}
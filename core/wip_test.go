@@ -0,0 +1,81 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWip(t *testing.T) {
+	test.RunOnRepo(t, "SaveAndRestore", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteFile(repo, true, "staged", "a")
+		test.WriteFile(repo, true, "modified", "a")
+		test.Commit(repo, nil)
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+
+		test.WriteFile(repo, true, "staged", "b")
+		test.WriteFile(repo, false, "modified", "b")
+		test.WriteFile(repo, false, "untracked", "b")
+
+		saved, err := SaveWip(repo, "test")
+		assert.Nil(t, err)
+		assert.True(t, saved)
+		test.StatusClean(t, repo)
+
+		// The stash list of the user is left untouched
+		_, err = repo.References.Lookup("refs/stash")
+		assert.NotNil(t, err)
+
+		wips, _ := ListWip(repo)
+		if assert.Equal(t, 1, len(wips)) {
+			assert.Equal(t, "test", wips[0].Tip)
+			assert.Equal(t, 3, wips[0].Files)
+		}
+
+		restored, err := RestoreWip(repo, "test")
+		assert.Nil(t, err)
+		assert.True(t, restored)
+
+		statusList, _ := repo.StatusList(&git.StatusOptions{Flags: git.StatusOptIncludeUntracked})
+		count, _ := statusList.EntryCount()
+		assert.Equal(t, 3, count)
+		staged, _ := statusList.ByIndex(0)
+		assert.Equal(t, "modified", staged.IndexToWorkdir.NewFile.Path)
+		staged, _ = statusList.ByIndex(1)
+		assert.Equal(t, git.StatusIndexModified, staged.Status)
+		content, _ := ioutil.ReadFile(filepath.Join(repo.Workdir(), "untracked"))
+		assert.Equal(t, "b", string(content))
+
+		_, err = repo.References.Lookup(RefsWip + "test")
+		assert.NotNil(t, err)
+		_, err = repo.References.Lookup("refs/stash")
+		assert.NotNil(t, err)
+	})
+
+	test.RunOnRepo(t, "NothingToSave", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		saved, err := SaveWip(repo, "test")
+		assert.Nil(t, err)
+		assert.False(t, saved)
+
+		restored, err := RestoreWip(repo, "test")
+		assert.Nil(t, err)
+		assert.False(t, restored)
+	})
+
+	test.RunOnRepo(t, "AlreadySaved", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		test.WriteFile(repo, false, "foo", "a")
+		SaveWip(repo, "test")
+
+		test.WriteFile(repo, false, "bar", "b")
+		saved, err := SaveWip(repo, "test")
+		assert.False(t, saved)
+		assert.Equal(t, "Tip 'test' already has work in progress saved in refs/tie/wip/test.", err.Error())
+
+		// The new changes are left in the working tree
+		content, _ := ioutil.ReadFile(filepath.Join(repo.Workdir(), "bar"))
+		assert.Equal(t, "b", string(content))
+	})
+}
@@ -0,0 +1,353 @@
+package core
+
+import (
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Directory where the worktrees of the tips are created, next to the main worktree by default
+const WorktreesDirConfigKey = "tie.worktreesDir"
+
+// A linked worktree having a tip checked out
+type Worktree struct {
+	Tip string
+	// Root of the working tree
+	Path string
+	// Administrative directory, in the worktrees directory of the common git dir
+	GitDir string
+}
+
+// Returns the git dir shared by all the worktrees of the repository.
+// For the main worktree, it's the git dir itself.
+func CommonDir(repo *git.Repository) string {
+	content, err := ioutil.ReadFile(filepath.Join(repo.Path(), "commondir"))
+	if err != nil {
+		return filepath.Clean(repo.Path())
+	}
+
+	commonDir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(repo.Path(), commonDir)
+	}
+
+	return filepath.Clean(commonDir)
+}
+
+// Returns the linked worktrees having a tip checked out
+func TipWorktrees(repo *git.Repository) []Worktree {
+	worktreesDir := filepath.Join(CommonDir(repo), "worktrees")
+	entries, _ := ioutil.ReadDir(worktreesDir)
+
+	worktrees := []Worktree{}
+	for _, entry := range entries {
+		gitDir := filepath.Join(worktreesDir, entry.Name())
+
+		head, err := ioutil.ReadFile(filepath.Join(gitDir, "HEAD"))
+		if err != nil {
+			continue
+		}
+		tip, notTip := TipName(strings.TrimPrefix(strings.TrimSpace(string(head)), "ref: "))
+		if notTip != nil {
+			continue
+		}
+
+		// gitdir points to the .git file at the root of the working tree
+		dotGit, err := ioutil.ReadFile(filepath.Join(gitDir, "gitdir"))
+		if err != nil {
+			continue
+		}
+
+		worktrees = append(worktrees, Worktree{
+			Tip:    tip,
+			Path:   filepath.Dir(strings.TrimSpace(string(dotGit))),
+			GitDir: gitDir,
+		})
+	}
+
+	return worktrees
+}
+
+// Returns the linked worktree of the tip, nil if it has none
+func TipWorktree(repo *git.Repository, tipName string) *Worktree {
+	for _, worktree := range TipWorktrees(repo) {
+		if worktree.Tip == tipName {
+			return &worktree
+		}
+	}
+
+	return nil
+}
+
+// Tells whether repo has been opened from this worktree
+func (worktree *Worktree) Is(repo *git.Repository) bool {
+	// libgit2 resolves the symlinks of the git dir it opens through .git
+	return realPath(repo.Path()) == realPath(worktree.GitDir)
+}
+
+func realPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// Returns where the worktree of the tip is created by default: <main worktree>-<tip>,
+// or <tie.worktreesDir>/<tip> when it's set
+func DefaultWorktreePath(repo *git.Repository, tipName string) string {
+	name := strings.Replace(tipName, "/", "-", -1)
+
+	config, _ := repo.Config()
+	if dir, err := config.LookupString(WorktreesDirConfigKey); err == nil && dir != "" {
+		return filepath.Join(dir, name)
+	}
+
+	mainWorktree := filepath.Dir(CommonDir(repo))
+	return mainWorktree + "-" + name
+}
+
+// Entries of the common dir that the git dir of a linked worktree links to.
+// libgit2 doesn't read commondir, it needs them to open the worktree. git ignores them.
+var commonDirLinks = []string{"objects", "refs", "packed-refs", "config", "info", filepath.Join("logs", "refs")}
+
+// Links the entries of the common dir missing in the git dir of a linked worktree,
+// including those of the worktrees created by git worktree add
+func linkCommonDir(gitDir string) error {
+	commonDir, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return err
+	}
+	common := strings.TrimSpace(string(commonDir))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+
+	for _, entry := range commonDirLinks {
+		link := filepath.Join(gitDir, entry)
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		}
+
+		target, err := filepath.Rel(filepath.Dir(link), filepath.Join(common, entry))
+		if err != nil {
+			return err
+		}
+		os.MkdirAll(filepath.Dir(link), 0755)
+		// The common dir may not have it yet, like packed-refs or logs/refs
+		if entry != "packed-refs" && entry != "config" {
+			os.MkdirAll(filepath.Join(common, entry), 0755)
+		}
+		if err := os.Symlink(target, link); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Opens the repository of a git dir. The git dir of a linked worktree is opened
+// from its working tree, otherwise libgit2 would take its parent as working tree.
+func OpenRepository(gitDir string) (*git.Repository, error) {
+	if _, err := os.Stat(filepath.Join(gitDir, "commondir")); err != nil {
+		return git.OpenRepository(gitDir)
+	}
+
+	if err := linkCommonDir(gitDir); err != nil {
+		return nil, err
+	}
+
+	dotGit, err := ioutil.ReadFile(filepath.Join(gitDir, "gitdir"))
+	if err != nil {
+		return nil, err
+	}
+
+	return git.OpenRepository(filepath.Dir(strings.TrimSpace(string(dotGit))))
+}
+
+// Opens the repository containing dir, which can be in a linked worktree
+func DiscoverRepository(dir string) (*git.Repository, error) {
+	// libgit2 only finds the git dir of a linked worktree once it links to the common dir
+	for current, _ := filepath.Abs(dir); ; current = filepath.Dir(current) {
+		if content, err := ioutil.ReadFile(filepath.Join(current, ".git")); err == nil {
+			gitDir := strings.TrimSpace(strings.TrimPrefix(string(content), "gitdir:"))
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(current, gitDir)
+			}
+			if _, err := os.Stat(filepath.Join(gitDir, "commondir")); err == nil {
+				linkCommonDir(gitDir)
+			}
+			break
+		}
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil || current == filepath.Dir(current) {
+			break
+		}
+	}
+
+	path, err := git.Discover(dir, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenRepository(path)
+}
+
+// Creates a linked worktree at path with HEAD on the tip, in the same layout as git worktree add.
+// The worktree already created for the tip is reused.
+func AddWorktree(repo *git.Repository, tipName, path string) (*Worktree, error) {
+	tip, err := repo.References.Lookup(RefsTips + tipName)
+	if err != nil {
+		return nil, fmt.Errorf("Tip '%v' doesn't exist.", tipName)
+	}
+
+	if worktree := TipWorktree(repo, tipName); worktree != nil {
+		if _, err := os.Stat(worktree.Path); err == nil {
+			return worktree, nil
+		}
+		// The working tree has been removed by hand
+		os.RemoveAll(worktree.GitDir)
+	}
+
+	// Like a branch, a tip can only be checked out in one worktree
+	if mainWorktreeHead(repo) == RefsTips+tipName {
+		return nil, fmt.Errorf("Tip '%v' is selected in the main worktree.", tipName)
+	}
+
+	path, _ = filepath.Abs(path)
+	if entries, err := ioutil.ReadDir(path); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("'%v' already exists and is not empty.", path)
+	}
+
+	// Named after the working tree, with a number when the name is taken like git does
+	name := filepath.Base(path)
+	gitDir := filepath.Join(CommonDir(repo), "worktrees", name)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(gitDir); err != nil {
+			break
+		}
+		gitDir = filepath.Join(CommonDir(repo), "worktrees", fmt.Sprintf("%v%v", name, i))
+	}
+
+	worktree := &Worktree{Tip: tipName, Path: path, GitDir: gitDir}
+
+	if err := worktree.writeFiles(); err != nil {
+		worktree.remove()
+		return nil, err
+	}
+
+	linked, err := OpenRepository(gitDir)
+	if err != nil {
+		worktree.remove()
+		return nil, err
+	}
+	defer linked.Free()
+
+	// Also writes the index of the worktree
+	commit, _ := repo.LookupCommit(tip.Target())
+	tree, _ := commit.Tree()
+	if err := linked.CheckoutTree(tree, &git.CheckoutOpts{Strategy: git.CheckoutForce}); err != nil {
+		worktree.remove()
+		return nil, err
+	}
+
+	return worktree, nil
+}
+
+func (worktree *Worktree) writeFiles() error {
+	if err := os.MkdirAll(worktree.GitDir, 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(worktree.Path, 0755); err != nil {
+		return err
+	}
+
+	files := map[string]string{
+		filepath.Join(worktree.GitDir, "HEAD"):      "ref: " + RefsTips + worktree.Tip + "\n",
+		filepath.Join(worktree.GitDir, "commondir"): "../..\n",
+		filepath.Join(worktree.GitDir, "gitdir"):    filepath.Join(worktree.Path, ".git") + "\n",
+		filepath.Join(worktree.Path, ".git"):        "gitdir: " + worktree.GitDir + "\n",
+	}
+
+	for file, content := range files {
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return linkCommonDir(worktree.GitDir)
+}
+
+// Removing the links of the git dir doesn't touch the common dir
+func (worktree *Worktree) remove() error {
+	if err := os.RemoveAll(worktree.Path); err != nil {
+		return err
+	}
+	return os.RemoveAll(worktree.GitDir)
+}
+
+// Returns the ref HEAD of the main worktree points to
+func mainWorktreeHead(repo *git.Repository) string {
+	head, _ := ioutil.ReadFile(filepath.Join(CommonDir(repo), "HEAD"))
+	return strings.TrimPrefix(strings.TrimSpace(string(head)), "ref: ")
+}
+
+// Removes the linked worktree of the tip, if it has one.
+// A worktree with uncommitted changes is kept, like git worktree remove does.
+func RemoveWorktree(repo *git.Repository, tipName string) error {
+	worktree := TipWorktree(repo, tipName)
+	if worktree == nil {
+		return nil
+	}
+
+	if worktree.Is(repo) {
+		return fmt.Errorf("Cannot remove the worktree of tip '%v' from itself.", tipName)
+	}
+
+	// Only the git dir is left when the working tree has been removed by hand
+	if _, err := os.Stat(worktree.Path); err == nil {
+		linked, err := OpenRepository(worktree.GitDir)
+		if err != nil {
+			return err
+		}
+		defer linked.Free()
+
+		statusList, err := linked.StatusList(&git.StatusOptions{
+			Show:  git.StatusShowIndexAndWorkdir,
+			Flags: git.StatusOptIncludeUntracked,
+		})
+		if err != nil {
+			return err
+		}
+		if count, _ := statusList.EntryCount(); count > 0 {
+			return fmt.Errorf("Worktree of tip '%v' at %v has uncommitted changes.", tipName, worktree.Path)
+		}
+	}
+
+	return worktree.remove()
+}
+
+// Moves HEAD of the linked worktree of a renamed tip
+func RenameWorktreeTip(repo *git.Repository, oldName, newName string) error {
+	worktree := TipWorktree(repo, oldName)
+	if worktree == nil {
+		return nil
+	}
+
+	return ioutil.WriteFile(filepath.Join(worktree.GitDir, "HEAD"), []byte("ref: "+RefsTips+newName+"\n"), 0644)
+}
+
+// Returns an error if the tip is checked out in another worktree, the main one included.
+// Moving the tip would leave the working tree of the other worktree behind.
+func CheckTipNotInWorktree(repo *git.Repository, tipName string) error {
+	if worktree := TipWorktree(repo, tipName); worktree != nil && !worktree.Is(repo) {
+		return fmt.Errorf("Tip '%v' is checked out in the worktree %v.", tipName, worktree.Path)
+	}
+
+	if CommonDir(repo) != filepath.Clean(repo.Path()) && mainWorktreeHead(repo) == RefsTips+tipName {
+		return fmt.Errorf("Tip '%v' is selected in the main worktree.", tipName)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorktree(t *testing.T) {
+	test.RunOnRepo(t, "Add", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		dir, _ := ioutil.TempDir("", "tie-worktrees-")
+		defer os.RemoveAll(dir)
+
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		test.CommitFiles(repo, RefsTips+"test", map[string]string{"foo": "bar"})
+
+		config, _ := repo.Config()
+		config.SetString(WorktreesDirConfigKey, dir)
+		path := DefaultWorktreePath(repo, "test")
+		assert.Equal(t, filepath.Join(dir, "test"), path)
+
+		worktree, err := AddWorktree(repo, "test", path)
+		assert.Nil(t, err)
+		assert.Equal(t, path, worktree.Path)
+		assert.Equal(t, filepath.Join(CommonDir(repo), "worktrees", "test"), worktree.GitDir)
+
+		// HEAD of the worktree is on the tip and the tip is checked out
+		head, _ := ioutil.ReadFile(filepath.Join(worktree.GitDir, "HEAD"))
+		assert.Equal(t, "ref: refs/tips/test\n", string(head))
+		foo, _ := ioutil.ReadFile(filepath.Join(path, "foo"))
+		assert.Equal(t, "bar", string(foo))
+		os.Mkdir(filepath.Join(path, "sub"), 0755)
+
+		// The worktree shares the repository
+		linked, err := DiscoverRepository(filepath.Join(path, "sub"))
+		if assert.Nil(t, err) {
+			assert.Equal(t, CommonDir(repo), CommonDir(linked))
+			assert.Equal(t, filepath.Clean(path), filepath.Clean(linked.Workdir()))
+			linkedHead, _ := linked.Head()
+			assert.Equal(t, RefsTips+"test", linkedHead.Name())
+			test.StatusClean(t, linked)
+			assert.True(t, worktree.Is(linked))
+
+			// The refs are shared, HEAD isn't
+			test.CommitFiles(linked, RefsTips+"test", map[string]string{"bar": "baz"})
+			tip, _ := repo.References.Lookup(RefsTips + "test")
+			linkedTip, _ := linked.References.Lookup(RefsTips + "test")
+			assert.True(t, tip.Target().Equal(linkedTip.Target()))
+			mainHead, _ := repo.Head()
+			assert.Equal(t, "refs/heads/master", mainHead.Name())
+			assert.Nil(t, CheckTipNotInWorktree(linked, "test"))
+			test.CreateTip(repo, "other", "refs/heads/master", true)
+			assert.Equal(t, "Tip 'other' is selected in the main worktree.", CheckTipNotInWorktree(linked, "other").Error())
+		}
+		assert.False(t, worktree.Is(repo))
+		assert.Equal(t, "Tip 'test' is checked out in the worktree "+path+".", CheckTipNotInWorktree(repo, "test").Error())
+
+		// The worktree is reused
+		again, err := AddWorktree(repo, "test", filepath.Join(dir, "elsewhere"))
+		assert.Nil(t, err)
+		assert.Equal(t, path, again.Path)
+
+		// Renaming the tip moves HEAD of the worktree
+		RenameTip(repo, "test", "renamed", context.Context)
+		assert.Nil(t, TipWorktree(repo, "test"))
+		assert.Equal(t, path, TipWorktree(repo, "renamed").Path)
+		head, _ = ioutil.ReadFile(filepath.Join(worktree.GitDir, "HEAD"))
+		assert.Equal(t, "ref: refs/tips/renamed\n", string(head))
+	})
+
+	test.RunOnRepo(t, "SelectedInMainWorktree", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		dir, _ := ioutil.TempDir("", "tie-worktrees-")
+		defer os.RemoveAll(dir)
+		test.CreateTip(repo, "test", "refs/heads/master", true)
+
+		_, err := AddWorktree(repo, "test", filepath.Join(dir, "test"))
+		assert.Equal(t, "Tip 'test' is selected in the main worktree.", err.Error())
+		assert.Empty(t, TipWorktrees(repo))
+	})
+
+	test.RunOnRepo(t, "Remove", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		dir, _ := ioutil.TempDir("", "tie-worktrees-")
+		defer os.RemoveAll(dir)
+		test.CreateTip(repo, "test", "refs/heads/master", false)
+		worktree, _ := AddWorktree(repo, "test", filepath.Join(dir, "test"))
+
+		// Uncommitted changes keep the worktree
+		ioutil.WriteFile(filepath.Join(worktree.Path, "foo"), []byte("bar"), 0644)
+		err := RemoveWorktree(repo, "test")
+		assert.Equal(t, "Worktree of tip 'test' at "+worktree.Path+" has uncommitted changes.", err.Error())
+
+		os.Remove(filepath.Join(worktree.Path, "foo"))
+		err = RemoveWorktree(repo, "test")
+		assert.Nil(t, err)
+
+		_, err = os.Stat(worktree.Path)
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(worktree.GitDir)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
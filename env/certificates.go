@@ -0,0 +1,331 @@
+package env
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Hosts for which certificates and host keys are not verified
+const InsecureHostsConfigKey = "tie.insecureHosts"
+
+// Builds the callback verifying the certificates presented by the remotes of repo.
+// X.509 certificates are checked against the system pool and SSH host keys
+// against ~/.ssh/known_hosts and /etc/ssh/ssh_known_hosts.
+func NewCertificateCheckCallback(repo *git.Repository) git.CertificateCheckCallback {
+	return func(cert *git.Certificate, valid bool, hostname string) git.ErrorCode {
+		if repo != nil {
+			config, _ := repo.Config()
+			if isInsecureHost(config, hostname) {
+				return git.ErrOk
+			}
+		}
+
+		var err error
+
+		switch cert.Kind {
+		case git.CertificateX509:
+			err = checkX509(cert.X509, valid, hostname)
+		case git.CertificateHostkey:
+			// libgit2 doesn't tell the port it connected to, so the host key has to be
+			// known for every port the remotes use on this host
+			for _, port := range sshPorts(repo, hostname) {
+				if err = checkHostkey(cert.Hostkey, hostname, port, knownHostsFiles()); err != nil {
+					break
+				}
+			}
+		default:
+			err = fmt.Errorf("Unsupported certificate presented by host '%v'.", hostname)
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return git.ErrCertificate
+		}
+
+		return git.ErrOk
+	}
+}
+
+func isInsecureHost(config *git.Config, hostname string) bool {
+	it, err := config.NewMultivarIterator(InsecureHostsConfigKey, ".*")
+	if err != nil {
+		return false
+	}
+	defer it.Free()
+
+	for entry, end := it.Next(); end == nil; entry, end = it.Next() {
+		for _, host := range strings.FieldsFunc(entry.Value, isListSeparator) {
+			if matched, _ := path.Match(host, hostname); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isListSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\t'
+}
+
+func checkX509(cert *x509.Certificate, valid bool, hostname string) error {
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := "SHA256 " + hexFingerprint(sum[:])
+
+	if err := cert.VerifyHostname(hostname); err != nil {
+		return fmt.Errorf("Certificate of host '%v' (%v) is not trusted: %v", hostname, fingerprint, err)
+	}
+
+	// libgit2 already verified the chain
+	if valid {
+		return nil
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{DNSName: hostname}); err != nil {
+		return fmt.Errorf("Certificate of host '%v' (%v) is not trusted: %v", hostname, fingerprint, err)
+	}
+
+	return nil
+}
+
+const defaultSshPort = 22
+
+// Returns the ports of the ssh remotes of repo on hostname, the default one if there's none
+func sshPorts(repo *git.Repository, hostname string) []int {
+	ports := []int{}
+	found := map[int]bool{}
+
+	if repo != nil {
+		names, _ := repo.Remotes.List()
+		for _, name := range names {
+			remote, err := repo.Remotes.Lookup(name)
+			if err != nil {
+				continue
+			}
+			for _, remoteUrl := range []string{remote.Url(), remote.PushUrl()} {
+				host, port, ok := sshHost(remoteUrl)
+				if ok && host == hostname && !found[port] {
+					found[port] = true
+					ports = append(ports, port)
+				}
+			}
+		}
+	}
+
+	if len(ports) == 0 {
+		ports = append(ports, defaultSshPort)
+	}
+
+	return ports
+}
+
+// Returns the host and the port of an ssh url, either ssh://[user@]host[:port]/path
+// or the scp-like [user@]host:path
+func sshHost(remoteUrl string) (string, int, bool) {
+	if strings.Contains(remoteUrl, "://") {
+		parsed, err := url.Parse(remoteUrl)
+		if err != nil || (parsed.Scheme != "ssh" && parsed.Scheme != "git+ssh" && parsed.Scheme != "ssh+git") {
+			return "", 0, false
+		}
+		port := defaultSshPort
+		if parsed.Port() != "" {
+			port, err = strconv.Atoi(parsed.Port())
+			if err != nil {
+				return "", 0, false
+			}
+		}
+		return parsed.Hostname(), port, true
+	}
+
+	colon := strings.Index(remoteUrl, ":")
+	if colon < 0 || strings.Contains(remoteUrl[:colon], "/") {
+		return "", 0, false
+	}
+	host := remoteUrl[:colon]
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	return strings.Trim(host, "[]"), defaultSshPort, true
+}
+
+// Returns the name of the host in known_hosts: [host]:port when the port isn't the default one
+func knownHostName(hostname string, port int) string {
+	if port == defaultSshPort {
+		return hostname
+	}
+	return fmt.Sprintf("[%v]:%v", hostname, port)
+}
+
+func knownHostsFiles() []string {
+	return []string{
+		filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"),
+		"/etc/ssh/ssh_known_hosts",
+	}
+}
+
+func checkHostkey(hostkey git.HostkeyCertificate, hostname string, port int, knownHostsFiles []string) error {
+	name := knownHostName(hostname, port)
+
+	var fingerprint string
+	if hostkey.Kind&git.HostkeySHA1 != 0 {
+		fingerprint = "SHA1 " + hexFingerprint(hostkey.HashSHA1[:])
+	} else {
+		fingerprint = "MD5 " + hexFingerprint(hostkey.HashMD5[:])
+	}
+
+	known := false
+
+	for _, file := range knownHostsFiles {
+		for _, entry := range readKnownHosts(file) {
+			if !entry.matches(hostname, port) {
+				continue
+			}
+
+			same := entry.sameKey(hostkey)
+
+			if entry.marker == "@revoked" && same {
+				return fmt.Errorf("Host key of '%v' (%v) has been revoked.", name, fingerprint)
+			}
+
+			if entry.marker == "" {
+				if same {
+					return nil
+				}
+				known = true
+			}
+		}
+	}
+
+	if known {
+		return fmt.Errorf("Host key of '%v' (%v) doesn't match known_hosts. Someone could be eavesdropping on you.", name, fingerprint)
+	}
+
+	return fmt.Errorf("Host '%v' (%v) is not in known_hosts. Connect once with ssh or add it to %v.", name, fingerprint, InsecureHostsConfigKey)
+}
+
+type knownHost struct {
+	marker string
+	hosts  string
+	key    []byte
+}
+
+func readKnownHosts(file string) []knownHost {
+	entries := []knownHost{}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return entries
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		entry := knownHost{}
+		if strings.HasPrefix(fields[0], "@") {
+			entry.marker = fields[0]
+			fields = fields[1:]
+		}
+
+		// hosts, key type and base64 encoded key
+		if len(fields) < 3 {
+			continue
+		}
+
+		entry.hosts = fields[0]
+		entry.key, err = base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// Tells whether the entry is about the host on the given port. Like ssh, a host
+// without port in known_hosts is only valid on the default one.
+func (entry knownHost) matches(hostname string, port int) bool {
+	// Hashed hostname: |1|base64(salt)|base64(hmac-sha1(salt, [host]:port))
+	if strings.HasPrefix(entry.hosts, "|1|") {
+		parts := strings.Split(entry.hosts, "|")
+		if len(parts) != 4 {
+			return false
+		}
+		salt, err := base64.StdEncoding.DecodeString(parts[2])
+		if err != nil {
+			return false
+		}
+		hash, err := base64.StdEncoding.DecodeString(parts[3])
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte(knownHostName(hostname, port)))
+		return hmac.Equal(mac.Sum(nil), hash)
+	}
+
+	matched := false
+
+	for _, pattern := range strings.Split(entry.hosts, ",") {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		// [host]:port
+		patternPort := defaultSshPort
+		if strings.HasPrefix(pattern, "[") {
+			end := strings.Index(pattern, "]:")
+			if end < 0 {
+				continue
+			}
+			var err error
+			if patternPort, err = strconv.Atoi(pattern[end+2:]); err != nil {
+				continue
+			}
+			pattern = pattern[1:end]
+		}
+
+		if ok, _ := path.Match(pattern, hostname); ok && patternPort == port {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+func (entry knownHost) sameKey(hostkey git.HostkeyCertificate) bool {
+	if hostkey.Kind&git.HostkeySHA1 != 0 {
+		return sha1.Sum(entry.key) == hostkey.HashSHA1
+	}
+	return md5.Sum(entry.key) == hostkey.HashMD5
+}
+
+func hexFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
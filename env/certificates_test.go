@@ -0,0 +1,183 @@
+package env
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckHostkey(t *testing.T) {
+	key := []byte("host key")
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+	hostkey := git.HostkeyCertificate{
+		Kind:     git.HostkeySHA1,
+		HashSHA1: sha1.Sum(key),
+	}
+
+	withKnownHosts := func(lines ...string) []string {
+		file, _ := ioutil.TempFile("", "tie-known-hosts-")
+		file.WriteString(strings.Join(lines, "\n"))
+		file.Close()
+		return []string{file.Name()}
+	}
+
+	t.Run("KnownHost", func(t *testing.T) {
+		files := withKnownHosts("github.com,192.30.253.113 ssh-rsa " + encodedKey)
+		defer os.Remove(files[0])
+
+		assert.Nil(t, checkHostkey(hostkey, "github.com", 22, files))
+		assert.Nil(t, checkHostkey(hostkey, "192.30.253.113", 22, files))
+	})
+
+	t.Run("UnknownHost", func(t *testing.T) {
+		files := withKnownHosts("github.com ssh-rsa " + encodedKey)
+		defer os.Remove(files[0])
+
+		err := checkHostkey(hostkey, "example.com", 22, files)
+		if assert.NotNil(t, err) {
+			assert.True(t, strings.HasPrefix(err.Error(), "Host 'example.com' (SHA1 "))
+		}
+	})
+
+	t.Run("ChangedKey", func(t *testing.T) {
+		files := withKnownHosts("github.com ssh-rsa " + base64.StdEncoding.EncodeToString([]byte("other key")))
+		defer os.Remove(files[0])
+
+		err := checkHostkey(hostkey, "github.com", 22, files)
+		if assert.NotNil(t, err) {
+			assert.True(t, strings.HasPrefix(err.Error(), "Host key of 'github.com' (SHA1 "))
+			assert.True(t, strings.HasSuffix(err.Error(), "doesn't match known_hosts. Someone could be eavesdropping on you."))
+		}
+	})
+
+	t.Run("HashedHost", func(t *testing.T) {
+		salt := []byte("salt")
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte("github.com"))
+		hashed := "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		files := withKnownHosts(hashed + " ssh-rsa " + encodedKey)
+		defer os.Remove(files[0])
+
+		assert.Nil(t, checkHostkey(hostkey, "github.com", 22, files))
+		assert.NotNil(t, checkHostkey(hostkey, "gitlab.com", 22, files))
+	})
+
+	t.Run("Patterns", func(t *testing.T) {
+		files := withKnownHosts(
+			"# comment",
+			"*.example.com,!evil.example.com ssh-rsa "+encodedKey,
+			"[git.local]:2222 ssh-rsa "+encodedKey)
+		defer os.Remove(files[0])
+
+		assert.Nil(t, checkHostkey(hostkey, "git.example.com", 22, files))
+		assert.NotNil(t, checkHostkey(hostkey, "evil.example.com", 22, files))
+		assert.Nil(t, checkHostkey(hostkey, "git.local", 2222, files))
+		assert.NotNil(t, checkHostkey(hostkey, "git.local", 22, files))
+	})
+
+	t.Run("Ports", func(t *testing.T) {
+		salt := []byte("salt")
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte("[hashed.local]:2222"))
+		hashed := "|1|" + base64.StdEncoding.EncodeToString(salt) + "|" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		files := withKnownHosts(
+			"[git.local]:2222 ssh-rsa "+encodedKey,
+			"other.local ssh-rsa "+encodedKey,
+			hashed+" ssh-rsa "+encodedKey)
+		defer os.Remove(files[0])
+
+		// A host key is only valid on the port it's listed with, 22 when there's none
+		assert.NotNil(t, checkHostkey(hostkey, "git.local", 2223, files))
+		assert.NotNil(t, checkHostkey(hostkey, "other.local", 2222, files))
+		assert.Nil(t, checkHostkey(hostkey, "hashed.local", 2222, files))
+		assert.NotNil(t, checkHostkey(hostkey, "hashed.local", 22, files))
+
+		err := checkHostkey(hostkey, "git.local", 22, files)
+		if assert.NotNil(t, err) {
+			assert.True(t, strings.HasPrefix(err.Error(), "Host 'git.local' (SHA1 "))
+		}
+		err = checkHostkey(hostkey, "other.local", 2222, files)
+		if assert.NotNil(t, err) {
+			assert.True(t, strings.HasPrefix(err.Error(), "Host '[other.local]:2222' (SHA1 "))
+		}
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		files := withKnownHosts(
+			"@revoked * ssh-rsa "+encodedKey,
+			"github.com ssh-rsa "+encodedKey)
+		defer os.Remove(files[0])
+
+		err := checkHostkey(hostkey, "github.com", 22, files)
+		if assert.NotNil(t, err) {
+			assert.True(t, strings.HasSuffix(err.Error(), "has been revoked."))
+		}
+	})
+}
+
+func TestInsecureHosts(t *testing.T) {
+	test.RunOnRepo(t, "SkipVerification", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		config, _ := repo.Config()
+		config.SetString(InsecureHostsConfigKey, "*.example.com, localhost")
+
+		callback := NewCertificateCheckCallback(repo)
+		cert := &git.Certificate{Kind: git.CertificateHostkey}
+
+		assert.Equal(t, git.ErrOk, callback(cert, false, "git.example.com"))
+		assert.Equal(t, git.ErrOk, callback(cert, false, "localhost"))
+		assert.Equal(t, git.ErrCertificate, callback(cert, false, "unknown.invalid"))
+	})
+}
+
+func TestSshPorts(t *testing.T) {
+	test.RunOnRepo(t, "RemoteUrls", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		repo.Remotes.Create("scp", "git@git.local:repo.git")
+		repo.Remotes.Create("ssh", "ssh://git@git.local:2222/repo.git")
+		repo.Remotes.Create("https", "https://git.local:8443/repo.git")
+
+		assert.Equal(t, []int{22, 2222}, sshPorts(repo, "git.local"))
+		assert.Equal(t, []int{22}, sshPorts(repo, "unknown.local"))
+	})
+}
+
+func TestCheckX509(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"git.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	cert, _ := x509.ParseCertificate(der)
+
+	// The chain has been verified by libgit2
+	assert.Nil(t, checkX509(cert, true, "git.example.com"))
+
+	// The certificate must be for the host, even with a valid chain
+	err := checkX509(cert, true, "evil.example.com")
+	if assert.NotNil(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "Certificate of host 'evil.example.com' (SHA256 "))
+	}
+
+	// A self-signed certificate isn't trusted by the system
+	err = checkX509(cert, false, "git.example.com")
+	if assert.NotNil(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "Certificate of host 'git.example.com' (SHA256 "))
+	}
+}
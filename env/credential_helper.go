@@ -0,0 +1,136 @@
+package env
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gopkg.in/libgit2/git2go.v25"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Attributes exchanged with credential helpers.
+// See https://git-scm.com/docs/git-credential#IOFMT
+type credential map[string]string
+
+func newCredential(url, username string) credential {
+	cred := credential{}
+
+	parsed, err := neturl.Parse(url)
+	if err == nil {
+		cred["protocol"] = parsed.Scheme
+		cred["host"] = parsed.Host
+		if parsed.User != nil && len(username) == 0 {
+			username = parsed.User.Username()
+		}
+	}
+
+	if len(username) > 0 {
+		cred["username"] = username
+	}
+
+	return cred
+}
+
+// Url of the credential, used to prompt the user
+func (cred credential) url(username string) string {
+	return fmt.Sprintf("%v://%v@%v", cred["protocol"], username, cred["host"])
+}
+
+func (cred credential) encode() []byte {
+	buffer := new(bytes.Buffer)
+	for _, key := range []string{"protocol", "host", "path", "username", "password"} {
+		if value, found := cred[key]; found {
+			buffer.WriteString(fmt.Sprintf("%v=%v\n", key, value))
+		}
+	}
+	buffer.WriteString("\n")
+	return buffer.Bytes()
+}
+
+func decodeCredential(output []byte) credential {
+	cred := credential{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) == 2 {
+			cred[parts[0]] = parts[1]
+		}
+	}
+	return cred
+}
+
+// Asks the credential helpers to complete cred, until one of them
+// provides both a username and a password.
+func fillCredential(config *git.Config, cred credential) credential {
+	for _, helper := range credentialHelpers(config) {
+		output, err := runCredentialHelper(helper, "get", cred)
+		if err != nil {
+			continue
+		}
+
+		response := decodeCredential(output)
+		if response["quit"] == "1" || response["quit"] == "true" {
+			break
+		}
+
+		for key, value := range response {
+			cred[key] = value
+		}
+
+		if len(cred["username"]) > 0 && len(cred["password"]) > 0 {
+			break
+		}
+	}
+
+	return cred
+}
+
+func runCredentialHelpers(config *git.Config, action string, cred credential) {
+	for _, helper := range credentialHelpers(config) {
+		runCredentialHelper(helper, action, cred)
+	}
+}
+
+func credentialHelpers(config *git.Config) []string {
+	helpers := []string{}
+
+	it, err := config.NewMultivarIterator("credential.helper", ".*")
+	if err != nil {
+		return helpers
+	}
+	defer it.Free()
+
+	for entry, end := it.Next(); end == nil; entry, end = it.Next() {
+		// An empty helper resets the list
+		if len(entry.Value) == 0 {
+			helpers = []string{}
+		} else {
+			helpers = append(helpers, entry.Value)
+		}
+	}
+
+	return helpers
+}
+
+// Runs the helper the way git does: "!cmd" is a shell command, a path
+// is run as is and anything else is a git-credential-* program.
+func runCredentialHelper(helper, action string, cred credential) ([]byte, error) {
+	var command string
+
+	if strings.HasPrefix(helper, "!") {
+		command = helper[1:]
+	} else if strings.HasPrefix(helper, "/") || strings.HasPrefix(helper, "~") {
+		command = helper
+	} else {
+		command = "git credential-" + helper
+	}
+
+	cmd := exec.Command("sh", "-c", command+" "+action)
+	cmd.Stdin = bytes.NewReader(cred.encode())
+	cmd.Stderr = os.Stderr
+
+	return cmd.Output()
+}
@@ -3,22 +3,51 @@ package env
 import (
 	"fmt"
 	"gopkg.in/libgit2/git2go.v25"
+	"os"
 )
 
-func CredentialCallback(url string, username_from_url string, allowedTypes git.CredType) (git.ErrorCode, *git.Cred) {
+// Number of times a user/password is asked for a given url before giving up
+const maxCredentialAttempts = 3
+
+// Provides credentials to libgit2 and remembers them until the remote
+// operation that used them is approved.
+type CredentialStore struct {
+	repo     *git.Repository
+	attempts map[string]int
+	// Credentials given for an url but not approved yet
+	pending map[string]credential
+}
+
+func NewCredentialStore(repo *git.Repository) *CredentialStore {
+	return &CredentialStore{
+		repo:     repo,
+		attempts: map[string]int{},
+		pending:  map[string]credential{},
+	}
+}
+
+func (store *CredentialStore) Callback(url string, username_from_url string, allowedTypes git.CredType) (git.ErrorCode, *git.Cred) {
 	// inspired by https://github.com/jwaldrip/git-get/blob/master/callbacks.go#L26
 
+	// libgit2 calls back as long as the credentials are refused
+	store.attempts[url]++
+	attempt := store.attempts[url]
+
 	if allowedTypes&git.CredTypeUserpassPlaintext != 0 {
-		fmt.Println("Plain text user/password not implemented yet.")
-		return git.ErrUser, nil
+		if attempt > maxCredentialAttempts {
+			store.reject(url)
+			fmt.Printf("Authentication failed for '%v'\n", url)
+			return git.ErrAuth, nil
+		}
+		return store.userpass(url, username_from_url)
 	}
 	if allowedTypes&git.CredTypeSshKey != 0 {
-		i, cred := git.NewCredSshKeyFromAgent(username_from_url)
-		return git.ErrorCode(i), &cred
+		return store.sshKey(url, username_from_url, attempt)
 	}
 	if allowedTypes&git.CredTypeSshCustom != 0 {
-		fmt.Println("Custom ssh not implemented yet.")
-		return git.ErrUser, nil
+		// git2go v25 has no way to sign the server challenge from Go
+		fmt.Printf("Authentication failed for '%v': custom ssh signatures are not supported. Use an ssh key file or the ssh agent.\n", url)
+		return git.ErrAuth, nil
 	}
 	if allowedTypes&git.CredTypeDefault != 0 {
 		i, cred := git.NewCredDefault()
@@ -29,6 +58,110 @@ func CredentialCallback(url string, username_from_url string, allowedTypes git.C
 	return git.ErrUser, nil
 }
 
-func CertificateCheckCallback(cert *git.Certificate, valid bool, hostname string) git.ErrorCode {
-	return git.ErrOk
+// Ends a remote operation. The credentials it used are stored if it succeeded.
+// Either way, the next operation tries the credentials from the first one again.
+func (store *CredentialStore) Approve(succeeded bool) {
+	if succeeded {
+		config, _ := store.repo.Config()
+		for _, cred := range store.pending {
+			runCredentialHelpers(config, "store", cred)
+		}
+	}
+
+	store.pending = map[string]credential{}
+	store.attempts = map[string]int{}
+}
+
+// Erases the credentials that have been refused for url
+func (store *CredentialStore) reject(url string) {
+	cred, found := store.pending[url]
+	if !found {
+		return
+	}
+
+	config, _ := store.repo.Config()
+	runCredentialHelpers(config, "erase", cred)
+	delete(store.pending, url)
+}
+
+func (store *CredentialStore) userpass(url, usernameFromUrl string) (git.ErrorCode, *git.Cred) {
+	config, _ := store.repo.Config()
+
+	cred := newCredential(url, usernameFromUrl)
+	if len(cred["username"]) == 0 {
+		cred["username"], _ = config.LookupString("credential.username")
+	}
+
+	_, retry := store.pending[url]
+
+	if retry {
+		// Being asked again means that the previous credentials have been refused
+		store.reject(url)
+	} else {
+		cred = fillCredential(config, cred)
+	}
+
+	var err error
+
+	if len(cred["username"]) == 0 {
+		cred["username"], err = prompt(config, fmt.Sprintf("Username for '%v': ", url), false)
+		if err != nil {
+			fmt.Println(err.Error())
+			return git.ErrUser, nil
+		}
+	}
+
+	if retry || len(cred["password"]) == 0 {
+		cred["password"], err = prompt(config, fmt.Sprintf("Password for '%v': ", cred.url(cred["username"])), true)
+		if err != nil {
+			fmt.Println(err.Error())
+			return git.ErrUser, nil
+		}
+	}
+
+	store.pending[url] = cred
+
+	i, userpass := git.NewCredUserpassPlaintext(cred["username"], cred["password"])
+	return git.ErrorCode(i), &userpass
+}
+
+// Each attempt tries the next key: the ssh agent if one is running,
+// then the keys returned by sshKeys.
+func (store *CredentialStore) sshKey(url, username string, attempt int) (git.ErrorCode, *git.Cred) {
+	if len(username) == 0 {
+		username = os.Getenv("USER")
+	}
+
+	index := attempt - 1
+
+	if len(os.Getenv("SSH_AUTH_SOCK")) > 0 {
+		if index == 0 {
+			i, cred := git.NewCredSshKeyFromAgent(username)
+			return git.ErrorCode(i), &cred
+		}
+		index--
+	}
+
+	config, _ := store.repo.Config()
+	keys := sshKeys(config)
+
+	if index >= len(keys) {
+		fmt.Printf("Authentication failed for '%v': no more ssh key to try.\n", url)
+		return git.ErrAuth, nil
+	}
+
+	key := keys[index]
+	passphrase := ""
+
+	if isEncryptedKey(key) {
+		var err error
+		passphrase, err = prompt(config, fmt.Sprintf("Enter passphrase for key '%v': ", key), true)
+		if err != nil {
+			fmt.Println(err.Error())
+			return git.ErrUser, nil
+		}
+	}
+
+	i, cred := git.NewCredSshKey(username, sshPublicKey(config, key), key, passphrase)
+	return git.ErrorCode(i), &cred
 }
@@ -0,0 +1,129 @@
+package env
+
+import (
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testUrl = "https://example.com/repo.git"
+
+// Writes an executable script in the .git directory of repo
+func writeScript(repo *git.Repository, name, content string) string {
+	script := filepath.Join(repo.Path(), name)
+	ioutil.WriteFile(script, []byte("#!/bin/sh\n"+content), 0755)
+	return script
+}
+
+// Configures a credential helper that logs its calls and answers bob/secret
+func stubHelper(repo *git.Repository) (log string) {
+	log = filepath.Join(repo.Path(), "helper.log")
+	helper := writeScript(repo, "helper.sh",
+		`echo "$1" >> `+log+`
+cat >> `+log+`
+if [ "$1" = "get" ]; then
+	echo username=bob
+	echo password=secret
+fi
+`)
+	config, _ := repo.Config()
+	config.SetString("credential.helper", helper)
+	return log
+}
+
+func TestCredentialStore(t *testing.T) {
+	test.RunOnRepo(t, "HelperGetAndStore", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		log := stubHelper(repo)
+		store := NewCredentialStore(repo)
+
+		code, cred := store.Callback(testUrl, "", git.CredTypeUserpassPlaintext)
+		assert.Equal(t, git.ErrOk, code)
+		if assert.NotNil(t, cred) {
+			assert.Equal(t, git.CredTypeUserpassPlaintext, cred.Type())
+		}
+
+		calls, _ := ioutil.ReadFile(log)
+		assert.Equal(t, "get\nprotocol=https\nhost=example.com\n\n", string(calls))
+
+		// The credentials are stored once the operation succeeded
+		store.Approve(true)
+
+		calls, _ = ioutil.ReadFile(log)
+		assert.Equal(t,
+			"get\nprotocol=https\nhost=example.com\n\n"+
+				"store\nprotocol=https\nhost=example.com\nusername=bob\npassword=secret\n\n",
+			string(calls))
+	})
+
+	test.RunOnRepo(t, "RejectedCredentials", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		log := stubHelper(repo)
+		askpass := writeScript(repo, "askpass.sh", `echo other`)
+		os.Setenv("GIT_ASKPASS", askpass)
+		defer os.Unsetenv("GIT_ASKPASS")
+
+		store := NewCredentialStore(repo)
+		store.Callback(testUrl, "", git.CredTypeUserpassPlaintext)
+
+		// Being called again means that bob/secret has been refused
+		code, cred := store.Callback(testUrl, "", git.CredTypeUserpassPlaintext)
+		assert.Equal(t, git.ErrOk, code)
+		assert.NotNil(t, cred)
+
+		calls, _ := ioutil.ReadFile(log)
+		assert.Equal(t,
+			"get\nprotocol=https\nhost=example.com\n\n"+
+				"erase\nprotocol=https\nhost=example.com\nusername=bob\npassword=secret\n\n",
+			string(calls))
+
+		// The password has been asked through GIT_ASKPASS
+		assert.Equal(t, "other", store.pending[testUrl]["password"])
+
+		// Give up after too many attempts
+		store.Callback(testUrl, "", git.CredTypeUserpassPlaintext)
+		code, cred = store.Callback(testUrl, "", git.CredTypeUserpassPlaintext)
+		assert.Equal(t, git.ErrAuth, code)
+		assert.Nil(t, cred)
+	})
+
+	test.RunOnRepo(t, "FailedOperation", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		log := stubHelper(repo)
+		askpass := writeScript(repo, "askpass.sh", `echo other`)
+		os.Setenv("GIT_ASKPASS", askpass)
+		defer os.Unsetenv("GIT_ASKPASS")
+
+		store := NewCredentialStore(repo)
+		for i := 0; i < maxCredentialAttempts; i++ {
+			store.Callback(testUrl, "", git.CredTypeUserpassPlaintext)
+		}
+		store.Approve(false)
+
+		// The credentials of a failed operation are not stored
+		calls, _ := ioutil.ReadFile(log)
+		assert.NotContains(t, string(calls), "store\n")
+
+		// The next operation starts over
+		code, cred := store.Callback(testUrl, "", git.CredTypeUserpassPlaintext)
+		assert.Equal(t, git.ErrOk, code)
+		assert.NotNil(t, cred)
+		assert.Equal(t, "secret", store.pending[testUrl]["password"])
+	})
+
+	test.RunOnRepo(t, "UsernameFromUrl", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		askpass := writeScript(repo, "askpass.sh", `echo "$1" > `+filepath.Join(repo.Path(), "prompt"))
+		os.Setenv("GIT_ASKPASS", askpass)
+		defer os.Unsetenv("GIT_ASKPASS")
+
+		store := NewCredentialStore(repo)
+		code, _ := store.Callback("https://alice@example.com/repo.git", "", git.CredTypeUserpassPlaintext)
+		assert.Equal(t, git.ErrOk, code)
+
+		// Only the password is prompted
+		prompt, _ := ioutil.ReadFile(filepath.Join(repo.Path(), "prompt"))
+		assert.Equal(t, "Password for 'https://alice@example.com': \n", string(prompt))
+		assert.Equal(t, "alice", store.pending["https://alice@example.com/repo.git"]["username"])
+	})
+}
@@ -0,0 +1,69 @@
+package env
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/libgit2/git2go.v25"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Shared so that the input buffered by a prompt isn't lost for the next one
+var stdin = bufio.NewReader(os.Stdin)
+
+// Prints the question and returns the line typed by the user
+func Prompt(question string) (string, error) {
+	fmt.Print(question)
+	return readAnswer(stdin)
+}
+
+// Asks the user for a value through GIT_ASKPASS, core.askPass, SSH_ASKPASS
+// or the terminal. The answer of secret prompts is not echoed.
+func prompt(config *git.Config, text string, secret bool) (string, error) {
+	askpass := os.Getenv("GIT_ASKPASS")
+
+	if len(askpass) == 0 {
+		askpass, _ = config.LookupString("core.askPass")
+	}
+
+	if len(askpass) == 0 {
+		askpass = os.Getenv("SSH_ASKPASS")
+	}
+
+	if len(askpass) > 0 {
+		output, err := exec.Command(askpass, text).Output()
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(output), "\r\n"), nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", errors.New("Cannot prompt for credentials: no terminal available.")
+	}
+	defer tty.Close()
+
+	tty.WriteString(text)
+
+	if secret {
+		answer, err := terminal.ReadPassword(int(tty.Fd()))
+		tty.WriteString("\n")
+		return string(answer), err
+	}
+
+	return readAnswer(bufio.NewReader(tty))
+}
+
+// Returns the next line of input, without its end of line. A last line without
+// end of line is still an answer.
+func readAnswer(input *bufio.Reader) (string, error) {
+	answer, err := input.ReadString('\n')
+	if err != nil && len(answer) == 0 {
+		return "", err
+	}
+	return strings.TrimRight(answer, "\r\n"), nil
+}
@@ -0,0 +1,190 @@
+package env
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/pem"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	SshKeyConfigKey       = "tie.sshKey"
+	SshPublicKeyConfigKey = "tie.sshPublicKey"
+)
+
+// Same order as ssh's default identities
+var defaultSshKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa", "id_dsa"}
+
+// Returns the private keys to try, in order: tie.sshKey, the identities of
+// core.sshCommand (or GIT_SSH_COMMAND) then the default ~/.ssh/id_* files.
+func sshKeys(config *git.Config) []string {
+	keys := []string{}
+
+	add := func(key string) {
+		key = expandHome(key)
+		if _, err := os.Stat(key); err != nil {
+			return
+		}
+		for _, k := range keys {
+			if k == key {
+				return
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	if key, err := config.LookupString(SshKeyConfigKey); err == nil {
+		add(key)
+	}
+
+	sshCommand := os.Getenv("GIT_SSH_COMMAND")
+	if len(sshCommand) == 0 {
+		sshCommand, _ = config.LookupString("core.sshCommand")
+	}
+
+	for _, key := range sshCommandIdentities(sshCommand) {
+		add(key)
+	}
+
+	for _, key := range defaultSshKeys {
+		add(filepath.Join(os.Getenv("HOME"), ".ssh", key))
+	}
+
+	return keys
+}
+
+// Extracts the identity files given to ssh with -i or -o IdentityFile.
+// Like ssh, the option accepts IdentityFile=path and IdentityFile path.
+func sshCommandIdentities(command string) []string {
+	identities := []string{}
+
+	args := splitCommand(command)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || (arg[:2] != "-i" && arg[:2] != "-o") {
+			continue
+		}
+
+		// -i path and -o option, or -ipath and -ooption
+		value := arg[2:]
+		if len(value) == 0 && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+
+		if arg[1] == 'o' {
+			if len(value) < len("IdentityFile") || !strings.EqualFold(value[:len("IdentityFile")], "IdentityFile") {
+				continue
+			}
+			value = strings.TrimLeft(value[len("IdentityFile"):], " \t=")
+			// -oIdentityFile path
+			if len(value) == 0 && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+		}
+
+		if len(value) > 0 {
+			identities = append(identities, value)
+		}
+	}
+
+	return identities
+}
+
+// Splits a command line on blanks, except when quoted
+func splitCommand(command string) []string {
+	args := []string{}
+	var arg bytes.Buffer
+	inArg := false
+	var quote rune
+
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args
+}
+
+// Returns the public key matching privateKey, or an empty string to let
+// libssh2 derive it from the private key.
+func sshPublicKey(config *git.Config, privateKey string) string {
+	if key, err := config.LookupString(SshKeyConfigKey); err == nil && expandHome(key) == privateKey {
+		if publicKey, err := config.LookupString(SshPublicKeyConfigKey); err == nil {
+			return expandHome(publicKey)
+		}
+	}
+
+	if _, err := os.Stat(privateKey + ".pub"); err == nil {
+		return privateKey + ".pub"
+	}
+
+	return ""
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[2:])
+	}
+	return path
+}
+
+// Tells if the private key file is protected by a passphrase
+func isEncryptedKey(file string) bool {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return false
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return false
+	}
+
+	// Legacy PEM keys
+	if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") || block.Type == "ENCRYPTED PRIVATE KEY" {
+		return true
+	}
+
+	// openssh-key-v1 keys start with the name of their cipher
+	magic := []byte("openssh-key-v1\x00")
+	if block.Type == "OPENSSH PRIVATE KEY" && bytes.HasPrefix(block.Bytes, magic) {
+		rest := block.Bytes[len(magic):]
+		if len(rest) < 4 {
+			return false
+		}
+		length := binary.BigEndian.Uint32(rest)
+		if uint32(len(rest)-4) < length {
+			return false
+		}
+		return string(rest[4:4+length]) != "none"
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package env
+
+import (
+	"encoding/binary"
+	"encoding/pem"
+	"github.com/apflieger/tie/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/libgit2/git2go.v25"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSshCommandIdentities(t *testing.T) {
+	assert.Equal(t, []string{}, sshCommandIdentities(""))
+	assert.Equal(t, []string{"~/.ssh/ci"}, sshCommandIdentities("ssh -i ~/.ssh/ci"))
+	assert.Equal(t, []string{"/keys/a", "/keys/b"}, sshCommandIdentities("ssh -i/keys/a -o IdentityFile=/keys/b -o StrictHostKeyChecking=no"))
+	assert.Equal(t, []string{"/keys/c"}, sshCommandIdentities(`ssh -oIdentityFile="/keys/c" -v`))
+	assert.Equal(t, []string{"/keys/d", "/keys/e"}, sshCommandIdentities("ssh -oIdentityFile /keys/d -o IdentityFile /keys/e"))
+	assert.Equal(t, []string{"/my keys/f", "/my keys/f"}, sshCommandIdentities(`ssh -o "IdentityFile=/my keys/f" -o 'identityfile /my keys/f' -p 22`))
+}
+
+func TestIsEncryptedKey(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "tie-keys-")
+	defer os.RemoveAll(dir)
+
+	writeKey := func(name string, block *pem.Block) string {
+		file := filepath.Join(dir, name)
+		ioutil.WriteFile(file, pem.EncodeToMemory(block), 0600)
+		return file
+	}
+
+	opensshKey := func(cipher string) []byte {
+		key := []byte("openssh-key-v1\x00")
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(cipher)))
+		return append(append(key, length...), cipher...)
+	}
+
+	assert.False(t, isEncryptedKey(writeKey("plain_rsa", &pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("key")})))
+	assert.True(t, isEncryptedKey(writeKey("encrypted_rsa", &pem.Block{
+		Type:    "RSA PRIVATE KEY",
+		Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-128-CBC,00"},
+		Bytes:   []byte("key"),
+	})))
+	assert.False(t, isEncryptedKey(writeKey("plain_openssh", &pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: opensshKey("none")})))
+	assert.True(t, isEncryptedKey(writeKey("encrypted_openssh", &pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: opensshKey("aes256-ctr")})))
+	assert.False(t, isEncryptedKey(filepath.Join(dir, "missing")))
+}
+
+func TestSshKeys(t *testing.T) {
+	test.RunOnRepo(t, "Order", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		home, _ := ioutil.TempDir("", "tie-home-")
+		defer os.RemoveAll(home)
+		previousHome := os.Getenv("HOME")
+		os.Setenv("HOME", home)
+		defer os.Setenv("HOME", previousHome)
+
+		sshDir := filepath.Join(home, ".ssh")
+		os.Mkdir(sshDir, 0700)
+		for _, key := range []string{"id_rsa", "id_ed25519", "configured", "command"} {
+			ioutil.WriteFile(filepath.Join(sshDir, key), []byte{}, 0600)
+		}
+		ioutil.WriteFile(filepath.Join(sshDir, "configured.pub"), []byte{}, 0600)
+
+		config, _ := repo.Config()
+		config.SetString(SshKeyConfigKey, "~/.ssh/configured")
+		config.SetString("core.sshCommand", "ssh -i ~/.ssh/command -i ~/.ssh/missing")
+
+		// Missing keys are ignored and keys are listed once
+		assert.Equal(t, []string{
+			filepath.Join(sshDir, "configured"),
+			filepath.Join(sshDir, "command"),
+			filepath.Join(sshDir, "id_ed25519"),
+			filepath.Join(sshDir, "id_rsa"),
+		}, sshKeys(config))
+
+		assert.Equal(t, filepath.Join(sshDir, "configured.pub"), sshPublicKey(config, filepath.Join(sshDir, "configured")))
+		assert.Equal(t, "", sshPublicKey(config, filepath.Join(sshDir, "command")))
+
+		config.SetString(SshPublicKeyConfigKey, "/elsewhere/key.pub")
+		assert.Equal(t, "/elsewhere/key.pub", sshPublicKey(config, filepath.Join(sshDir, "configured")))
+	})
+
+	test.RunOnRepo(t, "GiveUp", func(t *testing.T, context test.TestContext, repo *git.Repository) {
+		home, _ := ioutil.TempDir("", "tie-home-")
+		defer os.RemoveAll(home)
+		previousHome := os.Getenv("HOME")
+		os.Setenv("HOME", home)
+		defer os.Setenv("HOME", previousHome)
+		previousAgent := os.Getenv("SSH_AUTH_SOCK")
+		os.Unsetenv("SSH_AUTH_SOCK")
+		defer os.Setenv("SSH_AUTH_SOCK", previousAgent)
+
+		os.Mkdir(filepath.Join(home, ".ssh"), 0700)
+		ioutil.WriteFile(filepath.Join(home, ".ssh", "id_rsa"), []byte{}, 0600)
+
+		store := NewCredentialStore(repo)
+
+		// The only key is tried once
+		code, cred := store.Callback("ssh://git@example.com/repo.git", "git", git.CredTypeSshKey)
+		assert.Equal(t, git.ErrOk, code)
+		assert.NotNil(t, cred)
+
+		code, cred = store.Callback("ssh://git@example.com/repo.git", "git", git.CredTypeSshKey)
+		assert.Equal(t, git.ErrAuth, code)
+		assert.Nil(t, cred)
+
+		// Custom signatures are refused
+		code, cred = store.Callback("ssh://git@example.com/repo.git", "git", git.CredTypeSshCustom)
+		assert.Equal(t, git.ErrAuth, code)
+		assert.Nil(t, cred)
+	})
+}
@@ -8,10 +8,29 @@ import (
 const OptionMissing = "OPTION_MISSING"
 const OptionWithoutValue = "OPTION_WITHOUT_VALUE"
 
+// Output formats of the commands that support machine-readable output
+const (
+	FormatText      = "text"
+	FormatJson      = "json"
+	FormatPorcelain = "porcelain"
+)
+
 type OpenEditor func(config *git.Config, file string) (string, error)
 
+// Asks a question to the user and returns the answer
+type Prompt func(question string) (string, error)
+
+// Called once a remote operation is over, so that its credentials can be stored when it succeeded
+type ApproveCredentials func(succeeded bool)
+
 type Context struct {
-	Logger          *log.Logger
-	RemoteCallbacks git.RemoteCallbacks
-	OpenEditor      OpenEditor
+	Logger             *log.Logger
+	RemoteCallbacks    git.RemoteCallbacks
+	OpenEditor         OpenEditor
+	Prompt             Prompt
+	ApproveCredentials ApproveCredentials
+	// One of the Format constants. Empty means FormatText.
+	Format string
+	// Skips the pre-commit, commit-msg and pre-push hooks
+	NoVerify bool
 }
@@ -200,6 +200,13 @@ func WriteFile(repo *git.Repository, add bool, file string, lines ...string) {
 	}
 }
 
+// Installs an executable shell script as the given hook of the repo
+func WriteHook(repo *git.Repository, name, script string) {
+	hooksDir := filepath.Join(repo.Path(), "hooks")
+	os.MkdirAll(hooksDir, 0755)
+	ioutil.WriteFile(filepath.Join(hooksDir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755)
+}
+
 func StatusClean(t *testing.T, repo *git.Repository) bool {
 	statusList, _ := repo.StatusList(nil)
 	statusCount, _ := statusList.EntryCount()
@@ -217,3 +224,22 @@ func CreateTip(repo *git.Repository, tipName, base string, slct bool) {
 		repo.References.CreateSymbolic("HEAD", "refs/tips/"+tipName, true, "")
 	}
 }
+
+// Commits the files on top of refname without touching the index nor the working tree
+func CommitFiles(repo *git.Repository, refname string, files map[string]string) *git.Oid {
+	ref, _ := repo.References.Lookup(refname)
+	parent, _ := repo.LookupCommit(ref.Target())
+	parentTree, _ := parent.Tree()
+
+	builder, _ := repo.TreeBuilderFromTree(parentTree)
+	for file, content := range files {
+		blob, _ := repo.CreateBlobFromBuffer([]byte(content))
+		builder.Insert(file, blob, git.FilemodeBlob)
+	}
+	treeOid, _ := builder.Write()
+	tree, _ := repo.LookupTree(treeOid)
+
+	signature, _ := repo.DefaultSignature()
+	oid, _ := repo.CreateCommit(refname, signature, signature, "default message", tree, parent)
+	return oid
+}
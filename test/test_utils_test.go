@@ -115,3 +115,23 @@ func TestCommit(t *testing.T) {
 		assert.Equal(t, "custom message", commit.Message())
 	})
 }
+
+func TestCommitFiles(t *testing.T) {
+	RunOnRepo(t, "OnRef", func(t *testing.T, context TestContext, repo *git.Repository) {
+		head, _ := repo.Head()
+		repo.References.Create("refs/heads/test", head.Target(), false, "")
+
+		oid := CommitFiles(repo, "refs/heads/test", map[string]string{"foo": "bar"})
+
+		ref, _ := repo.References.Lookup("refs/heads/test")
+		assert.True(t, oid.Equal(ref.Target()))
+
+		commit, _ := repo.LookupCommit(oid)
+		assert.True(t, commit.ParentId(0).Equal(head.Target()))
+		tree, _ := commit.Tree()
+		assert.NotNil(t, tree.EntryByName("foo"))
+
+		// The working tree is untouched
+		StatusClean(t, repo)
+	})
+}
@@ -4,22 +4,18 @@ import (
 	"errors"
 	"fmt"
 	"github.com/apflieger/tie/commands"
+	"github.com/apflieger/tie/core"
 	"github.com/apflieger/tie/env"
 	"github.com/apflieger/tie/model"
 	"github.com/spf13/cobra"
 	"gopkg.in/libgit2/git2go.v25"
 	"log"
 	"os"
+	"strings"
 )
 
 func main() {
-	path, err := git.Discover(".", false, nil)
-
-	if err != nil {
-		fmt.Println(err.Error())
-	}
-
-	repo, err := git.OpenRepository(path)
+	repo, err := core.DiscoverRepository(".")
 
 	if err != nil {
 		fmt.Println(err.Error())
@@ -27,15 +23,26 @@ func main() {
 
 	var rootCmd = &cobra.Command{
 		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return checkFormat()
+		},
 	}
 
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "format", model.FormatText, "output format of list, status and log: text or json")
+	rootCmd.PersistentFlags().BoolVar(&porcelain, "porcelain", false, "stable text output of list, status and log, for scripts")
+	rootCmd.PersistentFlags().BoolVar(&noVerify, "no-verify", false, "bypass the pre-commit, commit-msg and pre-push hooks")
+
+	credentials := env.NewCredentialStore(repo)
+
 	context := model.Context{
 		Logger: log.New(os.Stdout, "", 0),
 		RemoteCallbacks: git.RemoteCallbacks{
-			CredentialsCallback:      env.CredentialCallback,
-			CertificateCheckCallback: env.CertificateCheckCallback,
+			CredentialsCallback:      credentials.Callback,
+			CertificateCheckCallback: env.NewCertificateCheckCallback(repo),
 		},
-		OpenEditor: env.OpenEditor,
+		OpenEditor:         env.OpenEditor,
+		Prompt:             env.Prompt,
+		ApproveCredentials: credentials.Approve,
 	}
 
 	rootCmd.AddCommand(buildCommitCommand(repo, context))
@@ -46,24 +53,86 @@ func main() {
 	rootCmd.AddCommand(buildDeleteCommand(repo, context))
 	rootCmd.AddCommand(buildStackCommand(repo, context))
 	rootCmd.AddCommand(buildUpdateCommand(repo, context))
+	rootCmd.AddCommand(buildStatusCommand(repo, context))
+	rootCmd.AddCommand(buildLogCommand(repo, context))
+	rootCmd.AddCommand(buildDiffCommand(repo, context))
+	rootCmd.AddCommand(buildDescribeCommand(repo, context))
+	rootCmd.AddCommand(buildRenameCommand(repo, context))
+	rootCmd.AddCommand(buildRebaseCommand(repo, context))
+	rootCmd.AddCommand(buildSplitCommand(repo, context))
+	rootCmd.AddCommand(buildFoldCommand(repo, context))
+	rootCmd.AddCommand(buildMoveCommand(repo, context))
+	rootCmd.AddCommand(buildWipCommand(repo, context))
+	rootCmd.AddCommand(buildWorktreeCommand(repo, context))
+	rootCmd.AddCommand(buildUndoCommand(repo, context))
+	rootCmd.AddCommand(buildRedoCommand(repo, context))
+	rootCmd.AddCommand(buildOplogCommand(repo, context))
+
+	var before core.State
+	if repo != nil {
+		before = core.TakeSnapshot(repo)
+	}
 
 	rootCmd.Execute()
+
+	// Record what the command changed so that it can be undone
+	cmd, _, err := rootCmd.Find(os.Args[1:])
+	if err == nil && before != nil && !journalCommands[cmd.Name()] {
+		core.RecordOperation(repo, strings.Join(os.Args[1:], " "), before)
+	}
 }
 
+var outputFormat string
+var porcelain bool
+var noVerify bool
+
+func checkFormat() error {
+	if porcelain {
+		if outputFormat != model.FormatText {
+			return errors.New("--porcelain can't be used with --format.")
+		}
+		outputFormat = model.FormatPorcelain
+	}
+
+	if outputFormat != model.FormatText && outputFormat != model.FormatJson && outputFormat != model.FormatPorcelain {
+		return fmt.Errorf("Unknown format '%v'. Use 'text' or 'json'.", outputFormat)
+	}
+
+	return nil
+}
+
+// Returns the context with the output format given on the command line
+func formatted(context model.Context) model.Context {
+	context.Format = outputFormat
+	return context
+}
+
+// Returns the context with the hooks bypassed if --no-verify is given
+func verified(context model.Context) model.Context {
+	context.NoVerify = noVerify
+	return context
+}
+
+// Commands that are not recorded in the journal
+var journalCommands = map[string]bool{"undo": true, "redo": true, "oplog": true}
+
 func buildCommitCommand(repo *git.Repository, context model.Context) *cobra.Command {
 	var message, tipName string
+	var all, patch bool
 
 	commitCommand := &cobra.Command{
 		Use:   "commit [flags]",
 		Short: "Record changes in the currently selected tip",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return commands.CommitCommand(repo, message, tipName, context)
+			return commands.CommitCommand(repo, message, tipName, all, patch, verified(context))
 		},
 	}
 
 	commitCommand.Flags().StringVarP(&message, "message", "m", "", "commit message")
 	commitCommand.Flags().StringVarP(&tipName, "tip", "t", model.OptionMissing, "create and select a tip on the fly")
 	commitCommand.Flag("tip").NoOptDefVal = model.OptionWithoutValue
+	commitCommand.Flags().BoolVarP(&all, "all", "a", false, "stage the modified and deleted files first")
+	commitCommand.Flags().BoolVarP(&patch, "patch", "p", false, "choose the hunks to commit interactively")
 
 	commitCommand.Aliases = []string{"ci"}
 
@@ -71,28 +140,47 @@ func buildCommitCommand(repo *git.Repository, context model.Context) *cobra.Comm
 }
 
 func buildSelectCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var noStash, worktree bool
+
 	selectCommand := &cobra.Command{
-		Use:   "select [<tip or branch>]",
+		Use:   "select [flags] <tip or branch>",
 		Short: "Switch the repository on the given tip or branch",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return commands.SelectCommand(repo, args[0])
+			if len(args) < 1 {
+				return errors.New("Argument missing")
+			}
+			if worktree {
+				return commands.WorktreeCommand(repo, args[0], "", context)
+			}
+			return commands.SelectCommand(repo, args[0], !noStash, context)
 		},
 	}
 
+	selectCommand.Flags().BoolVarP(&noStash, "no-stash", "", false, "don't save the uncommitted changes of the current tip")
+	selectCommand.Flags().BoolVarP(&worktree, "worktree", "w", false, "check the tip out in its own worktree instead")
+
 	selectCommand.Aliases = []string{"sl"}
 
 	return selectCommand
 }
 
 func buildUpdateCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var recursive, all bool
+
 	updateCommand := &cobra.Command{
 		Use:   "update",
 		Short: "Retrieve latest commits from the remote",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return commands.UpdateCommand(repo, context)
+			if all {
+				return commands.UpdateAllCommand(repo, verified(context))
+			}
+			return commands.UpdateCommand(repo, recursive, verified(context))
 		},
 	}
 
+	updateCommand.Flags().BoolVarP(&recursive, "recursive", "r", false, "also update the tips stacked on the current tip")
+	updateCommand.Flags().BoolVarP(&all, "all", "a", false, "update every tip, leaving the conflicting ones untouched")
+
 	abortCommand := &cobra.Command{
 		Use: "abort",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -103,7 +191,7 @@ func buildUpdateCommand(repo *git.Repository, context model.Context) *cobra.Comm
 	continueCommand := &cobra.Command{
 		Use: "continue",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return commands.UpdateContinueCommand(repo)
+			return commands.UpdateContinueCommand(repo, verified(context))
 		},
 	}
 
@@ -119,11 +207,11 @@ func buildRewriteCommand(repo *git.Repository, context model.Context) *cobra.Com
 		Use:   "rewrite",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 0 {
-				return env.RewriteStartCommand(repo, context)
+				return commands.RewriteStartCommand(repo, verified(context))
 			} else if args[0] == "continue" {
-				return env.RewriteContinueCommand(repo, context)
+				return commands.RewriteContinueCommand(repo, verified(context))
 			} else if args[0] == "abort" {
-				return env.RewriteAbortCommand(repo, context)
+				return commands.RewriteAbortCommand(repo)
 			} else {
 				return fmt.Errorf("Incurrect verb '%v'.\n", args[0])
 			}
@@ -136,7 +224,7 @@ func buildRewriteCommand(repo *git.Repository, context model.Context) *cobra.Com
 		Use:   "amend [flags]",
 		Short: "Meld changes into the previous commit",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return commands.AmendCommand(repo, message, context)
+			return commands.AmendCommand(repo, message, verified(context))
 		},
 	}
 
@@ -145,6 +233,84 @@ func buildRewriteCommand(repo *git.Repository, context model.Context) *cobra.Com
 
 	rewriteCommand.AddCommand(amendCommand)
 
+	dropCommand := &cobra.Command{
+		Use:   "drop <commit>",
+		Short: "Remove a commit from the current tip",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("Argument missing")
+			}
+			return commands.RewriteDropCommand(repo, args[0], verified(context))
+		},
+	}
+
+	rewriteCommand.AddCommand(dropCommand)
+
+	var squashMessage string
+
+	squashCommand := &cobra.Command{
+		Use:   "squash [flags] <commit>|<from>..<to>",
+		Short: "Meld commits into the previous one",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("Argument missing")
+			}
+			return commands.RewriteSquashCommand(repo, args[0], squashMessage, verified(context))
+		},
+	}
+
+	squashCommand.Flags().StringVarP(&squashMessage, "message", "m", model.OptionMissing, "commit message")
+
+	rewriteCommand.AddCommand(squashCommand)
+
+	fixupCommand := &cobra.Command{
+		Use:   "fixup <commit>",
+		Short: "Meld a commit into the previous one, discarding its message",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("Argument missing")
+			}
+			return commands.RewriteFixupCommand(repo, args[0], verified(context))
+		},
+	}
+
+	rewriteCommand.AddCommand(fixupCommand)
+
+	var rewordMessage string
+
+	rewordCommand := &cobra.Command{
+		Use:   "reword [flags] <commit>",
+		Short: "Change the message of a commit",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return errors.New("Argument missing")
+			}
+			return commands.RewriteRewordCommand(repo, args[0], rewordMessage, verified(context))
+		},
+	}
+
+	rewordCommand.Flags().StringVarP(&rewordMessage, "message", "m", model.OptionMissing, "commit message")
+	rewordCommand.Flag("message").NoOptDefVal = model.OptionWithoutValue
+
+	rewriteCommand.AddCommand(rewordCommand)
+
+	var before string
+
+	moveCommand := &cobra.Command{
+		Use:   "move <commit> --before <commit>",
+		Short: "Reorder a commit",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 || len(before) == 0 {
+				return errors.New("Argument missing")
+			}
+			return commands.RewriteMoveCommand(repo, args[0], before, verified(context))
+		},
+	}
+
+	moveCommand.Flags().StringVarP(&before, "before", "", "", "commit to move before")
+
+	rewriteCommand.AddCommand(moveCommand)
+
 	rewriteCommand.Aliases = []string{"rw"}
 
 	return rewriteCommand
@@ -181,7 +347,7 @@ func buildListCommand(repo *git.Repository, context model.Context) *cobra.Comman
 		Use:   "list [flags]",
 		Short: "List tips and branches",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return commands.ListCommand(repo, context, listTips, listBranches, listRemotes, listAll)
+			return commands.ListCommand(repo, formatted(context), listTips, listBranches, listRemotes, listAll)
 		},
 	}
 
@@ -196,29 +362,310 @@ func buildListCommand(repo *git.Repository, context model.Context) *cobra.Comman
 }
 
 func buildDeleteCommand(repo *git.Repository, context model.Context) *cobra.Command {
-	var stacked bool
+	var stacked, merged bool
 
 	deleteCommand := &cobra.Command{
 		Use:   "delete [flags] [<tip>]",
 		Short: "Delete tips",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return commands.DeleteCommand(repo, stacked, args, context)
+			return commands.DeleteCommand(repo, stacked, merged, args, verified(context))
 		},
 	}
 
 	deleteCommand.Flags().BoolVarP(&stacked, "stacked", "", false, "delete tips that have been stacked")
+	deleteCommand.Flags().BoolVarP(&merged, "merged", "", false, "delete tips whose commits have landed on their base")
 
 	return deleteCommand
 }
 
 func buildStackCommand(repo *git.Repository, context model.Context) *cobra.Command {
 	stackCommand := &cobra.Command{
-		Use:   "stack",
+		Use:   "stack [flags]",
 		Short: "Put tip's commits into a branch",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return commands.StackCommand(repo, context)
+			return commands.StackCommand(repo, verified(context))
 		},
 	}
 
 	return stackCommand
 }
+
+func buildWipCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	wipCommand := &cobra.Command{
+		Use:   "wip",
+		Short: "List the uncommitted changes saved on the tips",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return commands.WipCommand(repo, context)
+		},
+	}
+
+	return wipCommand
+}
+
+func buildWorktreeCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	worktreeCommand := &cobra.Command{
+		Use:   "worktree [<tip> [<path>]]",
+		Short: "Check a tip out in its own worktree, or list the worktrees of the tips",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch len(args) {
+			case 0:
+				return commands.WorktreeListCommand(repo, context)
+			case 1:
+				return commands.WorktreeCommand(repo, args[0], "", context)
+			default:
+				return commands.WorktreeCommand(repo, args[0], args[1], context)
+			}
+		},
+	}
+
+	return worktreeCommand
+}
+
+func buildUndoCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var push bool
+
+	undoCommand := &cobra.Command{
+		Use:   "undo [flags]",
+		Short: "Revert the tips, HEAD and bases to their state before the last command",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return commands.UndoCommand(repo, push, verified(context))
+		},
+	}
+
+	undoCommand.Flags().BoolVarP(&push, "push", "p", false, "push the affected tips")
+
+	return undoCommand
+}
+
+func buildRedoCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var push bool
+
+	redoCommand := &cobra.Command{
+		Use:   "redo [flags]",
+		Short: "Apply again the last undone command",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return commands.RedoCommand(repo, push, verified(context))
+		},
+	}
+
+	redoCommand.Flags().BoolVarP(&push, "push", "p", false, "push the affected tips")
+
+	return redoCommand
+}
+
+func buildOplogCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	oplogCommand := &cobra.Command{
+		Use:   "oplog",
+		Short: "List the commands that can be undone",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return commands.OplogCommand(repo, context)
+		},
+	}
+
+	return oplogCommand
+}
+
+func buildStatusCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	statusCommand := &cobra.Command{
+		Use:   "status",
+		Short: "Show where the selected tip stands against its base and its remote",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return commands.StatusCommand(repo, formatted(context))
+		},
+	}
+
+	statusCommand.Aliases = []string{"st"}
+
+	return statusCommand
+}
+
+func buildLogCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var allTips, full, stat, patch bool
+
+	logCommand := &cobra.Command{
+		Use:   "log [flags] [<tip>]",
+		Short: "Show the commits of a tip, between its tail and its head",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tip := ""
+			if len(args) > 0 {
+				tip = args[0]
+			}
+			return commands.LogCommand(repo, tip, allTips, full, stat, patch, formatted(context))
+		},
+	}
+
+	logCommand.Flags().BoolVarP(&allTips, "all-tips", "", false, "show the commits of every tip, grouped by tip")
+	logCommand.Flags().BoolVarP(&full, "full", "f", false, "show the author, the date and the whole message of the commits")
+	logCommand.Flags().BoolVarP(&stat, "stat", "", false, "show the files changed by each commit")
+	logCommand.Flags().BoolVarP(&patch, "patch", "p", false, "show the changes of each commit")
+
+	return logCommand
+}
+
+func buildDiffCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var remote, nameStatus bool
+
+	diffCommand := &cobra.Command{
+		Use:   "diff [flags] [<tip>]",
+		Short: "Show the changes of a tip since its tail, or since it was last pushed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tip := ""
+			if len(args) > 0 {
+				tip = args[0]
+			}
+			return commands.DiffCommand(repo, tip, remote, nameStatus, context)
+		},
+	}
+
+	diffCommand.Flags().BoolVarP(&remote, "remote", "r", false, "compare the commits of the tip with the ones of its remote tip")
+	diffCommand.Flags().BoolVarP(&nameStatus, "name-status", "", false, "show only the names and the status of the changed files")
+
+	return diffCommand
+}
+
+func buildDescribeCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var message string
+
+	describeCommand := &cobra.Command{
+		Use:   "describe [flags] [<tip>]",
+		Short: "Edit the title and the description of a tip",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tip := ""
+			if len(args) > 0 {
+				tip = args[0]
+			}
+			return commands.DescribeCommand(repo, tip, message, verified(context))
+		},
+	}
+
+	describeCommand.Flags().StringVarP(&message, "message", "m", model.OptionMissing, "description, an empty one removes it")
+
+	return describeCommand
+}
+
+func buildRenameCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename <old> <new>",
+		Short: "Rename a tip, locally and on its remote",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("Expected the current name and the new name of the tip.")
+			}
+			return commands.RenameCommand(repo, args[0], args[1], verified(context))
+		},
+	}
+}
+
+func buildRebaseCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var onto string
+
+	rebaseCommand := &cobra.Command{
+		Use:   "rebase --onto <newbase> [<tip>]",
+		Short: "Move a tip onto another base",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tip := ""
+			if len(args) > 0 {
+				tip = args[0]
+			}
+			return commands.RebaseCommand(repo, onto, tip, verified(context))
+		},
+	}
+
+	rebaseCommand.Flags().StringVarP(&onto, "onto", "", "", "new base of the tip")
+
+	abortCommand := &cobra.Command{
+		Use: "abort",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return commands.RebaseAbortCommand(repo)
+		},
+	}
+
+	continueCommand := &cobra.Command{
+		Use: "continue",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return commands.RebaseContinueCommand(repo, verified(context))
+		},
+	}
+
+	rebaseCommand.AddCommand(abortCommand)
+	rebaseCommand.AddCommand(continueCommand)
+
+	return rebaseCommand
+}
+
+func buildSplitCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var at, name string
+
+	splitCommand := &cobra.Command{
+		Use:   "split [<tip>] --at <commit> --name <new>",
+		Short: "Move the commits after a commit into a new tip stacked on the tip",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tip := ""
+			if len(args) > 0 {
+				tip = args[0]
+			}
+			return commands.SplitCommand(repo, tip, at, name, verified(context))
+		},
+	}
+
+	splitCommand.Flags().StringVarP(&at, "at", "", "", "last commit kept in the tip")
+	splitCommand.Flags().StringVarP(&name, "name", "n", "", "name of the new tip")
+
+	return splitCommand
+}
+
+func buildFoldCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var into string
+
+	foldCommand := &cobra.Command{
+		Use:   "fold [<src>] [--into <dst>]",
+		Short: "Replay the commits of a tip on another tip and delete it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src := ""
+			if len(args) > 0 {
+				src = args[0]
+			}
+			return commands.FoldCommand(repo, src, into, verified(context))
+		},
+	}
+
+	foldCommand.Flags().StringVarP(&into, "into", "", "", "tip receiving the commits, the base of the folded tip by default")
+
+	return foldCommand
+}
+
+func buildMoveCommand(repo *git.Repository, context model.Context) *cobra.Command {
+	var to string
+
+	moveCommand := &cobra.Command{
+		Use:   "move <commit> --to <tip>",
+		Short: "Move a commit from its tip to another tip",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("Expected the commit to move.")
+			}
+			return commands.MoveCommand(repo, args[0], to, verified(context))
+		},
+	}
+
+	moveCommand.Flags().StringVarP(&to, "to", "", "", "tip receiving the commit")
+
+	abortCommand := &cobra.Command{
+		Use: "abort",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return commands.MoveAbortCommand(repo)
+		},
+	}
+
+	continueCommand := &cobra.Command{
+		Use: "continue",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return commands.MoveContinueCommand(repo, verified(context))
+		},
+	}
+
+	moveCommand.AddCommand(abortCommand)
+	moveCommand.AddCommand(continueCommand)
+
+	return moveCommand
+}